@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "books-api"
+	testKeyID    = "test-key"
+)
+
+// a mock OpenID Connect provider: discovery, key set and a token endpoint that answers
+// every code with an ID token carrying the claims of the test
+type mockOIDCServer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+	// form of the last token request
+	form url.Values
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDCServer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": testKeyID,
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.form = r.PostForm
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims)
+		token.Header["kid"] = testKeyID
+		signed, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockOIDCServer) provider() *OIDCProvider {
+	return &OIDCProvider{
+		Issuer:      m.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/auth/oidc/callback",
+		Scopes:      []string{"openid", "profile"},
+		GroupsClaim: "groups",
+		GroupRoles:  map[string]string{"library-admins": RoleAdmin, "library-staff": RoleEditor, "typo": "owner"},
+		HTTPClient:  m.Client(),
+	}
+}
+
+func (m *mockOIDCServer) idTokenClaims(nonce string, groups ...interface{}) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                m.URL,
+		"aud":                testClientID,
+		"sub":                "user-123",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"nonce":              nonce,
+		"preferred_username": "ada",
+		"email":              "ada@example.com",
+		"groups":             groups,
+	}
+}
+
+func TestOIDCAuthCodeURL(t *testing.T) {
+	m := newMockOIDCServer(t)
+
+	raw, err := m.provider().AuthCodeURL(context.Background(), "state-1", "nonce-1", PKCEChallenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != m.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %q", got)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"scope":                 "openid profile",
+		"code_challenge":        PKCEChallenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for param, value := range want {
+		if got := u.Query().Get(param); got != value {
+			t.Errorf("%s = %q, want %q", param, got, value)
+		}
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		groups   []interface{}
+		wantRole string
+	}{
+		{name: "admin group", groups: []interface{}{"library-admins"}, wantRole: RoleAdmin},
+		{name: "most privileged group wins", groups: []interface{}{"library-staff", "library-admins"}, wantRole: RoleAdmin},
+		{name: "editor group", groups: []interface{}{"other", "library-staff"}, wantRole: RoleEditor},
+		{name: "no mapped group demotes to viewer", groups: []interface{}{"other"}, wantRole: RoleViewer},
+		{name: "no groups demotes to viewer", wantRole: RoleViewer},
+		{name: "group mapped to an unknown role is ignored", groups: []interface{}{"typo"}, wantRole: RoleViewer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockOIDCServer(t)
+			m.claims = m.idTokenClaims("nonce-1", tt.groups...)
+			p := m.provider()
+
+			identity, err := p.Exchange(context.Background(), "code-1", "verifier-1", "nonce-1")
+			if err != nil {
+				t.Fatalf("Exchange error: %v", err)
+			}
+			if identity.Provider != m.URL || identity.Subject != "user-123" || identity.Username != "ada" {
+				t.Errorf("identity = %+v", identity)
+			}
+			if m.form.Get("code") != "code-1" || m.form.Get("code_verifier") != "verifier-1" {
+				t.Errorf("token request form = %v", m.form)
+			}
+			if got := p.RoleForGroups(identity.Groups); got != tt.wantRole {
+				t.Errorf("RoleForGroups(%v) = %q, want %q", identity.Groups, got, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(m *mockOIDCServer, claims jwt.MapClaims)
+	}{
+		{name: "nonce mismatch", modify: func(m *mockOIDCServer, claims jwt.MapClaims) { claims["nonce"] = "other" }},
+		{name: "wrong audience", modify: func(m *mockOIDCServer, claims jwt.MapClaims) { claims["aud"] = "other-client" }},
+		{name: "wrong issuer", modify: func(m *mockOIDCServer, claims jwt.MapClaims) { claims["iss"] = "https://evil.example" }},
+		{name: "expired", modify: func(m *mockOIDCServer, claims jwt.MapClaims) {
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		}},
+		{name: "no subject", modify: func(m *mockOIDCServer, claims jwt.MapClaims) { delete(claims, "sub") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockOIDCServer(t)
+			m.claims = m.idTokenClaims("nonce-1")
+			tt.modify(m, m.claims)
+
+			if identity, err := m.provider().Exchange(context.Background(), "code-1", "verifier-1", "nonce-1"); err == nil {
+				t.Errorf("Exchange accepted the token, identity = %+v", identity)
+			}
+		})
+	}
+}
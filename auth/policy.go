@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+const (
+	PermAuthorRead    = "author:read"
+	PermAuthorWrite   = "author:write"
+	PermAuthorDelete  = "author:delete"
+	PermBookRead      = "book:read"
+	PermBookWrite     = "book:write"
+	PermBookDelete    = "book:delete"
+	PermAuditRead     = "audit:read"
+	PermUserManage    = "user:manage"
+	PermMaintenance   = "maintenance"
+	PermAPIKeyManage  = "apikey:manage"
+	PermLibraryManage = "library:manage"
+
+	permAll = "*"
+)
+
+// Permissions lists every permission, API key scopes must be one of these
+var Permissions = []string{
+	PermAuthorRead, PermAuthorWrite, PermAuthorDelete,
+	PermBookRead, PermBookWrite, PermBookDelete,
+	PermAuditRead, PermUserManage, PermMaintenance, PermAPIKeyManage, PermLibraryManage,
+}
+
+// IsPermission reports whether the permission is known
+func IsPermission(permission string) bool {
+	for _, known := range Permissions {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy maps each role to the permissions it grants, "*" grants everything
+type Policy struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+var DefaultPolicy = Policy{
+	Roles: map[string][]string{
+		RoleViewer: {PermAuthorRead, PermBookRead},
+		RoleEditor: {PermAuthorRead, PermBookRead, PermAuthorWrite, PermBookWrite},
+		RoleAdmin:  {permAll},
+	},
+}
+
+var (
+	policyMu sync.RWMutex
+	policy   = DefaultPolicy
+)
+
+// LoadPolicy reads the policy file named by POLICY_FILE, keeping the default policy if unset
+func LoadPolicy() error {
+	path := os.Getenv("POLICY_FILE")
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var loaded Policy
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(loaded.Roles) == 0 {
+		return fmt.Errorf("parsing %s: no roles defined", path)
+	}
+
+	policyMu.Lock()
+	policy = loaded
+	policyMu.Unlock()
+	return nil
+}
+
+// IsRole reports whether the role exists in the current policy
+func IsRole(role string) bool {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	_, ok := policy.Roles[role]
+	return ok
+}
+
+// Allowed reports whether the role grants the permission
+func Allowed(role string, permission string) bool {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	for _, granted := range policy.Roles[role] {
+		if granted == permAll || granted == permission {
+			return true
+		}
+	}
+	return false
+}
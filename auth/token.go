@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type Claims struct {
+	UserID   string   `json:"uid"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Scopes   []string `json:"scopes,omitempty"`
+	// LibraryID pins the caller to one library, empty for admins who may pick any
+	LibraryID string `json:"lib,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Can checks API key scopes when present, otherwise the role's permissions
+func (c *Claims) Can(permission string) bool {
+	if c.Scopes != nil {
+		for _, scope := range c.Scopes {
+			if scope == permission {
+				return true
+			}
+		}
+		return false
+	}
+	return Allowed(c.Role, permission)
+}
+
+func secret() []byte {
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+func ttlFromEnv(key string, fallback time.Duration) time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv(key)); err == nil && ttl > 0 {
+		return ttl
+	}
+	return fallback
+}
+
+// AccessTokenTTL is read from ACCESS_TOKEN_TTL, e.g. "15m"
+func AccessTokenTTL() time.Duration {
+	return ttlFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL is read from REFRESH_TOKEN_TTL, e.g. "720h"
+func RefreshTokenTTL() time.Duration {
+	return ttlFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+// NewAccessToken signs a short-lived HS256 token for the user
+func NewAccessToken(userID string, username string, role string, libraryID string) (string, *Claims, error) {
+	now := time.Now().UTC()
+	claims := &Claims{
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		LibraryID: libraryID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        RandomToken(),
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret())
+	return signed, claims, err
+}
+
+// ParseAccessToken verifies the signature and expiry of an access token
+func ParseAccessToken(token string) (*Claims, error) {
+	claims := &Claims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return secret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// RandomToken returns 32 random bytes hex encoded, used for refresh tokens and token IDs
+func RandomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// HashToken is how opaque tokens are stored at rest
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
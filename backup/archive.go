@@ -0,0 +1,179 @@
+// Package backup reads and writes catalog archives: a gzipped NDJSON stream
+// that starts with a manifest line, holds one line per document and ends with
+// a trailer carrying the document counts, so truncated archives are detected.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FormatVersion is written to every manifest, readers reject newer versions
+const FormatVersion = 1
+
+const (
+	kindManifest = "manifest"
+	kindDocument = "document"
+	kindEnd      = "end"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported archive version")
+	ErrTruncated          = errors.New("archive is truncated")
+	ErrCountMismatch      = errors.New("archive document counts do not match its trailer")
+)
+
+type Manifest struct {
+	Kind        string    `json:"kind"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Database    string    `json:"database,omitempty"`
+	Collections []string  `json:"collections"`
+}
+
+type trailer struct {
+	Kind   string           `json:"kind"`
+	Counts map[string]int64 `json:"counts"`
+}
+
+type line struct {
+	Kind       string           `json:"kind"`
+	Collection string           `json:"collection,omitempty"`
+	Document   json.RawMessage  `json:"document,omitempty"`
+	Counts     map[string]int64 `json:"counts,omitempty"`
+}
+
+type Writer struct {
+	gz     *gzip.Writer
+	buf    *bufio.Writer
+	counts map[string]int64
+}
+
+// NewWriter starts an archive and writes its manifest
+func NewWriter(w io.Writer, manifest Manifest) (*Writer, error) {
+	gz := gzip.NewWriter(w)
+	aw := &Writer{gz: gz, buf: bufio.NewWriter(gz), counts: map[string]int64{}}
+
+	manifest.Kind = kindManifest
+	manifest.Version = FormatVersion
+	if manifest.CreatedAt.IsZero() {
+		manifest.CreatedAt = time.Now().UTC()
+	}
+	if err := aw.writeLine(manifest); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+func (w *Writer) writeLine(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if _, err := w.buf.Write(data); err != nil {
+		return err
+	}
+	return w.buf.WriteByte('\n')
+}
+
+// Write adds one raw document, stored as canonical extended JSON so ObjectIDs and dates survive
+func (w *Writer) Write(collection string, doc bson.Raw) error {
+	data, err := bson.MarshalExtJSON(doc, true, false)
+	if err != nil {
+		return err
+	}
+	w.counts[collection]++
+	return w.writeLine(line{Kind: kindDocument, Collection: collection, Document: data})
+}
+
+// Counts is the number of documents written per collection so far
+func (w *Writer) Counts() map[string]int64 {
+	return w.counts
+}
+
+// Close writes the trailer and flushes the archive, the underlying writer is left open
+func (w *Writer) Close() error {
+	if err := w.writeLine(trailer{Kind: kindEnd, Counts: w.counts}); err != nil {
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+type Reader struct {
+	Manifest Manifest
+	gz       *gzip.Reader
+	buf      *bufio.Reader
+	counts   map[string]int64
+}
+
+// NewReader opens an archive and reads its manifest
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	ar := &Reader{gz: gz, buf: bufio.NewReader(gz), counts: map[string]int64{}}
+
+	data, err := ar.buf.ReadBytes('\n')
+	if err != nil && len(data) == 0 {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &ar.Manifest); err != nil || ar.Manifest.Kind != kindManifest {
+		return nil, errors.New("archive does not start with a manifest")
+	}
+	if ar.Manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, ar.Manifest.Version)
+	}
+	return ar, nil
+}
+
+// Next returns the next document and its collection, io.EOF once the trailer
+// has been read and the counts check out
+func (r *Reader) Next() (string, bson.M, error) {
+	data, err := r.buf.ReadBytes('\n')
+	if err == io.EOF && len(data) == 0 {
+		return "", nil, ErrTruncated
+	}
+	if err != nil && err != io.EOF {
+		return "", nil, err
+	}
+
+	var l line
+	if err := json.Unmarshal(data, &l); err != nil {
+		return "", nil, fmt.Errorf("invalid archive line: %w", err)
+	}
+
+	switch l.Kind {
+	case kindDocument:
+		var doc bson.M
+		if err := bson.UnmarshalExtJSON(l.Document, true, &doc); err != nil {
+			return "", nil, fmt.Errorf("invalid %s document: %w", l.Collection, err)
+		}
+		r.counts[l.Collection]++
+		return l.Collection, doc, nil
+	case kindEnd:
+		for collection, count := range l.Counts {
+			if r.counts[collection] != count {
+				return "", nil, fmt.Errorf("%w: %s has %d, expected %d", ErrCountMismatch, collection, r.counts[collection], count)
+			}
+		}
+		return "", nil, io.EOF
+	default:
+		return "", nil, fmt.Errorf("unknown archive line kind %q", l.Kind)
+	}
+}
+
+// Close releases the gzip reader, the underlying reader is left open
+func (r *Reader) Close() error {
+	return r.gz.Close()
+}
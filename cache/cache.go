@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache stores serialized responses. Entries carry tags so that every entry
+// depending on a record can be dropped when that record changes. Implement it
+// over an external store to share the cache between instances.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration, tags ...string)
+	InvalidateTags(tags ...string)
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+// LRU is an in-process cache holding at most size entries, evicting the least recently used
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	tagged  map[string]map[string]struct{}
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+		tagged:  map[string]map[string]struct{}{},
+	}
+}
+
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := element.Value.(*entry)
+	if time.Now().After(e.expires) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return e.value, true
+}
+
+func (c *LRU) Set(key string, value []byte, ttl time.Duration, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	e := &entry{key: key, value: value, expires: time.Now().Add(ttl), tags: tags}
+	c.entries[key] = c.order.PushFront(e)
+	for _, tag := range tags {
+		if c.tagged[tag] == nil {
+			c.tagged[tag] = map[string]struct{}{}
+		}
+		c.tagged[tag][key] = struct{}{}
+	}
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRU) InvalidateTags(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.tagged[tag] {
+			if element, ok := c.entries[key]; ok {
+				c.remove(element)
+			}
+		}
+		delete(c.tagged, tag)
+	}
+}
+
+func (c *LRU) remove(element *list.Element) {
+	e := element.Value.(*entry)
+	c.order.Remove(element)
+	delete(c.entries, e.key)
+	for _, tag := range e.tags {
+		delete(c.tagged[tag], e.key)
+		if len(c.tagged[tag]) == 0 {
+			delete(c.tagged, tag)
+		}
+	}
+}
+
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Invalidations uint64 `json:"invalidations"`
+}
+
+// Metered counts hits, misses and invalidations of any Cache
+type Metered struct {
+	Cache
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+func NewMetered(c Cache) *Metered {
+	return &Metered{Cache: c}
+}
+
+func (m *Metered) Get(key string) ([]byte, bool) {
+	value, ok := m.Cache.Get(key)
+	if ok {
+		m.hits.Add(1)
+	} else {
+		m.misses.Add(1)
+	}
+	return value, ok
+}
+
+func (m *Metered) InvalidateTags(tags ...string) {
+	m.invalidations.Add(uint64(len(tags)))
+	m.Cache.InvalidateTags(tags...)
+}
+
+func (m *Metered) Stats() Stats {
+	return Stats{Hits: m.hits.Load(), Misses: m.misses.Load(), Invalidations: m.invalidations.Load()}
+}
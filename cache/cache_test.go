@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// keys of the entries still cached, in order
+func cachedKeys(c *LRU, keys ...string) []string {
+	found := []string{}
+	for _, key := range keys {
+		if _, ok := c.Get(key); ok {
+			found = append(found, key)
+		}
+	}
+	return found
+}
+
+func TestLRUGetSet(t *testing.T) {
+	c := NewLRU(10)
+	c.Set("a", []byte("1"), time.Hour)
+
+	if value, ok := c.Get("a"); !ok || string(value) != "1" {
+		t.Errorf("Get(a) = %q, %v", value, ok)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Errorf("Get(missing) hit")
+	}
+
+	c.Set("a", []byte("2"), time.Hour)
+	if value, _ := c.Get("a"); string(value) != "2" {
+		t.Errorf("Get(a) after overwrite = %q", value)
+	}
+
+	c.Set("expired", []byte("x"), -time.Second, "tag")
+	if _, ok := c.Get("expired"); ok {
+		t.Errorf("expired entry was served")
+	}
+	if _, ok := c.tagged["tag"]; ok {
+		t.Errorf("expired entry left its tag behind")
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2)
+	c.Set("a", []byte("1"), time.Hour, "t")
+	c.Set("b", []byte("2"), time.Hour)
+	c.Get("a")
+	c.Set("c", []byte("3"), time.Hour)
+
+	if got := cachedKeys(c, "a", "b", "c"); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("cached = %v, want [a c]", got)
+	}
+
+	c.Set("d", []byte("4"), time.Hour)
+	if _, ok := c.tagged["t"]; ok {
+		t.Errorf("evicted entry left its tag behind")
+	}
+}
+
+func TestLRUInvalidateTags(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate []string
+		want       []string
+	}{
+		{name: "one tag", invalidate: []string{"book:1"}, want: []string{"author-page", "author-list"}},
+		{name: "list tag", invalidate: []string{"list:books"}, want: []string{"book-page", "author-page", "author-list"}},
+		{name: "several tags", invalidate: []string{"author:1", "list:books"}, want: []string{"author-list"}},
+		{name: "unknown tag", invalidate: []string{"book:2"}, want: []string{"book-page", "author-page", "book-list", "author-list"}},
+		{name: "no tags", want: []string{"book-page", "author-page", "book-list", "author-list"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU(10)
+			c.Set("book-page", []byte("{}"), time.Hour, "book:1", "author:1")
+			c.Set("author-page", []byte("{}"), time.Hour, "author:1")
+			c.Set("book-list", []byte("[]"), time.Hour, "list:books", "book:1")
+			c.Set("author-list", []byte("[]"), time.Hour, "list:authors")
+
+			c.InvalidateTags(tt.invalidate...)
+			if got := cachedKeys(c, "book-page", "author-page", "book-list", "author-list"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cached = %v, want %v", got, tt.want)
+			}
+
+			// Entries that are gone must not be left in any tag
+			for tag, keys := range c.tagged {
+				for key := range keys {
+					if _, ok := c.entries[key]; !ok {
+						t.Errorf("tag %s still lists dropped entry %s", tag, key)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMeteredStats(t *testing.T) {
+	m := NewMetered(NewLRU(10))
+	m.Set("a", []byte("1"), time.Hour, "t")
+
+	m.Get("a")
+	m.Get("a")
+	m.Get("b")
+	m.InvalidateTags("t", "u")
+	m.Get("a")
+
+	if got, want := m.Stats(), (Stats{Hits: 2, Misses: 2, Invalidations: 2}); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
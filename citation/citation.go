@@ -0,0 +1,347 @@
+// Package citation renders catalog books as BibTeX, RIS, CSL-JSON and APA or MLA references.
+package citation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	FormatBibTeX  = "bibtex"
+	FormatRIS     = "ris"
+	FormatCSLJSON = "csl-json"
+	FormatAPA     = "apa"
+	FormatMLA     = "mla"
+)
+
+var ErrUnknownFormat = errors.New("unknown citation format")
+
+var contentTypes = map[string]string{
+	FormatBibTeX:  "application/x-bibtex; charset=utf-8",
+	FormatRIS:     "application/x-research-info-systems; charset=utf-8",
+	FormatCSLJSON: "application/vnd.citationstyles.csl+json; charset=utf-8",
+	FormatAPA:     "text/plain; charset=utf-8",
+	FormatMLA:     "text/plain; charset=utf-8",
+}
+
+// Name is a personal name split the way citation styles need it
+type Name struct {
+	Given  string `json:"given,omitempty"`
+	Family string `json:"family"`
+}
+
+// Entry is one book to cite, Authors in citation order
+type Entry struct {
+	ID        string
+	Title     string
+	Subtitle  string
+	Authors   []Name
+	Publisher string
+	Year      int
+	ISBN      string
+}
+
+// lowercase particles that belong to the family name, as in "Ludwig van Beethoven"
+var nameParticles = map[string]bool{
+	"van": true, "von": true, "der": true, "den": true, "de": true, "du": true,
+	"da": true, "di": true, "del": true, "della": true, "le": true, "la": true,
+}
+
+// ParseName splits "Given Family" or "Family, Given"
+func ParseName(full string) Name {
+	full = strings.TrimSpace(full)
+	if family, given, ok := strings.Cut(full, ","); ok {
+		return Name{Given: strings.TrimSpace(given), Family: strings.TrimSpace(family)}
+	}
+
+	words := strings.Fields(full)
+	if len(words) <= 1 {
+		return Name{Family: full}
+	}
+	split := len(words) - 1
+	for split > 1 && nameParticles[strings.ToLower(words[split-1])] {
+		split--
+	}
+	return Name{Given: strings.Join(words[:split], " "), Family: strings.Join(words[split:], " ")}
+}
+
+func ContentType(format string) (string, bool) {
+	contentType, ok := contentTypes[format]
+	return contentType, ok
+}
+
+// Format renders the entries in the given format
+func Format(format string, entries []Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case FormatBibTeX:
+		keys := map[string]int{}
+		for i, entry := range entries {
+			if i > 0 {
+				buf.WriteByte('\n')
+			}
+			writeBibTeX(&buf, entry, keys)
+		}
+	case FormatRIS:
+		for _, entry := range entries {
+			writeRIS(&buf, entry)
+		}
+	case FormatCSLJSON:
+		items := make([]cslItem, len(entries))
+		for i, entry := range entries {
+			items[i] = newCSLItem(entry)
+		}
+		data, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	case FormatAPA:
+		for _, entry := range entries {
+			buf.WriteString(apa(entry))
+			buf.WriteByte('\n')
+		}
+	case FormatMLA:
+		for _, entry := range entries {
+			buf.WriteString(mla(entry))
+			buf.WriteByte('\n')
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+	return buf.Bytes(), nil
+}
+
+func fullTitle(entry Entry) string {
+	if entry.Subtitle == "" {
+		return entry.Title
+	}
+	return entry.Title + ": " + entry.Subtitle
+}
+
+func invertedName(name Name) string {
+	if name.Given == "" {
+		return name.Family
+	}
+	return name.Family + ", " + name.Given
+}
+
+// ASCII letters and digits of s, lowercased, for citation keys
+func keyPart(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// citation key like "leguin1969left", skipping a leading article,
+// suffixed b, c, ... when several books share one
+func bibTeXKey(entry Entry, keys map[string]int) string {
+	key := "book"
+	if len(entry.Authors) > 0 {
+		key = keyPart(entry.Authors[0].Family)
+	}
+	if entry.Year > 0 {
+		key += strconv.Itoa(entry.Year)
+	}
+	for _, word := range strings.Fields(entry.Title) {
+		if lower := strings.ToLower(word); lower != "a" && lower != "an" && lower != "the" {
+			key += keyPart(word)
+			break
+		}
+	}
+	if key == "" {
+		key = "book"
+	}
+
+	keys[key]++
+	if n := keys[key]; n > 1 {
+		key += string(rune('a' + n - 1))
+	}
+	return key
+}
+
+// escape the characters BibTeX treats specially inside braces
+func bibTeXEscape(s string) string {
+	replacer := strings.NewReplacer(`\`, `\textbackslash{}`, "{", `\{`, "}", `\}`, "&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`, "_", `\_`)
+	return replacer.Replace(s)
+}
+
+func writeBibTeX(buf *bytes.Buffer, entry Entry, keys map[string]int) {
+	fmt.Fprintf(buf, "@book{%s,\n", bibTeXKey(entry, keys))
+
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(buf, "  %s = {%s},\n", name, bibTeXEscape(value))
+		}
+	}
+
+	names := make([]string, len(entry.Authors))
+	for i, author := range entry.Authors {
+		names[i] = invertedName(author)
+	}
+	field("author", strings.Join(names, " and "))
+	field("title", entry.Title)
+	field("subtitle", entry.Subtitle)
+	field("publisher", entry.Publisher)
+	if entry.Year > 0 {
+		field("year", strconv.Itoa(entry.Year))
+	}
+	field("isbn", entry.ISBN)
+	buf.WriteString("}\n")
+}
+
+func writeRIS(buf *bytes.Buffer, entry Entry) {
+	tag := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(buf, "%s  - %s\r\n", name, value)
+		}
+	}
+
+	tag("TY", "BOOK")
+	for _, author := range entry.Authors {
+		tag("AU", invertedName(author))
+	}
+	tag("TI", fullTitle(entry))
+	tag("PB", entry.Publisher)
+	if entry.Year > 0 {
+		tag("PY", strconv.Itoa(entry.Year))
+	}
+	tag("SN", entry.ISBN)
+	tag("ID", entry.ID)
+	buf.WriteString("ER  - \r\n")
+}
+
+type cslItem struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	Title     string   `json:"title"`
+	Author    []Name   `json:"author,omitempty"`
+	Publisher string   `json:"publisher,omitempty"`
+	Issued    *cslDate `json:"issued,omitempty"`
+	ISBN      string   `json:"ISBN,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+func newCSLItem(entry Entry) cslItem {
+	item := cslItem{
+		ID:        entry.ID,
+		Type:      "book",
+		Title:     fullTitle(entry),
+		Author:    entry.Authors,
+		Publisher: entry.Publisher,
+		ISBN:      entry.ISBN,
+	}
+	if entry.Year > 0 {
+		item.Issued = &cslDate{DateParts: [][]int{{entry.Year}}}
+	}
+	return item
+}
+
+// "Ursula K." -> "U. K.", hyphenated names keep the hyphen: "Jean-Paul" -> "J.-P."
+func initials(given string) string {
+	var parts []string
+	for _, word := range strings.Fields(given) {
+		var hyphenated []string
+		for _, piece := range strings.Split(word, "-") {
+			if r := []rune(piece); len(r) > 0 {
+				hyphenated = append(hyphenated, string(r[0])+".")
+			}
+		}
+		parts = append(parts, strings.Join(hyphenated, "-"))
+	}
+	return strings.Join(parts, " ")
+}
+
+func ensurePeriod(s string) string {
+	if s == "" || strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
+
+// APA 7: "Le Guin, U. K., & Gaiman, N. (1969). Title: Subtitle. Publisher."
+func apa(entry Entry) string {
+	names := make([]string, len(entry.Authors))
+	for i, author := range entry.Authors {
+		names[i] = author.Family
+		if author.Given != "" {
+			names[i] += ", " + initials(author.Given)
+		}
+	}
+
+	// Up to 20 authors are listed, beyond that the first 19, an ellipsis and the last
+	if len(names) > 20 {
+		names = append(append(names[:19:19], "..."), names[len(names)-1])
+	}
+
+	var authors string
+	switch {
+	case len(names) == 1:
+		authors = names[0]
+	case len(names) > 1 && names[len(names)-2] == "...":
+		authors = strings.Join(names[:len(names)-2], ", ") + ", . . . " + names[len(names)-1]
+	case len(names) > 1:
+		authors = strings.Join(names[:len(names)-1], ", ") + ", & " + names[len(names)-1]
+	}
+
+	year := "n.d."
+	if entry.Year > 0 {
+		year = strconv.Itoa(entry.Year)
+	}
+
+	parts := []string{}
+	if authors != "" {
+		parts = append(parts, ensurePeriod(authors))
+	}
+	parts = append(parts, "("+year+").", ensurePeriod(fullTitle(entry)))
+	if entry.Publisher != "" {
+		parts = append(parts, ensurePeriod(entry.Publisher))
+	}
+	return strings.Join(parts, " ")
+}
+
+// MLA 9: "Le Guin, Ursula K., and Neil Gaiman. Title: Subtitle. Publisher, 1969."
+func mla(entry Entry) string {
+	var authors string
+	switch len(entry.Authors) {
+	case 0:
+	case 1:
+		authors = invertedName(entry.Authors[0])
+	case 2:
+		second := entry.Authors[1]
+		authors = invertedName(entry.Authors[0]) + ", and " + strings.TrimSpace(second.Given+" "+second.Family)
+	default:
+		authors = invertedName(entry.Authors[0]) + ", et al"
+	}
+
+	parts := []string{}
+	if authors != "" {
+		parts = append(parts, ensurePeriod(authors))
+	}
+	parts = append(parts, ensurePeriod(fullTitle(entry)))
+
+	var published []string
+	if entry.Publisher != "" {
+		published = append(published, entry.Publisher)
+	}
+	if entry.Year > 0 {
+		published = append(published, strconv.Itoa(entry.Year))
+	}
+	if len(published) > 0 {
+		parts = append(parts, strings.Join(published, ", ")+".")
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,155 @@
+package citation
+
+import (
+	"errors"
+	"testing"
+)
+
+var leGuin = Name{Given: "Ursula K.", Family: "Le Guin"}
+
+var leftHand = Entry{
+	ID:        "64b000000000000000000001",
+	Title:     "The Left Hand of Darkness",
+	Subtitle:  "50th Anniversary Edition",
+	Authors:   []Name{leGuin},
+	Publisher: "Ace",
+	Year:      1969,
+	ISBN:      "9780441478125",
+}
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		full string
+		want Name
+	}{
+		{full: "Ursula K. Le Guin", want: Name{Given: "Ursula K.", Family: "Le Guin"}},
+		{full: "Neil Gaiman", want: Name{Given: "Neil", Family: "Gaiman"}},
+		{full: "Le Guin, Ursula K.", want: Name{Given: "Ursula K.", Family: "Le Guin"}},
+		{full: "Ludwig van Beethoven", want: Name{Given: "Ludwig", Family: "van Beethoven"}},
+		{full: "Johannes Diderik van der Waals", want: Name{Given: "Johannes Diderik", Family: "van der Waals"}},
+		{full: "  Homer ", want: Name{Family: "Homer"}},
+		{full: "", want: Name{}},
+	}
+	for _, tt := range tests {
+		if got := ParseName(tt.full); got != tt.want {
+			t.Errorf("ParseName(%q) = %+v, want %+v", tt.full, got, tt.want)
+		}
+	}
+}
+
+func TestInitials(t *testing.T) {
+	tests := []struct {
+		given string
+		want  string
+	}{
+		{given: "Ursula K.", want: "U. K."},
+		{given: "Jean-Paul", want: "J.-P."},
+		{given: "Émile", want: "É."},
+		{given: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := initials(tt.given); got != tt.want {
+			t.Errorf("initials(%q) = %q, want %q", tt.given, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	gaiman := Name{Given: "Neil", Family: "Gaiman"}
+	pratchett := Name{Given: "Terry", Family: "Pratchett"}
+	goodOmens := Entry{Title: "Good Omens", Authors: []Name{pratchett, gaiman}, Publisher: "Gollancz", Year: 1990}
+	anonymous := Entry{ID: "x", Title: "Beowulf?"}
+
+	tests := []struct {
+		name    string
+		format  string
+		entries []Entry
+		want    string
+	}{
+		{
+			name:    "bibtex",
+			format:  FormatBibTeX,
+			entries: []Entry{leftHand},
+			want: "@book{leguin1969left,\n" +
+				"  author = {Le Guin, Ursula K.},\n" +
+				"  title = {The Left Hand of Darkness},\n" +
+				"  subtitle = {50th Anniversary Edition},\n" +
+				"  publisher = {Ace},\n" +
+				"  year = {1969},\n" +
+				"  isbn = {9780441478125},\n" +
+				"}\n",
+		},
+		{
+			name:    "bibtex keys are unique and values escaped",
+			format:  FormatBibTeX,
+			entries: []Entry{{Title: "Tea & Sympathy", Year: 2000}, {Title: "Tea & Sympathy", Year: 2000}},
+			want: "@book{book2000tea,\n  title = {Tea \\& Sympathy},\n  year = {2000},\n}\n" +
+				"\n@book{book2000teab,\n  title = {Tea \\& Sympathy},\n  year = {2000},\n}\n",
+		},
+		{
+			name:    "ris",
+			format:  FormatRIS,
+			entries: []Entry{leftHand},
+			want: "TY  - BOOK\r\n" +
+				"AU  - Le Guin, Ursula K.\r\n" +
+				"TI  - The Left Hand of Darkness: 50th Anniversary Edition\r\n" +
+				"PB  - Ace\r\n" +
+				"PY  - 1969\r\n" +
+				"SN  - 9780441478125\r\n" +
+				"ID  - 64b000000000000000000001\r\n" +
+				"ER  - \r\n",
+		},
+		{
+			name:    "csl-json",
+			format:  FormatCSLJSON,
+			entries: []Entry{anonymous},
+			want:    "[\n  {\n    \"id\": \"x\",\n    \"type\": \"book\",\n    \"title\": \"Beowulf?\"\n  }\n]\n",
+		},
+		{
+			name:    "apa",
+			format:  FormatAPA,
+			entries: []Entry{leftHand, goodOmens, anonymous},
+			want: "Le Guin, U. K. (1969). The Left Hand of Darkness: 50th Anniversary Edition. Ace.\n" +
+				"Pratchett, T., & Gaiman, N. (1990). Good Omens. Gollancz.\n" +
+				"(n.d.). Beowulf?\n",
+		},
+		{
+			name:    "mla",
+			format:  FormatMLA,
+			entries: []Entry{leftHand, goodOmens, {Title: "Omnibus", Authors: []Name{pratchett, gaiman, leGuin}}, anonymous},
+			want: "Le Guin, Ursula K. The Left Hand of Darkness: 50th Anniversary Edition. Ace, 1969.\n" +
+				"Pratchett, Terry, and Neil Gaiman. Good Omens. Gollancz, 1990.\n" +
+				"Pratchett, Terry, et al. Omnibus.\n" +
+				"Beowulf?\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(tt.format, tt.entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Format(%s) =\n%q\nwant\n%q", tt.format, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Format("chicago", []Entry{leftHand}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestAPAListsAtMostTwentyAuthors(t *testing.T) {
+	entry := Entry{Title: "Proceedings", Year: 2020}
+	for i := 0; i < 25; i++ {
+		entry.Authors = append(entry.Authors, Name{Given: "Ada", Family: string(rune('A' + i))})
+	}
+
+	want := "A, A., B, A., C, A., D, A., E, A., F, A., G, A., H, A., I, A., J, A., K, A., L, A., M, A., " +
+		"N, A., O, A., P, A., Q, A., R, A., S, A., . . . Y, A. (2020). Proceedings."
+	if got := apa(entry); got != want {
+		t.Errorf("apa =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"example/books-api/controller"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// run a subcommand such as `books-api backup -o catalog.ndjson.gz`,
+// returns false when the arguments do not name one and the server should start
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "backup":
+		err = backupCommand(args[1:])
+	case "restore":
+		err = restoreCommand(args[1:])
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "books-api "+args[0]+":", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+func backupCommand(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := flags.String("o", "", "archive file to write, standard output if empty")
+	flags.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	manifest, counts, err := controller.Backup(w)
+	if err != nil {
+		return err
+	}
+	for _, name := range manifest.Collections {
+		fmt.Fprintf(os.Stderr, "%s: %d documents\n", name, counts[name])
+	}
+	return nil
+}
+
+func restoreCommand(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	strategy := flags.String("strategy", controller.RestoreFail, "what to do with documents that already exist: skip, overwrite or fail")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: books-api restore [-strategy skip|overwrite|fail] archive.ndjson.gz")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || !controller.IsRestoreStrategy(*strategy) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	report, err := controller.Restore(file, *strategy, "cli")
+	for name, counts := range report.Collections {
+		fmt.Fprintf(os.Stderr, "%s: %d restored, %d overwritten, %d skipped\n", name, counts.Restored, counts.Overwritten, counts.Skipped)
+	}
+	return err
+}
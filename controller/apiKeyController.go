@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/auth"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	apiKeyPrefix       = "bk_"
+	apiKeyPrefixLength = 11
+)
+
+var apiKeyCollection *mongo.Collection
+
+var errAPIKeyNotFound = errors.New("api key not found")
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		apiKeyCollection = db.Collection("apiKeys")
+
+		ensureIndexes(apiKeyCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "keyHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+
+		fmt.Println("API key collection istance is ready")
+		return nil
+	})
+}
+
+func newAPIKey() string {
+	return apiKeyPrefix + auth.RandomToken()
+}
+
+// insert an API key, only its hash is stored
+func createAPIKey(request model.APIKeyRequest, libraryID primitive.ObjectID, req requestInfo) (model.APIKeyWithSecret, error) {
+	key := newAPIKey()
+	apiKey := model.APIKey{
+		Name:      request.Name,
+		Prefix:    key[:apiKeyPrefixLength],
+		KeyHash:   auth.HashToken(key),
+		Scopes:    request.Scopes,
+		ExpiresAt: request.ExpiresAt,
+		LibraryID: libraryID,
+	}
+	stampCreated(&apiKey.Timestamps, req.Actor)
+
+	inserted, err := apiKeyCollection.InsertOne(context.Background(), apiKey)
+	if err != nil {
+		return model.APIKeyWithSecret{}, err
+	}
+
+	apiKey.ID = inserted.InsertedID.(primitive.ObjectID)
+	return model.APIKeyWithSecret{APIKey: apiKey, Key: key}, nil
+}
+
+func getAPIKeys() ([]model.APIKey, error) {
+	keys := []model.APIKey{}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := apiKeyCollection.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		return keys, err
+	}
+
+	err = cursor.All(context.Background(), &keys)
+	return keys, err
+}
+
+// replace the secret of an active key, the old secret stops working immediately
+func rotateAPIKey(keyID primitive.ObjectID, req requestInfo) (model.APIKeyWithSecret, error) {
+	var apiKey model.APIKey
+
+	key := newAPIKey()
+	filter := bson.M{"_id": keyID, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": stampUpdated(bson.M{
+		"prefix":  key[:apiKeyPrefixLength],
+		"keyHash": auth.HashToken(key),
+	}, req.Actor)}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := apiKeyCollection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&apiKey)
+	if err == mongo.ErrNoDocuments {
+		return model.APIKeyWithSecret{}, errAPIKeyNotFound
+	}
+
+	return model.APIKeyWithSecret{APIKey: apiKey, Key: key}, err
+}
+
+func revokeAPIKey(keyID primitive.ObjectID, req requestInfo) error {
+	filter := bson.M{"_id": keyID, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": stampUpdated(bson.M{"revokedAt": time.Now().UTC()}, req.Actor)}
+
+	result, err := apiKeyCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errAPIKeyNotFound
+	}
+	return nil
+}
+
+// VerifyAPIKey is used by the authentication middleware, it records the key as used
+func VerifyAPIKey(key string) (*auth.Claims, error) {
+	var apiKey model.APIKey
+
+	now := time.Now().UTC()
+	filter := bson.M{
+		"keyHash":   auth.HashToken(key),
+		"revokedAt": bson.M{"$exists": false},
+		"$or": []bson.M{
+			{"expiresAt": bson.M{"$exists": false}},
+			{"expiresAt": bson.M{"$gt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"lastUsedAt": now}}
+
+	err := apiKeyCollection.FindOneAndUpdate(context.Background(), filter, update).Decode(&apiKey)
+	if err == mongo.ErrNoDocuments {
+		return nil, auth.ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	scopes := apiKey.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	claims := &auth.Claims{
+		UserID:   apiKey.ID.Hex(),
+		Username: "apikey:" + apiKey.Name,
+		Scopes:   scopes,
+	}
+	if !apiKey.LibraryID.IsZero() {
+		claims.LibraryID = apiKey.LibraryID.Hex()
+	}
+	return claims, nil
+}
+
+func CreateAPIKey(c *gin.Context) {
+	var request model.APIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, scope := range request.Scopes {
+		if !auth.IsPermission(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope " + scope})
+			return
+		}
+	}
+	if request.ExpiresAt != nil && request.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"})
+		return
+	}
+
+	var libraryID primitive.ObjectID
+	if request.LibraryID != "" {
+		var err error
+		libraryID, err = primitive.ObjectIDFromHex(request.LibraryID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+			return
+		}
+		if _, err := getLibrary(libraryID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+			return
+		}
+	}
+
+	apiKey, err := createAPIKey(request, libraryID, requestFrom(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating API key"})
+		return
+	}
+	c.JSON(http.StatusCreated, apiKey)
+}
+
+func GetAPIKeys(c *gin.Context) {
+	keys, err := getAPIKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func RotateAPIKey(c *gin.Context) {
+	keyID, err := primitive.ObjectIDFromHex(c.Param("keyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	apiKey, err := rotateAPIKey(keyID, requestFrom(c))
+	if err == errAPIKeyNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rotating API key"})
+		return
+	}
+	c.JSON(http.StatusOK, apiKey)
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	keyID, err := primitive.ObjectIDFromHex(c.Param("keyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	err = revokeAPIKey(keyID, requestFrom(c))
+	if err == errAPIKeyNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking API key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Revoked"})
+}
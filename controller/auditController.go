@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"example/books-api/middleware"
+	"example/books-api/model"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	auditEntityAuthor       = "author"
+	auditEntityBook         = "book"
+	auditEntityBookAuthor   = "bookAuthor"
+	auditEntitySeries       = "series"
+	auditEntityGenre        = "genre"
+	auditEntityPublisher    = "publisher"
+	auditEntityWork         = "work"
+	auditEntityReadingState = "readingState"
+	auditEntityPersonalTag  = "personalTag"
+
+	auditActionCreate = "create"
+	auditActionUpdate = "update"
+	auditActionDelete = "delete"
+
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+var auditCollection *mongo.Collection
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		auditCollection = db.Collection("auditLog")
+
+		fmt.Println("Audit collection istance is ready")
+		return nil
+	})
+}
+
+// append an audit entry, before and after are the full documents (nil on create/delete)
+func recordAudit(req requestInfo, entity string, action string, id primitive.ObjectID, before bson.M, after bson.M) {
+	entry := model.AuditEntry{
+		LibraryID: req.LibraryID,
+		Entity:    entity,
+		EntityID:  id,
+		Action:    action,
+		Actor:     req.Actor,
+		RequestID: req.RequestID,
+		Timestamp: time.Now().UTC(),
+		Before:    before,
+		After:     after,
+		Changes:   diffDocuments(before, after),
+	}
+
+	if _, err := auditCollection.InsertOne(context.Background(), entry); err != nil {
+		log.Println("Error writing audit entry: ", err)
+	}
+
+	// Every mutation is audited, so this is also where cached reads go stale
+	invalidateCached(req.LibraryID, entity, id, before, after)
+}
+
+// fields that differ between two documents
+func diffDocuments(before bson.M, after bson.M) map[string]model.AuditChange {
+	if before == nil || after == nil {
+		return nil
+	}
+
+	changes := map[string]model.AuditChange{}
+	for key, from := range before {
+		if to, ok := after[key]; !ok || !reflect.DeepEqual(from, to) {
+			changes[key] = model.AuditChange{From: from, To: after[key]}
+		}
+	}
+	for key, to := range after {
+		if _, ok := before[key]; !ok {
+			changes[key] = model.AuditChange{From: nil, To: to}
+		}
+	}
+
+	return changes
+}
+
+// load a raw document for an audit snapshot, nil if it does not exist
+func snapshot(col *mongo.Collection, id primitive.ObjectID) bson.M {
+	var doc bson.M
+	if err := col.FindOne(context.Background(), bson.M{"_id": id}).Decode(&doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+// load raw documents matching a filter for audit snapshots
+func snapshots(col *mongo.Collection, filter bson.M) []bson.M {
+	var docs []bson.M
+	cursor, err := col.Find(context.Background(), filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cursor.All(context.Background(), &docs); err != nil {
+		log.Fatal(err)
+	}
+	return docs
+}
+
+// get audit entries matching the filter, newest first
+func getAuditEntries(filter bson.M, limit int64) ([]model.AuditEntry, error) {
+	entries := []model.AuditEntry{}
+
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(limit)
+	cursor, err := auditCollection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return entries, err
+	}
+
+	err = cursor.All(context.Background(), &entries)
+	return entries, err
+}
+
+func GetAuditEntries(c *gin.Context) {
+	filter := bson.M{}
+	for _, key := range []string{"entity", "action", "actor", "requestId"} {
+		if value := c.Query(key); value != "" {
+			filter[key] = value
+		}
+	}
+
+	// Members of a library only see its entries, admins without one may pick any library
+	if claims := middleware.ClaimsFrom(c); claims != nil && claims.LibraryID != "" {
+		id, err := primitive.ObjectIDFromHex(claims.LibraryID)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid library membership"})
+			return
+		}
+		filter["libraryId"] = id
+	} else if libraryId := c.Query("libraryId"); libraryId != "" {
+		id, err := primitive.ObjectIDFromHex(libraryId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+			return
+		}
+		filter["libraryId"] = id
+	}
+
+	if entityId := c.Query("entityId"); entityId != "" {
+		id, err := primitive.ObjectIDFromHex(entityId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entity ID"})
+			return
+		}
+		filter["entityId"] = id
+	}
+
+	timeRange := bson.M{}
+	for param, op := range map[string]string{"from": "$gte", "to": "$lt"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ": expected RFC3339 time"})
+			return
+		}
+		timeRange[op] = t
+	}
+	if len(timeRange) > 0 {
+		filter["timestamp"] = timeRange
+	}
+
+	limit := int64(defaultAuditLimit)
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid limit: expected 1-%d", maxAuditLimit)})
+			return
+		}
+		limit = parsed
+	}
+
+	entries, err := getAuditEntries(filter, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
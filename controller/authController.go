@@ -0,0 +1,363 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/auth"
+	"example/books-api/middleware"
+	"example/books-api/model"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var userCollection *mongo.Collection
+var refreshTokenCollection *mongo.Collection
+var revokedTokenCollection *mongo.Collection
+var bootstrapCollection *mongo.Collection
+
+// _id of the marker the first account claims to become admin
+const firstAdminMarker = "firstAdmin"
+
+var errInvalidCredentials = errors.New("invalid username or password")
+var errUsernameTaken = errors.New("username already taken")
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		if os.Getenv("JWT_SECRET") == "" {
+			return errors.New("JWT_SECRET is not set")
+		}
+
+		userCollection = db.Collection("users")
+		refreshTokenCollection = db.Collection("refreshTokens")
+		revokedTokenCollection = db.Collection("revokedTokens")
+		bootstrapCollection = db.Collection("bootstrap")
+
+		ensureIndexes(userCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		ensureIndexes(refreshTokenCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		ensureIndexes(revokedTokenCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "tokenId", Value: 1}},
+		}, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+
+		fmt.Println("User collection istance is ready")
+		return nil
+	})
+}
+
+func ensureIndexes(col *mongo.Collection, indexes ...mongo.IndexModel) {
+	if _, err := col.Indexes().CreateMany(context.Background(), indexes); err != nil {
+		log.Println("Error creating indexes on "+col.Name()+": ", err)
+	}
+}
+
+// insert user with a bcrypt hash of the password
+func registerUser(credentials model.Credentials, req requestInfo) (model.User, error) {
+	var user model.User
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return user, err
+	}
+
+	user.Username = credentials.Username
+	user.PasswordHash = string(hash)
+	user.Role = auth.RoleViewer
+
+	// The first account bootstraps the instance and can promote everyone else
+	firstAdmin, err := claimFirstAdmin(req)
+	if err != nil {
+		return user, err
+	}
+	if firstAdmin {
+		user.Role = auth.RoleAdmin
+	}
+	stampCreated(&user.Timestamps, req.Actor)
+
+	inserted, err := userCollection.InsertOne(context.Background(), user)
+	if err != nil && firstAdmin {
+		// Let the next registration become admin instead
+		bootstrapCollection.DeleteOne(context.Background(), bson.M{"_id": firstAdminMarker})
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return user, errUsernameTaken
+	} else if err != nil {
+		return user, err
+	}
+
+	user.ID = inserted.InsertedID.(primitive.ObjectID)
+	return user, nil
+}
+
+// whether this registration is the one that makes the first admin. Registrations racing
+// on an empty instance all try to insert the same marker and only one of them succeeds;
+// once there are users nobody claims it, so existing deployments never get a new admin
+func claimFirstAdmin(req requestInfo) (bool, error) {
+	count, err := userCollection.CountDocuments(context.Background(), bson.M{}, options.Count().SetLimit(1))
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	marker := bson.M{"_id": firstAdminMarker, "claimedAt": time.Now().UTC(), "requestId": req.RequestID}
+	_, err = bootstrapCollection.InsertOne(context.Background(), marker)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// check the password and return the user
+func authenticateUser(credentials model.Credentials) (model.User, error) {
+	var user model.User
+
+	err := userCollection.FindOne(context.Background(), bson.M{"username": credentials.Username}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, errInvalidCredentials
+	} else if err != nil {
+		return user, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(credentials.Password)) != nil {
+		return user, errInvalidCredentials
+	}
+
+	return user, nil
+}
+
+func getUserByID(userID primitive.ObjectID) (model.User, error) {
+	var user model.User
+	err := userCollection.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user)
+	return user, err
+}
+
+// change a user's role, takes effect on their next login or refresh
+func setUserRole(userID primitive.ObjectID, role string, req requestInfo) (model.User, error) {
+	var user model.User
+
+	filter := bson.M{"_id": userID}
+	update := bson.M{"$set": stampUpdated(bson.M{"role": role}, req.Actor)}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := userCollection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&user)
+	return user, err
+}
+
+// issue an access token and a stored refresh token for the user
+func issueTokens(user model.User) (model.TokenPair, error) {
+	var pair model.TokenPair
+
+	libraryID := ""
+	if !user.LibraryID.IsZero() {
+		libraryID = user.LibraryID.Hex()
+	}
+
+	accessToken, claims, err := auth.NewAccessToken(user.ID.Hex(), user.Username, user.Role, libraryID)
+	if err != nil {
+		return pair, err
+	}
+
+	refreshToken := auth.RandomToken()
+	now := time.Now().UTC()
+	stored := model.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(refreshToken),
+		ExpiresAt: now.Add(auth.RefreshTokenTTL()),
+		CreatedAt: now,
+	}
+	if _, err := refreshTokenCollection.InsertOne(context.Background(), stored); err != nil {
+		return pair, err
+	}
+
+	pair.AccessToken = accessToken
+	pair.RefreshToken = refreshToken
+	pair.TokenType = "Bearer"
+	pair.ExpiresAt = claims.ExpiresAt.Time
+	return pair, nil
+}
+
+// revoke a refresh token and return the user it belonged to
+func revokeRefreshToken(refreshToken string) (primitive.ObjectID, error) {
+	var stored model.RefreshToken
+
+	now := time.Now().UTC()
+	filter := bson.M{
+		"tokenHash": auth.HashToken(refreshToken),
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"revokedAt": now}}
+
+	err := refreshTokenCollection.FindOneAndUpdate(context.Background(), filter, update).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return stored.UserID, auth.ErrInvalidToken
+	}
+
+	return stored.UserID, err
+}
+
+// deny an access token until it would have expired anyway
+func revokeAccessToken(claims *auth.Claims) error {
+	revoked := model.RevokedToken{TokenID: claims.ID, ExpiresAt: claims.ExpiresAt.Time}
+	_, err := revokedTokenCollection.InsertOne(context.Background(), revoked)
+	return err
+}
+
+// IsTokenRevoked is used by the authentication middleware
+func IsTokenRevoked(tokenID string) bool {
+	count, err := revokedTokenCollection.CountDocuments(context.Background(), bson.M{"tokenId": tokenID})
+	if err != nil {
+		log.Println("Error checking revoked tokens: ", err)
+		return true
+	}
+	return count > 0
+}
+
+func Register(c *gin.Context) {
+	var credentials model.Credentials
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := registerUser(credentials, requestFrom(c))
+	if err == errUsernameTaken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+func SetUserRole(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var body model.RoleChange
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !auth.IsRole(body.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+		return
+	}
+
+	user, err := setUserRole(userID, body.Role, requestFrom(c))
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating user"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func Login(c *gin.Context) {
+	var credentials model.Credentials
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := authenticateUser(credentials)
+	if err == errInvalidCredentials {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error logging in"})
+		return
+	}
+
+	pair, err := issueTokens(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error issuing tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// Refresh rotates the refresh token: the old one is revoked and a new pair is issued
+func Refresh(c *gin.Context) {
+	var body refreshRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := revokeRefreshToken(body.RefreshToken)
+	if err == auth.ErrInvalidToken {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error refreshing token"})
+		return
+	}
+
+	user, err := getUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+		return
+	}
+
+	pair, err := issueTokens(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error issuing tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout revokes the given refresh token and, if present, the access token used for the call
+func Logout(c *gin.Context) {
+	var body refreshRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := revokeRefreshToken(body.RefreshToken); err != nil && err != auth.ErrInvalidToken {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+		return
+	}
+
+	if token, ok := middleware.BearerToken(c); ok {
+		if claims, err := auth.ParseAccessToken(token); err == nil {
+			if err := revokeAccessToken(claims); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Logged out"})
+}
@@ -9,7 +9,6 @@ import (
 	"os"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -21,107 +20,209 @@ var bookAuthorCollection *mongo.Collection
 var bookListCollection *mongo.Collection
 
 func init() {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-	connectionString := os.Getenv("CONNECTION_STRING")
-	clientOptions := options.Client().ApplyURI(connectionString)
-
-	client, error := mongo.Connect(context.TODO(), clientOptions)
-
-	if error != nil {
-		log.Fatal(error)
-	}
-
-	fmt.Println("Mongodb connection success")
-
-	dbName := os.Getenv("DBNAME")
-    colName := os.Getenv("COLNAME")
-    colName2 := os.Getenv("COLNAME2")
-    colName3 := os.Getenv("COLNAME3")
-
-	collection = client.Database(dbName).Collection(colName)
-    bookListCollection = client.Database(dbName).Collection(colName2)
-    bookAuthorCollection = client.Database(dbName).Collection(colName3)
-
-	fmt.Println("Collection istance is ready")
+	onSetup(func(db *mongo.Database) error {
+		colName := os.Getenv("COLNAME")
+		colName2 := os.Getenv("COLNAME2")
+		colName3 := os.Getenv("COLNAME3")
+
+		collection = db.Collection(colName)
+		bookListCollection = db.Collection(colName2)
+		bookAuthorCollection = db.Collection(colName3)
+
+		ensureIndexes(collection,
+			mongo.IndexModel{Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "name", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "aliases", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "nationality", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "birthDate", Value: 1}}},
+		)
+
+		// External IDs are unique per library, authors without one are left out of the index
+		for field := range authorIdentifiers(model.Author{}) {
+			ensureIndexes(collection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "libraryId", Value: 1}, {Key: field, Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{field: bson.M{"$gt": ""}}),
+			})
+		}
+
+		fmt.Println("Collection istance is ready")
+		return nil
+	})
 }
 
 // insert author
-func insertAuthor(author model.Author) {
+func insertAuthor(author *model.Author, req requestInfo) error {
+	stampCreated(&author.Timestamps, req.Actor)
+	author.LibraryID = req.LibraryID
 	inserted, err := collection.InsertOne(context.Background(), author)
 
-	if err != nil {
-		log.Fatal(err)
+	// The unique identifier indexes catch authors inserted since the identifier check
+	if mongo.IsDuplicateKeyError(err) {
+		return errAuthorIdentifierTaken
+	} else if err != nil {
+		return err
 	}
 
 	fmt.Println("Inserted a single document: ", inserted.InsertedID)
+
+	author.ID = inserted.InsertedID.(primitive.ObjectID)
+	recordAudit(req, auditEntityAuthor, auditActionCreate, author.ID, nil, snapshot(collection, author.ID))
+	return nil
 }
 
 // update author
-func updateAuthor(authorId string, author model.Author) {
+func updateAuthor(authorId string, author model.Author, req requestInfo) error {
     id, _ := primitive.ObjectIDFromHex(authorId)
-    filter := bson.M{"_id": id}
-    update := bson.M{"$set": bson.M{"name": author.Name}} 
+    filter := scoped(req.LibraryID, bson.M{"_id": id})
+    update := authorUpdateDocument(author, req.Actor)
+    revision := captureRevision(authorRevisionTarget(), id, req)
+    before := snapshot(collection, id)
 
     result, err := collection.UpdateOne(context.Background(), filter, update)
 
-    if err != nil {
-        log.Fatal(err)
+    if mongo.IsDuplicateKeyError(err) {
+        return errAuthorIdentifierTaken
+    } else if err != nil {
+        return err
     }
 
     fmt.Println("Updated a single document: ", result.UpsertedID)
+
+    if result.MatchedCount == 0 {
+        return nil
+    }
+    // Stored once the update succeeded so a failed one leaves no revision behind
+    if err := storeRevision(context.Background(), revision); err != nil {
+        return err
+    }
+    recordAudit(req, auditEntityAuthor, auditActionUpdate, id, before, snapshot(collection, id))
+    return nil
 }
 
-// delete author
-func deleteAuthor(authorId string) {
-    id, _ := primitive.ObjectIDFromHex(authorId)
-    filter := bson.M{"_id": id}
+// what deleting an author takes with it: the author's books, every link of those books
+// (including the co-authors'), the books in the co-authors' lists, the readers' states
+// and personal tags of the books and the author's work credits
+type authorCascade struct {
+    bookIDs   []primitive.ObjectID
+    links     []bson.M
+    books     []bson.M
+    coAuthors []bson.M
+    states    []bson.M
+    tags      []bson.M
+    works     []bson.M
+}
 
-    // Get the author's books from the bookAuthor table
-    bookAuthorFilter := bson.M{"author": id}
-    cursor, err := bookAuthorCollection.Find(context.Background(), bookAuthorFilter)
-    if err != nil {
-        log.Fatal(err)
+// snapshot everything the cascade removes so the audit log can answer who deleted it
+func planAuthorCascade(id primitive.ObjectID, libraryID primitive.ObjectID) authorCascade {
+    var cascade authorCascade
+    for _, link := range snapshots(bookAuthorCollection, scoped(libraryID, bson.M{"author": id})) {
+        if bookID, ok := link["book"].(primitive.ObjectID); ok && !containsID(cascade.bookIDs, bookID) {
+            cascade.bookIDs = append(cascade.bookIDs, bookID)
+        }
     }
 
-    var bookIds []primitive.ObjectID
-    for cursor.Next(context.Background()) {
-        var bookAuthor model.BookAuthor
-        cursor.Decode(&bookAuthor)
-        bookIds = append(bookIds, bookAuthor.Book)
+    linkFilter := bson.M{"author": id}
+    if len(cascade.bookIDs) > 0 {
+        linkFilter = bson.M{"$or": []bson.M{{"author": id}, {"book": bson.M{"$in": cascade.bookIDs}}}}
+        cascade.books = snapshots(bookListCollection, scoped(libraryID, bson.M{"_id": bson.M{"$in": cascade.bookIDs}}))
+        cascade.coAuthors = snapshots(collection, scoped(libraryID, bson.M{"_id": bson.M{"$ne": id}, "books": bson.M{"$in": cascade.bookIDs}}))
+        cascade.states = snapshots(readingStateCollection, scoped(libraryID, bson.M{"book": bson.M{"$in": cascade.bookIDs}}))
+        cascade.tags = snapshots(personalTagCollection, scoped(libraryID, bson.M{"book": bson.M{"$in": cascade.bookIDs}}))
+    }
+    cascade.links = snapshots(bookAuthorCollection, scoped(libraryID, linkFilter))
+    cascade.works = snapshots(workCollection, scoped(libraryID, bson.M{"contributors.author": id}))
+    return cascade
+}
+
+// remove what the cascade covers, ctx carries the transaction of a bulk delete
+func (cascade authorCascade) apply(ctx context.Context, id primitive.ObjectID, req requestInfo) error {
+    if len(cascade.links) > 0 {
+        var linkIDs []interface{}
+        for _, link := range cascade.links {
+            linkIDs = append(linkIDs, link["_id"])
+        }
+        if _, err := bookAuthorCollection.DeleteMany(ctx, scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": linkIDs}})); err != nil {
+            return err
+        }
     }
 
-    result, err := collection.DeleteOne(context.Background(), filter)
-    if err != nil {
-        log.Fatal(err)
+    if len(cascade.bookIDs) > 0 {
+        bookFilter := scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": cascade.bookIDs}})
+        if _, err := bookListCollection.DeleteMany(ctx, bookFilter); err != nil {
+            return err
+        }
+
+        pull := bson.M{"$pull": bson.M{"books": bson.M{"$in": cascade.bookIDs}}, "$set": stampUpdated(bson.M{}, req.Actor)}
+        if _, err := collection.UpdateMany(ctx, scoped(req.LibraryID, bson.M{"books": bson.M{"$in": cascade.bookIDs}}), pull); err != nil {
+            return err
+        }
+
+        if _, err := readingStateCollection.DeleteMany(ctx, scoped(req.LibraryID, bson.M{"book": bson.M{"$in": cascade.bookIDs}})); err != nil {
+            return err
+        }
+        if _, err := personalTagCollection.DeleteMany(ctx, scoped(req.LibraryID, bson.M{"book": bson.M{"$in": cascade.bookIDs}})); err != nil {
+            return err
+        }
     }
-    fmt.Println("Deleted a single document from authors: ", result.DeletedCount)
 
-    bookAuthorResult, err := bookAuthorCollection.DeleteMany(context.Background(), bookAuthorFilter)
-    if err != nil {
-        log.Fatal(err)
+    // Works keep their own contributors
+    pull := bson.M{"$pull": bson.M{"contributors": bson.M{"author": id}}, "$set": stampUpdated(bson.M{}, req.Actor)}
+    if _, err := workCollection.UpdateMany(ctx, scoped(req.LibraryID, bson.M{"contributors.author": id}), pull); err != nil {
+        return err
     }
-    fmt.Println("Deleted documents from bookAuthor: ", bookAuthorResult.DeletedCount)
+    return nil
+}
 
-    readListFilter := bson.M{"book": bson.M{"$in": bookIds}}
-    readListResult, err := collection.DeleteMany(context.Background(), readListFilter)
-    if err != nil {
-        log.Fatal(err)
+func (cascade authorCascade) record(req requestInfo) {
+    for _, link := range cascade.links {
+        recordAudit(req, auditEntityBookAuthor, auditActionDelete, link["_id"].(primitive.ObjectID), link, nil)
     }
-    fmt.Println("Deleted documents from readList: ", readListResult.DeletedCount)
+    for _, book := range cascade.books {
+        recordAudit(req, auditEntityBook, auditActionDelete, book["_id"].(primitive.ObjectID), book, nil)
+    }
+    for _, coAuthor := range cascade.coAuthors {
+        coAuthorID := coAuthor["_id"].(primitive.ObjectID)
+        recordAudit(req, auditEntityAuthor, auditActionUpdate, coAuthorID, coAuthor, snapshot(collection, coAuthorID))
+    }
+    for _, state := range cascade.states {
+        recordAudit(req, auditEntityReadingState, auditActionDelete, state["_id"].(primitive.ObjectID), state, nil)
+    }
+    for _, tag := range cascade.tags {
+        recordAudit(req, auditEntityPersonalTag, auditActionDelete, tag["_id"].(primitive.ObjectID), tag, nil)
+    }
+    for _, work := range cascade.works {
+        workID := work["_id"].(primitive.ObjectID)
+        recordAudit(req, auditEntityWork, auditActionUpdate, workID, work, snapshot(workCollection, workID))
+    }
+}
+
+// delete author
+func deleteAuthor(authorId string, req requestInfo) error {
+    id, _ := primitive.ObjectIDFromHex(authorId)
+    filter := scoped(req.LibraryID, bson.M{"_id": id})
+
+    authorBefore := snapshot(collection, id)
+    cascade := planAuthorCascade(id, req.LibraryID)
 
-    bookFilter := bson.M{"_id": bson.M{"$in": bookIds}}
-    bookResult, err := bookListCollection.DeleteMany(context.Background(), bookFilter)
+    result, err := collection.DeleteOne(context.Background(), filter)
     if err != nil {
-        log.Fatal(err)
+        return err
+    }
+    fmt.Println("Deleted a single document from authors: ", result.DeletedCount)
+    if result.DeletedCount == 0 {
+        return nil
+    }
+    recordAudit(req, auditEntityAuthor, auditActionDelete, id, authorBefore, nil)
+
+    if err := cascade.apply(context.Background(), id, req); err != nil {
+        return err
     }
-    fmt.Println("Deleted documents from bookList: ", bookResult.DeletedCount)
+    fmt.Println("Deleted books of the author: ", len(cascade.bookIDs))
+    cascade.record(req)
+    return nil
 }
 
 // get author and return
-func getAuthor(authorID string) (model.AuthorWithBooks, error) {
+func getAuthor(authorID string, libraryID primitive.ObjectID) (model.AuthorWithBooks, error) {
     var authorWithBooks model.AuthorWithBooks
 
     id, err := primitive.ObjectIDFromHex(authorID)
@@ -130,31 +231,20 @@ func getAuthor(authorID string) (model.AuthorWithBooks, error) {
     }
 
     pipeline := []bson.M{
-        {"$match": bson.M{"_id": id}},
-        {"$lookup": bson.M{
-            "from":         "bookAuthor",
-            "localField":   "_id",
-            "foreignField": "author",
-            "as":           "authorBookRelations",
-        }},
-        {"$lookup": bson.M{
-            "from":         "bookList",
-            "localField":   "authorBookRelations.book",
-            "foreignField": "_id",
-            "as":           "books",
-        }},
-        {"$project": bson.M{
+        {"$match": scoped(libraryID, bson.M{"_id": id})},
+        scopedLookup("bookAuthor", "_id", "author", "authorBookRelations", libraryID),
+        scopedLookup("bookList", "authorBookRelations.book", "_id", "books", libraryID),
+        {"$project": authorProjection(bson.M{
             "_id":   1,
-            "name":  1,
             "books": bson.M{"$ifNull": []interface{}{
                 bson.M{"$map": bson.M{
                     "input": "$books",
                     "as":    "book",
-                    "in": bson.M{"title": "$$book.title"},
+                    "in": bson.M{"_id": "$$book._id", "title": "$$book.title"},
                 }},
                 []model.BookInfo{},
             }},
-        }},
+        })},
     }
 
     cursor, err := collection.Aggregate(context.Background(), pipeline)
@@ -170,34 +260,27 @@ func getAuthor(authorID string) (model.AuthorWithBooks, error) {
 }
 
 // get all authors and return
-func getAllAuthors() []model.AuthorWithBooks {
+func getAllAuthors(libraryID primitive.ObjectID, match bson.M, sort bson.D) []model.AuthorWithBooks {
     var authors []model.AuthorWithBooks
 
     pipeline := []bson.M{
-        {"$lookup": bson.M{
-            "from":         "bookAuthor",
-            "localField":   "_id",
-            "foreignField": "author",
-            "as":           "authorBookRelations",
-        }},
-        {"$lookup": bson.M{
-            "from":         "bookList",
-            "localField":   "authorBookRelations.book",
-            "foreignField": "_id",
-            "as":           "books",
-        }},
-        {"$project": bson.M{
+        {"$match": scoped(libraryID, match)},
+        scopedLookup("bookAuthor", "_id", "author", "authorBookRelations", libraryID),
+        scopedLookup("bookList", "authorBookRelations.book", "_id", "books", libraryID),
+        {"$project": authorProjection(bson.M{
             "_id":   1,
-            "name":  1,
             "books": bson.M{"$ifNull": []interface{}{
                 bson.M{"$map": bson.M{
                     "input": "$books",
                     "as":    "book",
-                    "in": bson.M{"title": "$$book.title"},
+                    "in": bson.M{"_id": "$$book._id", "title": "$$book.title"},
                 }},
                 []model.BookInfo{},
             }},
-        }},
+        })},
+    }
+    if len(sort) > 0 {
+        pipeline = append(pipeline, bson.M{"$sort": sort})
     }
 
     cursor, err := collection.Aggregate(context.Background(), pipeline)
@@ -218,18 +301,35 @@ func getAllAuthors() []model.AuthorWithBooks {
 }
 
 func GetAllAuthors(c *gin.Context) {
-    allAuthors := getAllAuthors()
-    c.JSON(http.StatusOK, allAuthors)
+    match, sort, err := authorListQuery(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if serveCached(c) {
+        return
+    }
+    libraryID := requestFrom(c).LibraryID
+    allAuthors := getAllAuthors(libraryID, match, sort)
+    respondCached(c, allAuthors, listTag(libraryID, auditEntityAuthor))
 }
 
 func GetAuthor(c *gin.Context) {
     authorId := c.Param("authorId")
-    author, err := getAuthor(authorId)
+    if serveCached(c) {
+        return
+    }
+    author, err := getAuthor(authorId, requestFrom(c).LibraryID)
     if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
         return
     }
-    c.JSON(http.StatusOK, author)
+    id, _ := primitive.ObjectIDFromHex(authorId)
+    tags := []string{entityTag(auditEntityAuthor, id)}
+    for _, book := range author.Books {
+        tags = append(tags, entityTag(auditEntityBook, book.ID))
+    }
+    respondCached(c, author, tags...)
 }
 
 func CreateAuthor(c *gin.Context) {
@@ -239,7 +339,32 @@ func CreateAuthor(c *gin.Context) {
         return
     }
     author.Books = []primitive.ObjectID{} 
-    insertAuthor(author)
+    if err := normalizeAuthorDetails(&author); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    req := requestFrom(c)
+    if err := checkAuthorIdentifiersAvailable(req.LibraryID, author, primitive.NilObjectID); err == errAuthorIdentifierTaken {
+        c.JSON(http.StatusConflict, gin.H{"error": "An author with this identifier already exists"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking author identifiers"})
+        return
+    }
+    if err := checkQuota(req.LibraryID, auditEntityAuthor, 1); err == errQuotaExceeded {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Author quota exceeded for this library"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking library quota"})
+        return
+    }
+    if err := insertAuthor(&author, req); err == errAuthorIdentifierTaken {
+        c.JSON(http.StatusConflict, gin.H{"error": "An author with this identifier already exists"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving author"})
+        return
+    }
     c.JSON(http.StatusOK, author)
 }
 
@@ -252,7 +377,26 @@ func UpdateAuthor(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    updateAuthor(authorId, author)
+    if err := normalizeAuthorDetails(&author); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    req := requestFrom(c)
+    id, _ := primitive.ObjectIDFromHex(authorId)
+    if err := checkAuthorIdentifiersAvailable(req.LibraryID, author, id); err == errAuthorIdentifierTaken {
+        c.JSON(http.StatusConflict, gin.H{"error": "An author with this identifier already exists"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking author identifiers"})
+        return
+    }
+    if err := updateAuthor(authorId, author, req); err == errAuthorIdentifierTaken {
+        c.JSON(http.StatusConflict, gin.H{"error": "An author with this identifier already exists"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving author"})
+        return
+    }
     c.JSON(http.StatusOK, gin.H{"status": "Updated"})
 }
 
@@ -260,6 +404,9 @@ func DeleteAuthor(c *gin.Context) {
 	c.Writer.Header().Set("Content-Type", "application/json")
 	c.Writer.Header().Set("Allow-Control-Allow-Methods", "DELETE")
 	authorId := c.Param("authorId")
-	deleteAuthor(authorId)
+	if err := deleteAuthor(authorId, requestFrom(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting author"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "Deleted"})
 }
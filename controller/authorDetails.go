@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/model"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errAuthorIdentifierTaken = errors.New("another author of this library has the same identifier")
+
+var (
+	isniPattern        = regexp.MustCompile(`^[0-9]{15}[0-9X]$`)
+	viafPattern        = regexp.MustCompile(`^[1-9][0-9]{0,21}$`)
+	openLibraryPattern = regexp.MustCompile(`^OL[1-9][0-9]*A$`)
+)
+
+// external identifier fields of an author by their bson name, unique per library
+func authorIdentifiers(author model.Author) map[string]string {
+	return map[string]string{
+		"isni":          author.ISNI,
+		"viaf":          author.VIAF,
+		"openLibraryId": author.OpenLibraryID,
+	}
+}
+
+// ISO 7064 MOD 11-2 check character over the first 15 digits of an ISNI
+func isniCheckCharacter(digits string) byte {
+	total := 0
+	for _, digit := range digits[:15] {
+		total = (total + int(digit-'0')) * 2
+	}
+	check := (12 - total%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// "0000 0001 2103 2683" and "https://isni.org/isni/0000000121032683" -> "0000000121032683"
+func normalizeISNI(value string) (string, error) {
+	normalized := strings.ToUpper(value[strings.LastIndex(value, "/")+1:])
+	normalized = strings.NewReplacer(" ", "", "-", "").Replace(normalized)
+	if !isniPattern.MatchString(normalized) || isniCheckCharacter(normalized) != normalized[15] {
+		return "", fmt.Errorf("invalid ISNI %q", value)
+	}
+	return normalized, nil
+}
+
+// "https://viaf.org/viaf/97006051" -> "97006051"
+func normalizeVIAF(value string) (string, error) {
+	normalized := strings.TrimSpace(value[strings.LastIndex(value, "/")+1:])
+	if !viafPattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid VIAF ID %q", value)
+	}
+	return normalized, nil
+}
+
+// "/authors/OL23919A" and "ol23919a" -> "OL23919A"
+func normalizeOpenLibraryID(value string) (string, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(value[strings.LastIndex(value, "/")+1:]))
+	if !openLibraryPattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid Open Library author ID %q", value)
+	}
+	return normalized, nil
+}
+
+// aliases trimmed, without duplicates and without the primary name
+func normalizeAliases(name string, aliases []string) []string {
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(name)): true}
+	var normalized []string
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if key := strings.ToLower(alias); alias != "" && !seen[key] {
+			seen[key] = true
+			normalized = append(normalized, alias)
+		}
+	}
+	return normalized
+}
+
+// check and normalize the descriptive fields of an author
+func normalizeAuthorDetails(author *model.Author) error {
+	author.Name = strings.TrimSpace(author.Name)
+	author.Nationality = strings.TrimSpace(author.Nationality)
+	author.Aliases = normalizeAliases(author.Name, author.Aliases)
+
+	for _, date := range []struct {
+		field string
+		value *string
+	}{{"birthDate", &author.BirthDate}, {"deathDate", &author.DeathDate}} {
+		if *date.value == "" {
+			continue
+		}
+		normalized, year, err := normalizePublishedDate(*date.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", date.field, *date.value)
+		}
+		if year > time.Now().Year() {
+			return fmt.Errorf("%s must not be in the future", date.field)
+		}
+		*date.value = normalized
+	}
+	// ISO dates of any precision compare as strings over their common precision
+	if author.BirthDate != "" && author.DeathDate != "" {
+		n := min(len(author.BirthDate), len(author.DeathDate))
+		if author.DeathDate[:n] < author.BirthDate[:n] {
+			return errors.New("deathDate must not be before birthDate")
+		}
+	}
+
+	for _, identifier := range []struct {
+		value     *string
+		normalize func(string) (string, error)
+	}{
+		{&author.ISNI, normalizeISNI},
+		{&author.VIAF, normalizeVIAF},
+		{&author.OpenLibraryID, normalizeOpenLibraryID},
+	} {
+		if *identifier.value == "" {
+			continue
+		}
+		normalized, err := identifier.normalize(*identifier.value)
+		if err != nil {
+			return err
+		}
+		*identifier.value = normalized
+	}
+	return nil
+}
+
+// errAuthorIdentifierTaken when another author of the library has one of the author's external IDs
+func checkAuthorIdentifiersAvailable(libraryID primitive.ObjectID, author model.Author, authorID primitive.ObjectID) error {
+	var conditions []bson.M
+	for field, value := range authorIdentifiers(author) {
+		if value != "" {
+			conditions = append(conditions, bson.M{field: value})
+		}
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	filter := scoped(libraryID, bson.M{"$or": conditions, "_id": bson.M{"$ne": authorID}})
+	err := collection.FindOne(context.Background(), filter).Err()
+	if err == mongo.ErrNoDocuments {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return errAuthorIdentifierTaken
+}
+
+// fields an author update replaces, missing identifiers are unset so they stay out of the unique indexes
+func authorUpdateDocument(author model.Author, actor string) bson.M {
+	set := bson.M{
+		"name":        author.Name,
+		"aliases":     author.Aliases,
+		"biography":   author.Biography,
+		"birthDate":   author.BirthDate,
+		"deathDate":   author.DeathDate,
+		"nationality": author.Nationality,
+	}
+	unset := bson.M{}
+	for field, value := range authorIdentifiers(author) {
+		if value == "" {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+	}
+
+	update := bson.M{"$set": stampUpdated(set, actor)}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeISNI(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "0000000121032683", want: "0000000121032683"},
+		{value: "0000 0001 2103 2683", want: "0000000121032683"},
+		{value: "0000-0001-2103-2683", want: "0000000121032683"},
+		{value: "https://isni.org/isni/0000000121032683", want: "0000000121032683"},
+		{value: "0000 0001 2281 955X", want: "000000012281955X"},
+		{value: "0000 0001 2281 955x", want: "000000012281955X"},
+		{value: "0000 0001 2103 2684", wantErr: true},
+		{value: "0000 0001 2281 9550", wantErr: true},
+		{value: "0000 0001 2103 268", wantErr: true},
+		{value: "000X 0001 2103 2683", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeISNI(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeISNI(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeISNI(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeExternalIDs(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize func(string) (string, error)
+		value     string
+		want      string
+		wantErr   bool
+	}{
+		{name: "viaf", normalize: normalizeVIAF, value: "97006051", want: "97006051"},
+		{name: "viaf url", normalize: normalizeVIAF, value: "https://viaf.org/viaf/97006051", want: "97006051"},
+		{name: "viaf leading zero", normalize: normalizeVIAF, value: "097006051", wantErr: true},
+		{name: "viaf letters", normalize: normalizeVIAF, value: "viaf97006051", wantErr: true},
+		{name: "open library", normalize: normalizeOpenLibraryID, value: "OL23919A", want: "OL23919A"},
+		{name: "open library path", normalize: normalizeOpenLibraryID, value: "/authors/ol23919a", want: "OL23919A"},
+		{name: "open library work", normalize: normalizeOpenLibraryID, value: "OL45804W", wantErr: true},
+		{name: "open library empty", normalize: normalizeOpenLibraryID, value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.normalize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: normalize(%q) error = %v, wantErr %v", tt.name, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: normalize(%q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeAliases(t *testing.T) {
+	got := normalizeAliases("Ursula K. Le Guin", []string{" Ursula Le Guin ", "", "ursula k. le guin", "URSULA LE GUIN", "U. K. Le Guin"})
+	if want := []string{"Ursula Le Guin", "U. K. Le Guin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeAliases = %q, want %q", got, want)
+	}
+}
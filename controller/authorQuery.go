@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// $match and $sort for /author/all, e.g.
+// ?name=twain&nationality=American,British&era=1850-1899
+func authorListQuery(c *gin.Context) (bson.M, bson.D, error) {
+	match, sort, err := timestampListQuery(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conditions []bson.M
+
+	// Pen names are found the same way as the primary name
+	if name := strings.TrimSpace(c.Query("name")); name != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(name), Options: "i"}
+		conditions = append(conditions, bson.M{"$or": []bson.M{{"name": pattern}, {"aliases": pattern}}})
+	}
+
+	if value := c.Query("nationality"); value != "" {
+		var nationalities []interface{}
+		for _, nationality := range strings.Split(value, ",") {
+			if nationality = strings.TrimSpace(nationality); nationality != "" {
+				nationalities = append(nationalities, exactNameFilter(nationality))
+			}
+		}
+		match["nationality"] = bson.M{"$in": nationalities}
+	}
+
+	if value := c.Query("era"); value != "" {
+		from, to, err := parseEra(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Authors whose lifetime overlaps the era, living authors are still active
+		if to != 0 {
+			conditions = append(conditions, bson.M{"birthDate": bson.M{"$lt": fmt.Sprintf("%04d", to+1)}})
+		}
+		if from != 0 {
+			conditions = append(conditions, bson.M{"$or": []bson.M{
+				{"deathDate": bson.M{"$gte": fmt.Sprintf("%04d", from)}},
+				{"deathDate": bson.M{"$in": []interface{}{nil, ""}}},
+			}})
+		}
+	}
+
+	if len(conditions) > 0 {
+		match["$and"] = conditions
+	}
+	return match, sort, nil
+}
+
+// "1850-1899", "1900-", "-1800" or a single year "1920"; 0 leaves that end open
+func parseEra(value string) (int, int, error) {
+	bounds := strings.SplitN(value, "-", 2)
+	if len(bounds) == 1 {
+		bounds = append(bounds, bounds[0])
+	}
+
+	var years [2]int
+	for i, bound := range bounds {
+		if bound = strings.TrimSpace(bound); bound == "" {
+			continue
+		}
+		year, err := strconv.Atoi(bound)
+		if err != nil || year <= 0 || year > 9999 {
+			return 0, 0, fmt.Errorf("invalid era %q: expected years like 1850-1899", value)
+		}
+		years[i] = year
+	}
+	if years[0] != 0 && years[1] != 0 && years[1] < years[0] {
+		return 0, 0, fmt.Errorf("invalid era %q: the end is before the start", value)
+	}
+	return years[0], years[1], nil
+}
+
+// author fields the detail and list aggregations pass through next to their books
+var authorDetailFields = []string{
+	"name", "aliases", "biography", "birthDate", "deathDate", "nationality", "isni", "viaf", "openLibraryId",
+	"createdAt", "updatedAt", "createdBy", "updatedBy",
+}
+
+// $project stage keeping every detail field, plus the given computed ones
+func authorProjection(fields bson.M) bson.M {
+	for _, field := range authorDetailFields {
+		fields[field] = 1
+	}
+	return fields
+}
@@ -0,0 +1,33 @@
+package controller
+
+import "testing"
+
+func TestParseEra(t *testing.T) {
+	tests := []struct {
+		value    string
+		from, to int
+		wantErr  bool
+	}{
+		{value: "1850-1899", from: 1850, to: 1899},
+		{value: " 1850 - 1899 ", from: 1850, to: 1899},
+		{value: "1900-", from: 1900},
+		{value: "-1800", to: 1800},
+		{value: "1920", from: 1920, to: 1920},
+		{value: "-", from: 0, to: 0},
+		{value: "1899-1850", wantErr: true},
+		{value: "0-1800", wantErr: true},
+		{value: "1850-10000", wantErr: true},
+		{value: "nineteenth century", wantErr: true},
+		{value: "1850-1899-1950", wantErr: true},
+	}
+	for _, tt := range tests {
+		from, to, err := parseEra(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseEra(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if from != tt.from || to != tt.to {
+			t.Errorf("parseEra(%q) = %d, %d, want %d, %d", tt.value, from, to, tt.from, tt.to)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/backup"
+	"example/books-api/model"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	RestoreSkip      = "skip"
+	RestoreOverwrite = "overwrite"
+	RestoreFail      = "fail"
+
+	restoreBatchSize = 500
+
+	// largest restore upload unless RESTORE_MAX_BYTES says otherwise
+	defaultRestoreMaxBytes = 1 << 30
+)
+
+var errRestoreConflict = errors.New("document already exists")
+
+// archive name, collection and audit entity of everything a backup holds;
+// sets without an entity are restored without audit entries, users and API keys
+// would otherwise copy password and key hashes into the audit log
+type backupSet struct {
+	name       string
+	collection *mongo.Collection
+	entity     string
+	// key fields of the unique indexes besides _id
+	unique [][]string
+	// fields holding the _id of a document from an earlier set
+	refs []string
+}
+
+// everything a working deployment needs: the libraries, their accounts and keys and
+// their catalogs; sets come after the sets they refer to, sessions, login states and
+// the audit log are left out
+func backupSets() []backupSet {
+	inLibrary := []string{"libraryId"}
+	return []backupSet{
+		{name: "libraries", collection: libraryCollection, unique: [][]string{{"slug"}}},
+		{name: "users", collection: userCollection, unique: [][]string{{"username"}, {"provider", "subject"}}, refs: inLibrary},
+		{name: "apiKeys", collection: apiKeyCollection, unique: [][]string{{"keyHash"}}, refs: inLibrary},
+		{name: "authors", collection: collection, entity: auditEntityAuthor, unique: authorUniqueKeys(), refs: inLibrary},
+		{name: "books", collection: bookCollection, entity: auditEntityBook, unique: [][]string{{"libraryId", "isbn13"}, {"libraryId", "isbn10"}}, refs: inLibrary},
+		{name: "bookAuthor", collection: bookAuthor, entity: auditEntityBookAuthor, refs: []string{"libraryId", "book", "author"}},
+		{name: "series", collection: seriesCollection, entity: auditEntitySeries, refs: inLibrary},
+		{name: "genres", collection: genreCollection, entity: auditEntityGenre, unique: [][]string{{"libraryId", "keys"}}, refs: inLibrary},
+		{name: "publishers", collection: publisherCollection, entity: auditEntityPublisher, refs: inLibrary},
+		{name: "works", collection: workCollection, entity: auditEntityWork, refs: inLibrary},
+		{name: "readingStates", collection: readingStateCollection, entity: auditEntityReadingState, unique: [][]string{{"libraryId", "actor", "book"}}, refs: []string{"libraryId", "book"}},
+		{name: "personalTags", collection: personalTagCollection, entity: auditEntityPersonalTag, unique: [][]string{{"libraryId", "owner", "name", "book"}}, refs: []string{"libraryId", "book"}},
+		{name: "revisions", collection: revisionCollection, unique: [][]string{{"entity", "entityId", "revision"}}, refs: []string{"libraryId", "entityId"}},
+	}
+}
+
+func authorUniqueKeys() [][]string {
+	var keys [][]string
+	for field := range authorIdentifiers(model.Author{}) {
+		keys = append(keys, []string{"libraryId", field})
+	}
+	return keys
+}
+
+func backupSetByName(name string) (backupSet, bool) {
+	for _, set := range backupSets() {
+		if set.name == name {
+			return set, true
+		}
+	}
+	return backupSet{}, false
+}
+
+func IsRestoreStrategy(strategy string) bool {
+	return strategy == RestoreSkip || strategy == RestoreOverwrite || strategy == RestoreFail
+}
+
+// Backup writes every library with its accounts and catalog to w
+func Backup(w io.Writer) (backup.Manifest, map[string]int64, error) {
+	sets := backupSets()
+	manifest := backup.Manifest{Database: collection.Database().Name()}
+	for _, set := range sets {
+		manifest.Collections = append(manifest.Collections, set.name)
+	}
+
+	archive, err := backup.NewWriter(w, manifest)
+	if err != nil {
+		return manifest, nil, err
+	}
+
+	for _, set := range sets {
+		cursor, err := set.collection.Find(context.Background(), bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+		if err != nil {
+			return manifest, nil, err
+		}
+		for cursor.Next(context.Background()) {
+			if err := archive.Write(set.name, cursor.Current); err != nil {
+				cursor.Close(context.Background())
+				return manifest, nil, err
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(context.Background())
+		if err != nil {
+			return manifest, nil, err
+		}
+	}
+
+	return manifest, archive.Counts(), archive.Close()
+}
+
+// read the whole archive once so a truncated or corrupt file is rejected
+// before anything is written; with the fail strategy also look for conflicts,
+// by _id and by unique key, so the restore cannot stop halfway
+func checkArchive(src io.Reader, strategy string) error {
+	archive, err := backup.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	pending := map[string][]bson.M{}
+	findConflict := func(name string) error {
+		docs := pending[name]
+		pending[name] = nil
+		if strategy != RestoreFail || len(docs) == 0 {
+			return nil
+		}
+		set, _ := backupSetByName(name)
+
+		ids := make([]interface{}, len(docs))
+		var keys []bson.M
+		for i, doc := range docs {
+			ids[i] = doc["_id"]
+			keys = append(keys, uniqueKeyFilters(set, doc)...)
+		}
+
+		var existing bson.M
+		err := set.collection.FindOne(context.Background(), bson.M{"_id": bson.M{"$in": ids}}).Decode(&existing)
+		if err == nil {
+			return fmt.Errorf("%w: %s %v", errRestoreConflict, name, existing["_id"])
+		} else if err != mongo.ErrNoDocuments {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		err = set.collection.FindOne(context.Background(), bson.M{"$or": keys}).Decode(&existing)
+		if err == nil {
+			return fmt.Errorf("%w: %s %v has a unique key of the backup", errRestoreConflict, name, existing["_id"])
+		} else if err != mongo.ErrNoDocuments {
+			return err
+		}
+		return nil
+	}
+
+	for {
+		name, doc, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := backupSetByName(name); !ok {
+			return fmt.Errorf("unknown collection %q in archive", name)
+		}
+		pending[name] = append(pending[name], doc)
+		if len(pending[name]) >= restoreBatchSize {
+			if err := findConflict(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name := range pending {
+		if err := findConflict(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// one filter per unique index matching the documents that hold the same key as doc;
+// empty fields are left out of the partial indexes and arrays match on any element
+func uniqueKeyFilters(set backupSet, doc bson.M) []bson.M {
+	var filters []bson.M
+	for _, fields := range set.unique {
+		filter := bson.M{}
+		for _, field := range fields {
+			value := doc[field]
+			if values, ok := value.(primitive.A); ok && len(values) > 0 {
+				value = bson.M{"$in": values}
+			} else if ok || value == nil || value == "" {
+				filter = nil
+				break
+			}
+			filter[field] = value
+		}
+		if filter != nil {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+// whether doc refers to a document that was left out of the restore
+func refersToDropped(set backupSet, doc bson.M, dropped map[interface{}]bool) bool {
+	for _, field := range set.refs {
+		if value, ok := doc[field]; ok && dropped[value] {
+			return true
+		}
+	}
+	return false
+}
+
+func isDuplicateKey(err mongo.WriteError) bool {
+	return err.Code == 11000
+}
+
+// write one batch of documents of a collection with the given strategy; with skip,
+// documents clashing on a unique key are added to dropped together with everything
+// referring to them, an existing document with the same _id takes their place instead
+func restoreBatch(set backupSet, docs []bson.M, strategy string, req requestInfo, dropped map[interface{}]bool, counts *model.RestoreCounts) error {
+	var kept []bson.M
+	for _, doc := range docs {
+		if refersToDropped(set, doc, dropped) {
+			dropped[doc["_id"]] = true
+			counts.Skipped++
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	docs = kept
+	if len(docs) == 0 {
+		return nil
+	}
+
+	ids := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc["_id"]
+	}
+
+	before := map[interface{}]bson.M{}
+	if strategy != RestoreFail {
+		for _, doc := range snapshots(set.collection, bson.M{"_id": bson.M{"$in": ids}}) {
+			before[doc["_id"]] = doc
+		}
+	}
+
+	models := make([]mongo.WriteModel, len(docs))
+	for i, doc := range docs {
+		if strategy == RestoreOverwrite {
+			models[i] = mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": doc["_id"]}).SetReplacement(doc).SetUpsert(true)
+		} else {
+			models[i] = mongo.NewInsertOneModel().SetDocument(doc)
+		}
+	}
+
+	// The fail strategy has already checked for conflicts, so it stops at the first write error
+	_, err := set.collection.BulkWrite(context.Background(), models, options.BulkWrite().SetOrdered(strategy == RestoreFail))
+
+	skipped := map[int]bool{}
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && strategy == RestoreSkip {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !isDuplicateKey(writeErr.WriteError) {
+				return writeErr
+			}
+			skipped[writeErr.Index] = true
+			if id := docs[writeErr.Index]["_id"]; before[id] == nil {
+				dropped[id] = true
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	for i, doc := range docs {
+		if skipped[i] {
+			counts.Skipped++
+			continue
+		}
+		if before[doc["_id"]] != nil {
+			counts.Overwritten++
+		} else {
+			counts.Restored++
+		}
+
+		docReq := req
+		docReq.LibraryID, _ = doc["libraryId"].(primitive.ObjectID)
+		if id, ok := doc["_id"].(primitive.ObjectID); ok && set.entity != "" {
+			recordAudit(docReq, set.entity, auditActionRestore, id, before[doc["_id"]], doc)
+		}
+	}
+	return nil
+}
+
+// Restore loads an archive written by Backup, keeping document IDs; existing
+// documents are skipped, overwritten or make the whole restore fail
+func Restore(src io.ReadSeeker, strategy string, actor string) (model.RestoreReport, error) {
+	return restoreArchive(src, strategy, requestInfo{Actor: actor})
+}
+
+func restoreArchive(src io.ReadSeeker, strategy string, req requestInfo) (model.RestoreReport, error) {
+	report := model.RestoreReport{Strategy: strategy, Collections: map[string]model.RestoreCounts{}}
+
+	if err := checkArchive(src, strategy); err != nil {
+		return report, err
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return report, err
+	}
+
+	archive, err := backup.NewReader(src)
+	if err != nil {
+		return report, err
+	}
+	defer archive.Close()
+	report.Version = archive.Manifest.Version
+
+	var batch []bson.M
+	var batchName string
+	dropped := map[interface{}]bool{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		set, _ := backupSetByName(batchName)
+		counts := report.Collections[batchName]
+		err := restoreBatch(set, batch, strategy, req, dropped, &counts)
+		report.Collections[batchName] = counts
+		batch = nil
+		return err
+	}
+
+	for {
+		name, doc, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		if name != batchName || len(batch) >= restoreBatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+			batchName = name
+		}
+		batch = append(batch, doc)
+	}
+
+	return report, flush()
+}
+
+func BackupDatabase(c *gin.Context) {
+	filename := fmt.Sprintf("books-api-%s.ndjson.gz", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure can only cut the archive short;
+	// the missing trailer makes restore reject it
+	if _, _, err := Backup(c.Writer); err != nil {
+		c.Error(err)
+	}
+}
+
+func RestoreDatabase(c *gin.Context) {
+	strategy := c.DefaultQuery("strategy", RestoreFail)
+	if !IsRestoreStrategy(strategy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "strategy must be skip, overwrite or fail"})
+		return
+	}
+
+	// Restore reads the archive twice, so the upload is spooled to disk first
+	tmp, err := os.CreateTemp("", "books-api-restore-*.ndjson.gz")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing upload"})
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	maxBytes := int64(defaultRestoreMaxBytes)
+	if size, err := strconv.ParseInt(os.Getenv("RESTORE_MAX_BYTES"), 10, 64); err == nil && size > 0 {
+		maxBytes = size
+	}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+
+	var tooLarge *http.MaxBytesError
+	if _, err := io.Copy(tmp, body); errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Backup is larger than %d bytes", maxBytes)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading upload"})
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading upload"})
+		return
+	}
+
+	report, err := restoreArchive(tmp, strategy, requestFrom(c))
+	if errors.Is(err, errRestoreConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "report": report})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"example/books-api/model"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUniqueKeyFilters(t *testing.T) {
+	libraryID := primitive.NewObjectID()
+	books, _ := backupSetByName("books")
+	genres, _ := backupSetByName("genres")
+	users, _ := backupSetByName("users")
+
+	tests := []struct {
+		name string
+		set  backupSet
+		doc  bson.M
+		want []bson.M
+	}{
+		{
+			name: "every unique index",
+			set:  books,
+			doc:  bson.M{"libraryId": libraryID, "isbn13": "9780441478125", "isbn10": "0441478123"},
+			want: []bson.M{{"libraryId": libraryID, "isbn13": "9780441478125"}, {"libraryId": libraryID, "isbn10": "0441478123"}},
+		},
+		{
+			name: "empty keys are not indexed",
+			set:  books,
+			doc:  bson.M{"libraryId": libraryID, "isbn13": "", "title": "Dune"},
+		},
+		{
+			name: "arrays match on any element",
+			set:  genres,
+			doc:  bson.M{"libraryId": libraryID, "keys": primitive.A{"sf", "science fiction"}},
+			want: []bson.M{{"libraryId": libraryID, "keys": bson.M{"$in": primitive.A{"sf", "science fiction"}}}},
+		},
+		{
+			name: "empty arrays are not indexed",
+			set:  genres,
+			doc:  bson.M{"libraryId": libraryID, "keys": primitive.A{}},
+		},
+		{
+			name: "compound keys need every field",
+			set:  users,
+			doc:  bson.M{"username": "ada", "provider": "oidc"},
+			want: []bson.M{{"username": "ada"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := uniqueKeyFilters(tt.set, tt.doc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: uniqueKeyFilters = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRestoreBatchSkipsChildrenOfDropped(t *testing.T) {
+	bookID, stateID, revisionID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	dropped := map[interface{}]bool{bookID: true}
+
+	states, _ := backupSetByName("readingStates")
+	revisions, _ := backupSetByName("revisions")
+
+	var counts model.RestoreCounts
+	if err := restoreBatch(states, []bson.M{{"_id": stateID, "book": bookID, "actor": "ada"}}, RestoreSkip, requestInfo{}, dropped, &counts); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreBatch(revisions, []bson.M{{"_id": revisionID, "entityId": bookID, "revision": 1}}, RestoreSkip, requestInfo{}, dropped, &counts); err != nil {
+		t.Fatal(err)
+	}
+
+	if counts.Skipped != 2 || counts.Restored != 0 {
+		t.Errorf("counts = %+v, want both documents skipped", counts)
+	}
+	if !dropped[stateID] || !dropped[revisionID] {
+		t.Errorf("dropped = %v, want the skipped children added", dropped)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"os"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -21,64 +20,117 @@ var readingListCollection *mongo.Collection
 var bookAuthor *mongo.Collection
 
 func init() {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-	connectionString := os.Getenv("CONNECTION_STRING")
-	clientOptions := options.Client().ApplyURI(connectionString)
-
-	client, error := mongo.Connect(context.TODO(), clientOptions)
-
-	if error != nil {
-		log.Fatal(error)
-	}
-
-	fmt.Println("Mongodb connection success")
-
-	dbName := os.Getenv("DBNAME")
-	colName2 := os.Getenv("COLNAME2")
-	colName := os.Getenv("COLNAME")
-	colName3 := os.Getenv("COLNAME3")
-
-	bookCollection = client.Database(dbName).Collection(colName2)
-	readingListCollection = client.Database(dbName).Collection(colName)
-	bookAuthor = client.Database(dbName).Collection(colName3)
-
-	fmt.Println("Collection istance is ready")
+	onSetup(func(db *mongo.Database) error {
+		colName2 := os.Getenv("COLNAME2")
+		colName := os.Getenv("COLNAME")
+		colName3 := os.Getenv("COLNAME3")
+
+		bookCollection = db.Collection(colName2)
+		readingListCollection = db.Collection(colName)
+		bookAuthor = db.Collection(colName3)
+
+		// Indexes behind the /book/all filters
+		for _, field := range []string{"publisher", "publicationYear", "language", "format"} {
+			ensureIndexes(bookCollection, mongo.IndexModel{
+				Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: field, Value: 1}},
+			})
+		}
+
+		// Series pages read the books of a series
+		ensureIndexes(bookCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "seriesId", Value: 1}, {Key: "volume", Value: 1}},
+		})
+
+		// Work pages list the editions of a work
+		ensureIndexes(bookCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "workId", Value: 1}},
+		})
+
+		// Publisher pages list the books of a publisher and its imprints
+		ensureIndexes(bookCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "publisherId", Value: 1}},
+		})
+
+		// Genre filters match books by any genre of a subtree
+		ensureIndexes(bookCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "genres", Value: 1}},
+		})
+
+		// Tag filters match books by tag name
+		ensureIndexes(bookCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "tags.name", Value: 1}},
+		})
+
+		// Role filters look up links by author and role
+		ensureIndexes(bookAuthor, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "author", Value: 1}, {Key: "role", Value: 1}},
+		})
+
+		// ISBNs are unique per library, books without one are left out of the index
+		for _, field := range []string{"isbn13", "isbn10"} {
+			ensureIndexes(bookCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "libraryId", Value: 1}, {Key: field, Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{field: bson.M{"$gt": ""}}),
+			})
+		}
+
+		fmt.Println("Collection istance is ready")
+		return nil
+	})
 }
 
-// insert book with author
-func insertBook(book model.Book, authorIDs []primitive.ObjectID) {
+// insert book with its contributors
+func insertBook(book *model.Book, contributors []model.Contributor, req requestInfo) error {
+    stampCreated(&book.Timestamps, req.Actor)
+    book.LibraryID = req.LibraryID
     inserted, err := bookCollection.InsertOne(context.Background(), book)
 
-    if err != nil {
-        log.Fatal(err)
+    // The unique ISBN indexes catch books inserted since the ISBN check
+    if mongo.IsDuplicateKeyError(err) {
+        return errISBNTaken
+    } else if err != nil {
+        return err
     }
 
     fmt.Println("Inserted a single document: ", inserted.InsertedID)
 
-    for _, authorID := range authorIDs {
+    book.ID = inserted.InsertedID.(primitive.ObjectID)
+    recordAudit(req, auditEntityBook, auditActionCreate, book.ID, nil, snapshot(bookCollection, book.ID))
+
+    // The reading state given with the book is the creator's own
+    if state, ok := bookReadingState(*book); ok {
+        if _, err := setReadingState(book.ID, state, req); err != nil {
+            return err
+        }
+    }
+
+    for _, authorID := range book.Authors {
+        before := snapshot(readingListCollection, authorID)
         _, err := readingListCollection.UpdateOne(
             context.Background(),
-            bson.M{"_id": authorID},
-            bson.M{"$addToSet": bson.M{"books": inserted.InsertedID}},
+            scoped(req.LibraryID, bson.M{"_id": authorID}),
+            bson.M{
+                "$addToSet": bson.M{"books": inserted.InsertedID},
+                "$set":      stampUpdated(bson.M{}, req.Actor),
+            },
         )
         if err != nil {
-            log.Fatal(err)
+            return err
         }
+        recordAudit(req, auditEntityAuthor, auditActionUpdate, authorID, before, snapshot(readingListCollection, authorID))
     }
 
-    for _, authorID := range authorIDs {
-        _, err := bookAuthor.InsertOne(context.Background(), bson.M{"book": inserted.InsertedID, "author": authorID})
-        if err != nil {
-            log.Fatal(err)
+    for _, link := range newLinks(book.ID, contributors, req) {
+        if _, err := bookAuthor.InsertOne(context.Background(), link); err != nil {
+            return err
         }
+        recordAudit(req, auditEntityBookAuthor, auditActionCreate, link.ID, nil, snapshot(bookAuthor, link.ID))
     }
+    return nil
 }
 
-// get book with author name
-func getBookWithAuthor(bookId string) (model.BookWithAuthor, error) {
+// get book with author name and the reader's own reading state and tags
+func getBookWithAuthor(bookId string, libraryID primitive.ObjectID, actor string) (model.BookWithAuthor, error) {
 	var bookWithAuthor model.BookWithAuthor
 
 	id, err := primitive.ObjectIDFromHex(bookId)
@@ -86,36 +138,13 @@ func getBookWithAuthor(bookId string) (model.BookWithAuthor, error) {
 		return bookWithAuthor, err
 	}
 
-    pipeline := []bson.M{
-        {"$match": bson.M{"_id": id}},
-        {"$lookup": bson.M{
-            "from":         "bookAuthor",
-            "localField":   "_id",
-            "foreignField": "book",
-            "as":           "bookAuthorRelations",
-        }},
-        {"$lookup": bson.M{
-            "from":         "readList",
-            "localField":   "bookAuthorRelations.author",
-            "foreignField": "_id",
-            "as":           "authors",
-        }},
-        {"$project": bson.M{
-            "_id":     1,
-            "title":   1,
-            "genre":   1,
-            "authors": bson.M{"$ifNull": []interface{}{
-                bson.M{"$map": bson.M{
-                    "input": "$authors",
-                    "as":    "author",
-                    "in": bson.M{"name": "$$author.name"},
-                }},
-                []model.AuthorInfo{},
-            }},
-            "read":    1,
-        }},
-    }
-    
+    pipeline := []bson.M{{"$match": scoped(libraryID, bson.M{"_id": id})}}
+    pipeline = append(pipeline, contributorStages(libraryID)...)
+    pipeline = append(pipeline, genreStages(libraryID)...)
+    pipeline = append(pipeline, bson.M{"$project": bookProjection(bson.M{"_id": 1, "contributors": 1})})
+    pipeline = append(pipeline, readingStateStages(libraryID, actor)...)
+    pipeline = append(pipeline, personalTagStages(libraryID, actor)...)
+
 	cursor, err := bookCollection.Aggregate(context.Background(), pipeline)
 	if err != nil {
 		log.Fatal(err)
@@ -123,36 +152,28 @@ func getBookWithAuthor(bookId string) (model.BookWithAuthor, error) {
 
 	if cursor.Next(context.Background()) {
 		cursor.Decode(&bookWithAuthor)
+		groupContributors(&bookWithAuthor)
 	}
 
 	return bookWithAuthor, nil
 }
 
-// get all book with author name
-func getAllBooksWithAuthors() []model.BookWithAuthor {
+// get all book with author name and the reader's own reading state and tags
+func getAllBooksWithAuthors(libraryID primitive.ObjectID, actor string, match bson.M, sort bson.D) []model.BookWithAuthor {
 	var booksWithAuthors []model.BookWithAuthor
 
-    pipeline := []bson.M{
-        {"$lookup": bson.M{
-            "from":         "bookAuthor",
-            "localField":   "_id",
-            "foreignField": "book",
-            "as":           "bookAuthorRelations",
-        }},
-        {"$lookup": bson.M{
-            "from":         "readList",
-            "localField":   "bookAuthorRelations.author",
-            "foreignField": "_id",
-            "as":           "authorInfo",
-        }},
-        {"$unwind": "$authorInfo"},
-        {"$group": bson.M{
-            "_id": "$_id",
-            "title": bson.M{"$first": "$title"},
-            "genre": bson.M{"$first": "$genre"},
-            "read": bson.M{"$first": "$read"},
-            "authors": bson.M{"$push": bson.M{"_id": "$authorInfo._id", "name": "$authorInfo.name"}},
-        }},
+    pipeline := []bson.M{{"$match": scoped(libraryID, match)}}
+    pipeline = append(pipeline, contributorStages(libraryID)...)
+    pipeline = append(pipeline, genreStages(libraryID)...)
+    pipeline = append(pipeline,
+        // books without any existing author are not listed
+        bson.M{"$match": bson.M{"contributors.0": bson.M{"$exists": true}}},
+        bson.M{"$project": bookProjection(bson.M{"_id": 1, "contributors": 1})},
+    )
+    pipeline = append(pipeline, readingStateStages(libraryID, actor)...)
+    pipeline = append(pipeline, personalTagStages(libraryID, actor)...)
+    if len(sort) > 0 {
+        pipeline = append(pipeline, bson.M{"$sort": sort})
     }
 
 	cursor, err := bookCollection.Aggregate(context.Background(), pipeline)
@@ -164,44 +185,121 @@ func getAllBooksWithAuthors() []model.BookWithAuthor {
 	for cursor.Next(context.Background()) {
 		var bookWithAuthor model.BookWithAuthor
 		cursor.Decode(&bookWithAuthor)
+		groupContributors(&bookWithAuthor)
 		booksWithAuthors = append(booksWithAuthors, bookWithAuthor)
 	}
 
 	return booksWithAuthors
 }
 
-// update book
-func updateBook(bookID string, book model.Book, authors []primitive.ObjectID) {
+// fields a book update replaces, missing ISBNs are unset so they stay out of the unique index;
+// the reading state is the reader's own and saved with setReadingState
+func bookUpdateDocument(book model.Book, actor string) bson.M {
+    set := bson.M{
+        "title":         book.Title,
+        "subtitle":        book.Subtitle,
+        "description":     book.Description,
+        "publisher":       book.Publisher,
+        "publicationYear": book.PublicationYear,
+        "publishedDate":   book.PublishedDate,
+        "edition":         book.Edition,
+        "pageCount":       book.PageCount,
+        "language":        book.Language,
+        "format":          book.Format,
+        "coverUrl":        book.CoverURL,
+        "genre":         book.Genre,
+    }
+    unset := bson.M{}
+    for field, value := range map[string]string{"isbn10": book.ISBN10, "isbn13": book.ISBN13} {
+        if value == "" {
+            unset[field] = ""
+        } else {
+            set[field] = value
+        }
+    }
+    if book.SeriesID.IsZero() {
+        unset["seriesId"] = ""
+    } else {
+        set["seriesId"] = book.SeriesID
+    }
+    if book.Volume == 0 {
+        unset["volume"] = ""
+    } else {
+        set["volume"] = book.Volume
+    }
+    if len(book.Genres) == 0 {
+        unset["genres"] = ""
+    } else {
+        set["genres"] = book.Genres
+    }
+    if book.PublisherID.IsZero() {
+        unset["publisherId"] = ""
+    } else {
+        set["publisherId"] = book.PublisherID
+    }
+    if book.WorkID.IsZero() {
+        unset["workId"] = ""
+    } else {
+        set["workId"] = book.WorkID
+    }
+
+    update := bson.M{"$set": stampUpdated(set, actor)}
+    if len(unset) > 0 {
+        update["$unset"] = unset
+    }
+    return update
+}
+
+// update book, contributors replace the book's links unless nil
+func updateBook(bookID string, book model.Book, contributors []model.Contributor, req requestInfo) error {
     id, _ := primitive.ObjectIDFromHex(bookID)
-    filter := bson.M{"_id": id}
-    update := bson.M{"$set": bson.M{"title": book.Title, "genre": book.Genre, "read": book.Read}}
+    filter := scoped(req.LibraryID, bson.M{"_id": id})
+    revision := captureRevision(bookRevisionTarget(), id, req)
+    before := snapshot(bookCollection, id)
+    update := markUserEdited(bookUpdateDocument(book, req.Actor), before, book)
+    if contributors != nil {
+        update["$set"].(bson.M)["authors"] = book.Authors
+    }
 
     result, err := bookCollection.UpdateOne(context.Background(), filter, update)
-    if err != nil {
-        log.Fatal(err)
+    if mongo.IsDuplicateKeyError(err) {
+        return errISBNTaken
+    } else if err != nil {
+        return err
     }
 
     fmt.Println("Updated a single document: ", result.UpsertedID)
 
-    // Update authors
-    authorFilter := bson.M{"book": id}
-    authorUpdate := bson.M{"$set": bson.M{"author": authors}}
+    if result.MatchedCount == 0 {
+        return nil
+    }
+    // Stored once the update succeeded so a failed one leaves no revision behind
+    if err := storeRevision(context.Background(), revision); err != nil {
+        return err
+    }
+    recordAudit(req, auditEntityBook, auditActionUpdate, id, before, snapshot(bookCollection, id))
 
-    authorResult, err := bookAuthor.UpdateOne(context.Background(), authorFilter, authorUpdate)
-    if err != nil {
-        log.Fatal(err)
+    if contributors != nil {
+        replaceBookLinks(id, contributors, req)
     }
 
-    fmt.Println("Updated authors: ", authorResult.ModifiedCount)
+    // A reading state sent with the book replaces the updating reader's own
+    if state, ok := bookReadingState(book); ok {
+        if _, err := setReadingState(id, state, req); err != nil {
+            return err
+        }
+    }
+    return nil
 }
 
 
 // delete book
-func deleteBook(bookId string) {
+func deleteBook(bookId string, req requestInfo) {
     id, _ := primitive.ObjectIDFromHex(bookId)
-    filter := bson.M{"_id": id}
+    filter := scoped(req.LibraryID, bson.M{"_id": id})
 
     // Delete the book from the books collection
+    before := snapshot(bookCollection, id)
     result, err := bookCollection.DeleteOne(context.Background(), filter)
 
     if err != nil {
@@ -210,8 +308,13 @@ func deleteBook(bookId string) {
 
     fmt.Println("Deleted a single document: ", result.DeletedCount)
 
+    if result.DeletedCount > 0 {
+        recordAudit(req, auditEntityBook, auditActionDelete, id, before, nil)
+    }
+
     // Delete the book from the bookAuthor collection
-    authorFilter := bson.M{"book": id}
+    authorFilter := scoped(req.LibraryID, bson.M{"book": id})
+    linksBefore := snapshots(bookAuthor, authorFilter)
     authorResult, err := bookAuthor.DeleteMany(context.Background(), authorFilter)
 
     if err != nil {
@@ -220,9 +323,14 @@ func deleteBook(bookId string) {
 
     fmt.Println("Deleted from bookAuthor: ", authorResult.DeletedCount)
 
+    for _, link := range linksBefore {
+        recordAudit(req, auditEntityBookAuthor, auditActionDelete, link["_id"].(primitive.ObjectID), link, nil)
+    }
+
     // Delete the book from the readingList collection
-    readListFilter := bson.M{"books": bson.M{"$in": []primitive.ObjectID{id}}}
-    update := bson.M{"$pull": bson.M{"books": id}}
+    readListFilter := scoped(req.LibraryID, bson.M{"books": bson.M{"$in": []primitive.ObjectID{id}}})
+    update := bson.M{"$pull": bson.M{"books": id}, "$set": stampUpdated(bson.M{}, req.Actor)}
+    authorsBefore := snapshots(readingListCollection, readListFilter)
     readListResult, err := readingListCollection.UpdateMany(context.Background(), readListFilter, update)
 
     if err != nil {
@@ -230,21 +338,66 @@ func deleteBook(bookId string) {
     }
 
     fmt.Println("Deleted from readingList: ", readListResult.ModifiedCount)
+
+    for _, author := range authorsBefore {
+        authorID := author["_id"].(primitive.ObjectID)
+        recordAudit(req, auditEntityAuthor, auditActionUpdate, authorID, author, snapshot(readingListCollection, authorID))
+    }
+
+    // Delete every reader's state of the book
+    stateFilter := scoped(req.LibraryID, bson.M{"book": id})
+    statesBefore := snapshots(readingStateCollection, stateFilter)
+    if _, err := readingStateCollection.DeleteMany(context.Background(), stateFilter); err != nil {
+        log.Fatal(err)
+    }
+    for _, state := range statesBefore {
+        recordAudit(req, auditEntityReadingState, auditActionDelete, state["_id"].(primitive.ObjectID), state, nil)
+    }
+
+    // and every reader's personal tags of it
+    tagsBefore := snapshots(personalTagCollection, stateFilter)
+    if _, err := personalTagCollection.DeleteMany(context.Background(), stateFilter); err != nil {
+        log.Fatal(err)
+    }
+    for _, tag := range tagsBefore {
+        recordAudit(req, auditEntityPersonalTag, auditActionDelete, tag["_id"].(primitive.ObjectID), tag, nil)
+    }
 }
 
 func GetAllBooksWithAuthors(c *gin.Context) {
-	allBooksWithAuthors := getAllBooksWithAuthors()
-	c.JSON(http.StatusOK, allBooksWithAuthors)
+	match, sort, err := bookListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if serveCached(c) {
+		return
+	}
+	req := requestFrom(c)
+	allBooksWithAuthors := getAllBooksWithAuthors(req.LibraryID, req.Actor, match, sort)
+	respondCached(c, allBooksWithAuthors, listTag(req.LibraryID, auditEntityBook))
 }
 
 func GetBookWithAuthor(c *gin.Context) {
 	bookId := c.Param("bookId")
-	bookWithAuthor, err := getBookWithAuthor(bookId)
+	if serveCached(c) {
+		return
+	}
+	req := requestFrom(c)
+	bookWithAuthor, err := getBookWithAuthor(bookId, req.LibraryID, req.Actor)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
 		return
 	}
-	c.JSON(http.StatusOK, bookWithAuthor)
+	id, _ := primitive.ObjectIDFromHex(bookId)
+	tags := []string{entityTag(auditEntityBook, id)}
+	for _, contributor := range bookWithAuthor.Contributors {
+		tags = append(tags, entityTag(auditEntityAuthor, contributor.ID))
+	}
+	for _, genre := range bookWithAuthor.Genres {
+		tags = append(tags, entityTag(auditEntityGenre, genre.ID))
+	}
+	respondCached(c, bookWithAuthor, tags...)
 }
 
 func CreateBook(c *gin.Context) {
@@ -253,18 +406,45 @@ func CreateBook(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    // Tags are added through /book/:bookId/tags, which knows whose they are
+    book.Tags = nil
+    if err := validateReadingState(book); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := normalizeBookISBN(&book); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := normalizeBookDetails(&book); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
 
-    authorIDs := make([]primitive.ObjectID, len(book.Authors))
-    for i, strID := range book.Authors {
-        authorID, err := primitive.ObjectIDFromHex(strID.Hex())
-        if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
-            return
-        }
-        authorIDs[i] = authorID
+    contributors, err := bookContributors(&book)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    // Editions of a work take the work's authors
+    if workContributors, err := checkBookWork(requestFrom(c).LibraryID, &book); err == errWorkNotFound {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Work does not exist"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking work"})
+        return
+    } else if workContributors != nil {
+        contributors = workContributors
     }
 
-    if exist, err := authorsExist(authorIDs); err != nil {
+    if contributors == nil {
+        contributors = []model.Contributor{}
+        book.Authors = []primitive.ObjectID{}
+    }
+
+    req := requestFrom(c)
+    if exist, err := authorsExist(book.Authors, req.LibraryID); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking author existence"})
         return
     } else if !exist {
@@ -272,13 +452,65 @@ func CreateBook(c *gin.Context) {
         return
     }
 
-    insertBook(book, authorIDs)
+    if err := checkBookSeries(req.LibraryID, book); err == errSeriesNotFound {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Series does not exist"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking series"})
+        return
+    }
+
+    if err := checkBookGenres(req.LibraryID, &book); err == errGenreNotFound {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Some genres do not exist"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking genres"})
+        return
+    }
+
+    if err := checkBookPublisher(req.LibraryID, &book); err == errPublisherNotFound {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Publisher does not exist"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking publisher"})
+        return
+    }
+
+    if err := checkISBNAvailable(req.LibraryID, book, primitive.NilObjectID); err == errISBNTaken {
+        c.JSON(http.StatusConflict, gin.H{"error": "A book with this ISBN already exists"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking ISBN"})
+        return
+    }
+
+    if err := checkQuota(req.LibraryID, auditEntityBook, 1); err == errQuotaExceeded {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Book quota exceeded for this library"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking library quota"})
+        return
+    }
+
+    if c.Query("enrich") != "false" {
+        enrichNewBook(c.Request.Context(), &book)
+    }
+    if err := insertBook(&book, contributors, req); err == errISBNTaken {
+        c.JSON(http.StatusConflict, gin.H{"error": "A book with this ISBN already exists"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving book"})
+        return
+    }
 
     c.JSON(http.StatusOK, book)
 }
 
-func authorsExist(authorIDs []primitive.ObjectID) (bool, error) {
-    filter := bson.M{"_id": bson.M{"$in": authorIDs}}
+func authorsExist(authorIDs []primitive.ObjectID, libraryID primitive.ObjectID) (bool, error) {
+    if len(authorIDs) == 0 {
+        return true, nil
+    }
+    filter := scoped(libraryID, bson.M{"_id": bson.M{"$in": authorIDs}})
 
     count, err := readingListCollection.CountDocuments(context.Background(), filter)
     if err != nil {
@@ -295,19 +527,38 @@ func UpdateBook(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    if err := validateReadingState(book); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := normalizeBookISBN(&book); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := normalizeBookDetails(&book); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
 
-    authorIDs := make([]primitive.ObjectID, len(book.Authors))
-    for i, strID := range book.Authors {
-        authorID, err := primitive.ObjectIDFromHex(strID.Hex())
-        if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
-            return
-        }
-        authorIDs[i] = authorID
+    contributors, err := bookContributors(&book)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    // Editions of a work take the work's authors
+    if workContributors, err := checkBookWork(requestFrom(c).LibraryID, &book); err == errWorkNotFound {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Work does not exist"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking work"})
+        return
+    } else if workContributors != nil {
+        contributors = workContributors
     }
 
     // Check if authors exist in the database
-    if exist, err := authorsExist(authorIDs); err != nil {
+    if exist, err := authorsExist(book.Authors, requestFrom(c).LibraryID); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking author existence"})
         return
     } else if !exist {
@@ -315,23 +566,62 @@ func UpdateBook(c *gin.Context) {
         return
     }
 
-    updateBook(bookId, book, authorIDs)
+    if err := checkBookSeries(requestFrom(c).LibraryID, book); err == errSeriesNotFound {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Series does not exist"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking series"})
+        return
+    }
+
+    if err := checkBookGenres(requestFrom(c).LibraryID, &book); err == errGenreNotFound {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Some genres do not exist"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking genres"})
+        return
+    }
+
+    if err := checkBookPublisher(requestFrom(c).LibraryID, &book); err == errPublisherNotFound {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Publisher does not exist"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking publisher"})
+        return
+    }
+
+    id, _ := primitive.ObjectIDFromHex(bookId)
+    if err := checkISBNAvailable(requestFrom(c).LibraryID, book, id); err == errISBNTaken {
+        c.JSON(http.StatusConflict, gin.H{"error": "A book with this ISBN already exists"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking ISBN"})
+        return
+    }
+
+    if err := updateBook(bookId, book, contributors, requestFrom(c)); err == errISBNTaken {
+        c.JSON(http.StatusConflict, gin.H{"error": "A book with this ISBN already exists"})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving book"})
+        return
+    }
 
     c.JSON(http.StatusOK, book)
 }
 
 func DeleteBook(c *gin.Context) {
 	bookId := c.Param("bookId")
-	deleteBook(bookId)
+	deleteBook(bookId, requestFrom(c))
 	c.JSON(http.StatusOK, gin.H{"message": "Book deleted"})
 }
 
 // get all books from author
-func getAllBooksForAuthor(authorId primitive.ObjectID) []model.Book {
+func getAllBooksForAuthor(authorId primitive.ObjectID, libraryID primitive.ObjectID) []model.Book {
     var bookAuthorLinks []model.BookAuthor
     var books []model.Book
 
-    linkFilter := bson.M{"author": authorId}
+    linkFilter := scoped(libraryID, bson.M{"author": authorId})
     cursor, err := bookAuthor.Find(context.Background(), linkFilter)
     if err != nil {
         log.Fatal(err)
@@ -344,7 +634,7 @@ func getAllBooksForAuthor(authorId primitive.ObjectID) []model.Book {
     }
 
     for _, link := range bookAuthorLinks {
-        bookFilter := bson.M{"_id": link.Book}
+        bookFilter := scoped(libraryID, bson.M{"_id": link.Book})
         var book model.Book
         err := bookCollection.FindOne(context.Background(), bookFilter).Decode(&book)
         if err != nil {
@@ -366,28 +656,36 @@ func GetBooksForAuthor(c *gin.Context) {
 		return
 	}
 
-	booksForAuthor := getAllBooksForAuthor(objAuthorId)
+	if serveCached(c) {
+		return
+	}
+
+	booksForAuthor := getAllBooksForAuthor(objAuthorId, requestFrom(c).LibraryID)
 
-	c.JSON(http.StatusOK, booksForAuthor)
+	tags := []string{entityTag(auditEntityAuthor, objAuthorId)}
+	for _, book := range booksForAuthor {
+		tags = append(tags, entityTag(auditEntityBook, book.ID))
+	}
+	respondCached(c, booksForAuthor, tags...)
 }
 
-// read book
-func readBook(bookId string) {
+// read book, marks it read for the reader only; other readers keep their own state
+func readBook(bookId string, req requestInfo) error {
 	id, _ := primitive.ObjectIDFromHex(bookId)
-	filter := bson.M{"_id": id}
-	update := bson.M{"$set": bson.M{"read": true}}
-
-	result, err := bookCollection.UpdateOne(context.Background(), filter, update)
-
-	if err != nil {
-		log.Fatal(err)
+	if _, err := findTaggedBook(id, req.LibraryID); err != nil {
+		return err
 	}
-
-	fmt.Println("Updated a single document: ", result.UpsertedID)
+	return markReadFor(id, req)
 }
 
 func ReadBook(c *gin.Context) {
 	bookId := c.Param("bookId")
-	readBook(bookId)
+	if err := readBook(bookId, requestFrom(c)); err == errBookNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading book"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Book read"})
 }
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"errors"
+	"example/books-api/model"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// language[-Script][-REGION][-variant...], e.g. "en", "sr-Latn-RS", "de-CH-1996"
+var languageTagPattern = regexp.MustCompile(`^([a-zA-Z]{2,3})(-[a-zA-Z]{4})?(-(?:[a-zA-Z]{2}|[0-9]{3}))?((?:-(?:[a-zA-Z0-9]{5,8}|[0-9][a-zA-Z0-9]{3}))*)$`)
+
+// publisher spellings of a binding mapped onto our formats
+var bookFormatAliases = map[string]string{
+	"hardcover":             model.BookFormatHardcover,
+	"hardback":              model.BookFormatHardcover,
+	"library binding":       model.BookFormatHardcover,
+	"paperback":             model.BookFormatPaperback,
+	"mass market paperback": model.BookFormatPaperback,
+	"trade paperback":       model.BookFormatPaperback,
+	"softcover":             model.BookFormatPaperback,
+	"ebook":                 model.BookFormatEbook,
+	"kindle edition":        model.BookFormatEbook,
+	"nook":                  model.BookFormatEbook,
+	"digital":               model.BookFormatEbook,
+	"audiobook":             model.BookFormatAudiobook,
+	"audio":                 model.BookFormatAudiobook,
+	"audio cd":              model.BookFormatAudiobook,
+	"audible audio":         model.BookFormatAudiobook,
+}
+
+// layouts providers and imports use for publication dates, stored as the ISO form of the same precision
+var publishedDateLayouts = []struct{ layout, iso string }{
+	{"2006-01-02", "2006-01-02"},
+	{"2006/01/02", "2006-01-02"},
+	{"January 2, 2006", "2006-01-02"},
+	{"Jan 2, 2006", "2006-01-02"},
+	{"Jan 02, 2006", "2006-01-02"},
+	{"2 January 2006", "2006-01-02"},
+	{"2006-01", "2006-01"},
+	{"January 2006", "2006-01"},
+	{"Jan 2006", "2006-01"},
+	{"2006", "2006"},
+}
+
+// canonical casing of a BCP-47 tag: "EN-us" -> "en-US", "sr-latn" -> "sr-Latn"
+func normalizeLanguageTag(tag string) (string, error) {
+	match := languageTagPattern.FindStringSubmatch(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if match == nil {
+		return "", fmt.Errorf("invalid language tag %q", tag)
+	}
+
+	normalized := strings.ToLower(match[1])
+	if script := match[2]; script != "" {
+		normalized += "-" + strings.ToUpper(script[1:2]) + strings.ToLower(script[2:])
+	}
+	normalized += strings.ToUpper(match[3]) + strings.ToLower(match[4])
+	return normalized, nil
+}
+
+func normalizeBookFormat(format string) (string, error) {
+	if normalized, ok := bookFormatAliases[strings.ToLower(strings.TrimSpace(format))]; ok {
+		return normalized, nil
+	}
+	return "", fmt.Errorf("invalid format %q: expected hardcover, paperback, ebook or audiobook", format)
+}
+
+// ISO date and year of a publication date written any of the accepted ways
+func normalizePublishedDate(value string) (string, int, error) {
+	value = strings.TrimSpace(value)
+	for _, candidate := range publishedDateLayouts {
+		if t, err := time.Parse(candidate.layout, value); err == nil {
+			return t.Format(candidate.iso), t.Year(), nil
+		}
+	}
+	return "", 0, fmt.Errorf("invalid publishedDate %q", value)
+}
+
+// check and normalize the descriptive fields of a book; the year is taken
+// from the publication date when only the date is given
+func normalizeBookDetails(book *model.Book) error {
+	if book.Language != "" {
+		language, err := normalizeLanguageTag(book.Language)
+		if err != nil {
+			return err
+		}
+		book.Language = language
+	}
+
+	if book.Format != "" {
+		format, err := normalizeBookFormat(book.Format)
+		if err != nil {
+			return err
+		}
+		book.Format = format
+	}
+
+	if book.PublishedDate != "" {
+		date, year, err := normalizePublishedDate(book.PublishedDate)
+		if err != nil {
+			return err
+		}
+		if book.PublicationYear != 0 && book.PublicationYear != year {
+			return errors.New("publicationYear does not match publishedDate")
+		}
+		book.PublishedDate, book.PublicationYear = date, year
+	}
+
+	if maxYear := time.Now().Year() + 5; book.PublicationYear < 0 || book.PublicationYear > maxYear {
+		return fmt.Errorf("publicationYear must be between 0 and %d", maxYear)
+	}
+	if book.PageCount < 0 {
+		return errors.New("pageCount must not be negative")
+	}
+
+	// Volumes may be fractional, like 2.5 for a novella between two books
+	if book.Volume < 0 {
+		return errors.New("volume must not be negative")
+	}
+	if book.Volume != 0 && book.SeriesID.IsZero() {
+		return errors.New("volume needs a seriesId")
+	}
+	return nil
+}
+
+// lenient form for imports and providers: values that do not validate are dropped
+func cleanBookDetails(book *model.Book) {
+	if language, err := normalizeLanguageTag(book.Language); err == nil {
+		book.Language = language
+	} else {
+		book.Language = ""
+	}
+	if format, err := normalizeBookFormat(book.Format); err == nil {
+		book.Format = format
+	} else {
+		book.Format = ""
+	}
+	if date, year, err := normalizePublishedDate(book.PublishedDate); err == nil {
+		book.PublishedDate = date
+		if book.PublicationYear == 0 {
+			book.PublicationYear = year
+		}
+	} else {
+		book.PublishedDate = ""
+	}
+}
+
+func parseImportInt(value string, field string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", field, value)
+	}
+	return n, nil
+}
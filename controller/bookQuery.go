@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// $match and $sort for book list endpoints, shared by /book/all and the list exports, e.g.
+// ?publisher=Ace&language=en&format=paperback&yearFrom=1960&yearTo=1979&minPages=200&translator=Pevear&series=<id>&work=<id>&genre=fantasy
+// &tags=book-club-2026,onboarding&tagMode=or
+func bookListQuery(c *gin.Context) (bson.M, bson.D, error) {
+	match, sort, err := timestampListQuery(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conditions, err := contributorConditions(c, requestFrom(c).LibraryID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tagged, err := tagConditions(c, requestFrom(c))
+	if err != nil {
+		return nil, nil, err
+	}
+	conditions = append(conditions, tagged...)
+	if len(conditions) > 0 {
+		match["$and"] = conditions
+	}
+
+	if publisher := c.Query("publisher"); publisher != "" {
+		// a publisher ID also matches the books of its imprints
+		if id, err := primitive.ObjectIDFromHex(publisher); err == nil {
+			family, err := publisherFamily(requestFrom(c).LibraryID, id)
+			if err != nil {
+				return nil, nil, err
+			}
+			match["publisherId"] = bson.M{"$in": append([]interface{}{}, family...)}
+		} else {
+			match["publisher"] = exactNameFilter(publisher)
+		}
+	}
+	if language := c.Query("language"); language != "" {
+		tag, err := normalizeLanguageTag(language)
+		if err != nil {
+			return nil, nil, err
+		}
+		// "en" also matches "en-US" and "en-GB"
+		match["language"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(tag) + "(-|$)", Options: "i"}
+	}
+	if series := c.Query("series"); series != "" {
+		if match["seriesId"], err = primitive.ObjectIDFromHex(series); err != nil {
+			return nil, nil, fmt.Errorf("invalid series: expected a series ID")
+		}
+	}
+	if work := c.Query("work"); work != "" {
+		if match["workId"], err = primitive.ObjectIDFromHex(work); err != nil {
+			return nil, nil, fmt.Errorf("invalid work: expected a work ID")
+		}
+	}
+	if genre := c.Query("genre"); genre != "" {
+		if match["genres"], err = genreCondition(requestFrom(c).LibraryID, genre); err != nil {
+			return nil, nil, err
+		}
+	}
+	if format := c.Query("format"); format != "" {
+		if match["format"], err = normalizeBookFormat(format); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for field, params := range map[string][2]string{
+		"publicationYear": {"yearFrom", "yearTo"},
+		"pageCount":       {"minPages", "maxPages"},
+	} {
+		rng := bson.M{}
+		for i, op := range []string{"$gte", "$lte"} {
+			value := c.Query(params[i])
+			if value == "" {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %s: expected a number", params[i])
+			}
+			rng[op] = n
+		}
+		if len(rng) > 0 {
+			match[field] = rng
+		}
+	}
+
+	return match, sort, nil
+}
+
+// book fields the detail and list aggregations pass through next to their authors,
+// the reading state is the reader's own and added by readingStateStages
+var bookDetailFields = []string{
+	"title", "subtitle", "description", "publisher", "publisherId", "publicationYear", "publishedDate", "edition",
+	"pageCount", "language", "format", "coverUrl",
+	"genre", "genres", "seriesId", "volume", "workId", "tags", "isbn10", "isbn13",
+	"enrichedFields", "createdAt", "updatedAt", "createdBy", "updatedBy",
+}
+
+// $project stage keeping every detail field, plus the given computed ones
+func bookProjection(fields bson.M) bson.M {
+	for _, field := range bookDetailFields {
+		fields[field] = 1
+	}
+	return fields
+}
+
+// genres condition for ?genre=, comma-separated genre IDs or names; a book matches
+// when it has one of the genres or one of their subgenres
+func genreCondition(libraryID primitive.ObjectID, value string) (bson.M, error) {
+	var refs []string
+	for _, ref := range strings.Split(value, ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+
+	ids, _, err := lookupGenres(libraryID, refs)
+	if err != nil {
+		return nil, err
+	}
+	genreIDs := []interface{}{}
+	if len(ids) > 0 {
+		if genreIDs, err = genreDescendants(libraryID, ids); err != nil {
+			return nil, err
+		}
+	}
+	return bson.M{"$in": append([]interface{}{}, genreIDs...)}, nil
+}
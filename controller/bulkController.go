@@ -0,0 +1,847 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example/books-api/auth"
+	"example/books-api/middleware"
+	"example/books-api/model"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	bulkOpCreate = "create"
+	bulkOpUpdate = "update"
+	bulkOpDelete = "delete"
+
+	bulkModeAtomic     = "atomic"
+	bulkModeBestEffort = "best-effort"
+
+	bulkStatusOK      = "ok"
+	bulkStatusError   = "error"
+	bulkStatusSkipped = "skipped"
+
+	maxBulkItems = 5000
+	// largest request body, enough for maxBulkItems operations of a few KB each
+	maxBulkBytes = 32 << 20
+)
+
+var errBulkTooLarge = fmt.Errorf("at most %d operations and %d MB per request", maxBulkItems, maxBulkBytes>>20)
+
+// one validated operation of a bulk request
+type bulkItem struct {
+	index        int
+	op           string
+	id           primitive.ObjectID
+	book         model.Book
+	author       model.Author
+	authorIDs    []primitive.ObjectID
+	contributors []model.Contributor
+	// set when an update carries an authors or contributors list, the book's links are replaced
+	replaceLinks bool
+	before       bson.M
+	linksBefore  []bson.M
+	linksCreated []model.BookAuthor
+	statesBefore []bson.M
+	tagsBefore   []bson.M
+	// state before an update, stored as a revision once the write succeeded
+	revision *model.Revision
+	// reading state sent with a book, saved as the requesting reader's own
+	state *model.ReadingState
+	// what an author delete takes with it
+	cascade authorCascade
+	write   mongo.WriteModel
+}
+
+type bulkPlan struct {
+	collection *mongo.Collection
+	results    []model.BulkItemResult
+	items      []*bulkItem
+	// runs right before the writes, e.g. to capture revisions; in atomic mode ctx is the transaction
+	prepare func(ctx context.Context, items []*bulkItem) error
+	// writes to links and related records for the items whose main write succeeded
+	after func(ctx context.Context, items []*bulkItem) error
+	// audit entries and the reader's own reading states once the writes are final
+	record func(items []*bulkItem)
+}
+
+func (p *bulkPlan) fail(index int, message string) {
+	p.results[index].Status = bulkStatusError
+	p.results[index].Error = message
+}
+
+func newBulkPlan(col *mongo.Collection, ops []model.BulkOperation) *bulkPlan {
+	plan := &bulkPlan{collection: col, results: make([]model.BulkItemResult, len(ops))}
+	for i, op := range ops {
+		plan.results[i] = model.BulkItemResult{Index: i, Op: op.Op, ID: op.ID}
+	}
+	return plan
+}
+
+// read a JSON array of operations or, for application/x-ndjson, one operation per line;
+// both are read one operation at a time so an oversized batch stops at the first extra item
+func decodeBulkOperations(c *gin.Context) ([]model.BulkOperation, error) {
+	decoder := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxBulkBytes))
+	ndjson := strings.HasPrefix(c.ContentType(), "application/x-ndjson")
+
+	ops, err := readBulkOperations(decoder, ndjson)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, errBulkTooLarge
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(ops) == 0 {
+		return nil, errors.New("no operations given")
+	}
+	return ops, nil
+}
+
+func readBulkOperations(decoder *json.Decoder, ndjson bool) ([]model.BulkOperation, error) {
+	var ops []model.BulkOperation
+
+	if !ndjson {
+		if token, err := decoder.Token(); err != nil {
+			return nil, err
+		} else if token != json.Delim('[') {
+			return nil, errors.New("expected an array of operations")
+		}
+	}
+
+	for ndjson || decoder.More() {
+		var op model.BulkOperation
+		err := decoder.Decode(&op)
+		if err == io.EOF && ndjson {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", len(ops), err)
+		}
+		ops = append(ops, op)
+		if len(ops) > maxBulkItems {
+			return nil, errBulkTooLarge
+		}
+	}
+
+	if !ndjson {
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return ops, nil
+}
+
+// parse the operation type and ID shared by books and authors, duplicate IDs are rejected
+// because unordered writes to the same record have no defined outcome
+func parseBulkItems(plan *bulkPlan, ops []model.BulkOperation) []*bulkItem {
+	var items []*bulkItem
+	seen := map[primitive.ObjectID]bool{}
+
+	for i, op := range ops {
+		item := &bulkItem{index: i, op: op.Op}
+
+		switch op.Op {
+		case bulkOpCreate:
+			if len(op.Data) == 0 {
+				plan.fail(i, "data is required")
+				continue
+			}
+		case bulkOpUpdate, bulkOpDelete:
+			id, err := primitive.ObjectIDFromHex(op.ID)
+			if err != nil {
+				plan.fail(i, "invalid id")
+				continue
+			}
+			if seen[id] {
+				plan.fail(i, "duplicate id in batch")
+				continue
+			}
+			seen[id] = true
+			item.id = id
+			if op.Op == bulkOpUpdate && len(op.Data) == 0 {
+				plan.fail(i, "data is required")
+				continue
+			}
+		default:
+			plan.fail(i, "op must be create, update or delete")
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// existing documents of the library by ID, used both to check existence and as audit snapshots
+func existingByID(col *mongo.Collection, libraryID primitive.ObjectID, items []*bulkItem) map[primitive.ObjectID]bson.M {
+	var ids []primitive.ObjectID
+	for _, item := range items {
+		if item.op != bulkOpCreate {
+			ids = append(ids, item.id)
+		}
+	}
+
+	existing := map[primitive.ObjectID]bson.M{}
+	if len(ids) == 0 {
+		return existing
+	}
+	for _, doc := range snapshots(col, scoped(libraryID, bson.M{"_id": bson.M{"$in": ids}})) {
+		existing[doc["_id"].(primitive.ObjectID)] = doc
+	}
+	return existing
+}
+
+func documentsByID(col *mongo.Collection, items []*bulkItem) map[primitive.ObjectID]bson.M {
+	var ids []primitive.ObjectID
+	for _, item := range items {
+		ids = append(ids, item.id)
+	}
+
+	docs := map[primitive.ObjectID]bson.M{}
+	if len(ids) == 0 {
+		return docs
+	}
+	for _, doc := range snapshots(col, bson.M{"_id": bson.M{"$in": ids}}) {
+		docs[doc["_id"].(primitive.ObjectID)] = doc
+	}
+	return docs
+}
+
+// run a plan: atomic mode writes everything in one transaction or nothing,
+// best-effort mode writes every valid item and reports the rest
+func executeBulk(ctx context.Context, plan *bulkPlan, mode string) model.BulkResult {
+	result := model.BulkResult{Mode: mode}
+
+	// Items still pending are the ones that passed validation
+	var pending []*bulkItem
+	for _, item := range plan.items {
+		if plan.results[item.index].Status == "" {
+			pending = append(pending, item)
+		}
+	}
+
+	if mode == bulkModeAtomic && len(pending) < len(plan.results) {
+		for _, item := range pending {
+			plan.results[item.index].Status = bulkStatusSkipped
+			plan.results[item.index].Error = "batch aborted: another item failed validation"
+		}
+		pending = nil
+	}
+
+	if len(pending) > 0 && mode == bulkModeBestEffort {
+		if err := plan.prepare(ctx, pending); err != nil {
+			for _, item := range pending {
+				plan.fail(item.index, err.Error())
+			}
+			pending = nil
+		}
+	}
+
+	var written []*bulkItem
+	if len(pending) > 0 && mode == bulkModeAtomic {
+		written = executeAtomic(ctx, plan, pending)
+	} else if len(pending) > 0 {
+		written = executeBestEffort(ctx, plan, pending)
+	}
+
+	// Items saved whose links failed keep their error but are still audited
+	for _, item := range written {
+		plan.results[item.index].ID = item.id.Hex()
+		if plan.results[item.index].Status == "" {
+			plan.results[item.index].Status = bulkStatusOK
+		}
+	}
+	if len(written) > 0 {
+		plan.record(written)
+	}
+
+	for _, item := range plan.results {
+		if item.Status == bulkStatusOK {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	result.Items = plan.results
+	return result
+}
+
+func bulkWriteModels(items []*bulkItem) []mongo.WriteModel {
+	models := make([]mongo.WriteModel, len(items))
+	for i, item := range items {
+		models[i] = item.write
+	}
+	return models
+}
+
+func executeAtomic(ctx context.Context, plan *bulkPlan, items []*bulkItem) []*bulkItem {
+	session, err := plan.collection.Database().Client().StartSession()
+	if err == nil {
+		defer session.EndSession(ctx)
+		_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+			if err := plan.prepare(sc, items); err != nil {
+				return nil, err
+			}
+			if _, err := plan.collection.BulkWrite(sc, bulkWriteModels(items), options.BulkWrite().SetOrdered(true)); err != nil {
+				return nil, err
+			}
+			// Revisions are stored by after, inside the transaction, so an aborted batch leaves none behind
+			return nil, plan.after(sc, items)
+		})
+	}
+
+	if err != nil {
+		for _, item := range items {
+			plan.fail(item.index, "transaction aborted: "+err.Error())
+		}
+		return nil
+	}
+	return items
+}
+
+func executeBestEffort(ctx context.Context, plan *bulkPlan, items []*bulkItem) []*bulkItem {
+	_, err := plan.collection.BulkWrite(ctx, bulkWriteModels(items), options.BulkWrite().SetOrdered(false))
+
+	failed := map[int]string{}
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) {
+		for _, writeErr := range bulkErr.WriteErrors {
+			failed[writeErr.Index] = writeErr.Message
+		}
+	} else if err != nil {
+		for i := range items {
+			failed[i] = err.Error()
+		}
+	}
+
+	var written []*bulkItem
+	for i, item := range items {
+		if message, ok := failed[i]; ok {
+			plan.fail(item.index, message)
+			continue
+		}
+		written = append(written, item)
+	}
+
+	if len(written) > 0 {
+		if err := plan.after(ctx, written); err != nil {
+			for _, item := range written {
+				plan.fail(item.index, "saved, but updating links or revisions failed: "+err.Error())
+			}
+		}
+	}
+	return written
+}
+
+func bulkMode(c *gin.Context) (string, bool) {
+	mode := c.DefaultQuery("mode", bulkModeBestEffort)
+	if mode != bulkModeAtomic && mode != bulkModeBestEffort {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be atomic or best-effort"})
+		return "", false
+	}
+	return mode, true
+}
+
+// deletes in a batch need the same permission as the single DELETE route
+func canBulkDelete(c *gin.Context, ops []model.BulkOperation, permission string) bool {
+	for _, op := range ops {
+		if op.Op == bulkOpDelete {
+			if claims := middleware.ClaimsFrom(c); claims == nil || !claims.Can(permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + permission})
+				return false
+			}
+			break
+		}
+	}
+	return true
+}
+
+func respondBulk(c *gin.Context, result model.BulkResult) {
+	status := http.StatusOK
+	if result.Failed > 0 && result.Mode == bulkModeAtomic {
+		status = http.StatusUnprocessableEntity
+	} else if result.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, result)
+}
+
+func planBookBulk(ops []model.BulkOperation, req requestInfo) *bulkPlan {
+	plan := newBulkPlan(bookCollection, ops)
+	items := parseBulkItems(plan, ops)
+
+	for _, item := range items {
+		if item.op == bulkOpDelete {
+			continue
+		}
+		if err := json.Unmarshal(ops[item.index].Data, &item.book); err != nil {
+			plan.fail(item.index, "invalid data: "+err.Error())
+			continue
+		}
+		item.book.Tags = nil
+		if err := validateReadingState(item.book); err != nil {
+			plan.fail(item.index, err.Error())
+			continue
+		}
+		// Duplicate ISBNs are left to the unique index and come back as write errors
+		if err := normalizeBookISBN(&item.book); err != nil {
+			plan.fail(item.index, err.Error())
+			continue
+		}
+		if err := normalizeBookDetails(&item.book); err != nil {
+			plan.fail(item.index, err.Error())
+			continue
+		}
+		contributors, err := bookContributors(&item.book)
+		if err != nil {
+			plan.fail(item.index, err.Error())
+			continue
+		}
+		if err := resolveBookGenre(req.LibraryID, &item.book); err != nil {
+			plan.fail(item.index, "Error checking genres")
+			continue
+		}
+		if err := resolveBookPublisher(req.LibraryID, &item.book); err != nil {
+			plan.fail(item.index, "Error checking publisher")
+			continue
+		}
+		if state, ok := bookReadingState(item.book); ok {
+			item.state = &state
+		}
+		item.contributors = contributors
+		item.authorIDs = item.book.Authors
+		item.replaceLinks = item.op == bulkOpCreate || contributors != nil
+	}
+
+	// Editions of a work take the work's authors
+	var workIDs []primitive.ObjectID
+	for _, item := range items {
+		if !item.book.WorkID.IsZero() {
+			workIDs = append(workIDs, item.book.WorkID)
+		}
+	}
+	knownWorks := map[primitive.ObjectID]model.Work{}
+	if len(workIDs) > 0 {
+		works, err := getAllWorks(req.LibraryID, bson.M{"_id": bson.M{"$in": workIDs}}, nil)
+		if err != nil {
+			for _, item := range items {
+				if !item.book.WorkID.IsZero() && plan.results[item.index].Status == "" {
+					plan.fail(item.index, "Error checking work")
+				}
+			}
+		}
+		for _, work := range works {
+			knownWorks[work.ID] = work
+		}
+	}
+	for _, item := range items {
+		if item.book.WorkID.IsZero() || plan.results[item.index].Status != "" {
+			continue
+		}
+		work, ok := knownWorks[item.book.WorkID]
+		if !ok {
+			plan.fail(item.index, "Work does not exist")
+			continue
+		}
+		item.contributors, item.authorIDs = workContributors(work)
+		item.book.Authors = item.authorIDs
+		item.replaceLinks = true
+	}
+
+	// Every referenced author must exist in this library
+	authorSet := map[primitive.ObjectID]bool{}
+	var authorIDs []primitive.ObjectID
+	for _, item := range items {
+		for _, authorID := range item.authorIDs {
+			if !authorSet[authorID] {
+				authorSet[authorID] = true
+				authorIDs = append(authorIDs, authorID)
+			}
+		}
+	}
+	knownAuthors := map[primitive.ObjectID]bool{}
+	if len(authorIDs) > 0 {
+		for _, doc := range snapshots(readingListCollection, scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": authorIDs}})) {
+			knownAuthors[doc["_id"].(primitive.ObjectID)] = true
+		}
+	}
+
+	// and so must every referenced series
+	var seriesIDs []primitive.ObjectID
+	for _, item := range items {
+		if !item.book.SeriesID.IsZero() {
+			seriesIDs = append(seriesIDs, item.book.SeriesID)
+		}
+	}
+	knownSeries := map[primitive.ObjectID]bool{}
+	if len(seriesIDs) > 0 {
+		for _, doc := range snapshots(seriesCollection, scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": seriesIDs}})) {
+			knownSeries[doc["_id"].(primitive.ObjectID)] = true
+		}
+	}
+
+	// and every referenced genre
+	var genreIDs []primitive.ObjectID
+	for _, item := range items {
+		genreIDs = append(genreIDs, item.book.Genres...)
+	}
+	knownGenres := map[primitive.ObjectID]bool{}
+	if len(genreIDs) > 0 {
+		for _, doc := range snapshots(genreCollection, scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": genreIDs}})) {
+			knownGenres[doc["_id"].(primitive.ObjectID)] = true
+		}
+	}
+
+	// and every referenced publisher, whose name the book takes
+	var publisherIDs []primitive.ObjectID
+	for _, item := range items {
+		if !item.book.PublisherID.IsZero() {
+			publisherIDs = append(publisherIDs, item.book.PublisherID)
+		}
+	}
+	knownPublishers := map[primitive.ObjectID]string{}
+	if len(publisherIDs) > 0 {
+		for _, doc := range snapshots(publisherCollection, scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": publisherIDs}})) {
+			knownPublishers[doc["_id"].(primitive.ObjectID)], _ = doc["name"].(string)
+		}
+	}
+
+	existing := existingByID(bookCollection, req.LibraryID, items)
+	creates := int64(0)
+
+	for _, item := range items {
+		if plan.results[item.index].Status != "" {
+			continue
+		}
+		if item.op != bulkOpCreate {
+			item.before = existing[item.id]
+			if item.before == nil {
+				plan.fail(item.index, "Book not found")
+				continue
+			}
+		}
+		for _, authorID := range item.authorIDs {
+			if !knownAuthors[authorID] {
+				plan.fail(item.index, "Some authors do not exist")
+				break
+			}
+		}
+		if plan.results[item.index].Status == "" && !item.book.SeriesID.IsZero() && !knownSeries[item.book.SeriesID] {
+			plan.fail(item.index, "Series does not exist")
+		}
+		for _, genreID := range item.book.Genres {
+			if plan.results[item.index].Status == "" && !knownGenres[genreID] {
+				plan.fail(item.index, "Some genres do not exist")
+			}
+		}
+		if !item.book.PublisherID.IsZero() {
+			if name, ok := knownPublishers[item.book.PublisherID]; !ok {
+				if plan.results[item.index].Status == "" {
+					plan.fail(item.index, "Publisher does not exist")
+				}
+			} else {
+				item.book.Publisher = name
+			}
+		}
+		if plan.results[item.index].Status != "" {
+			continue
+		}
+
+		switch item.op {
+		case bulkOpCreate:
+			creates++
+			item.id = primitive.NewObjectID()
+			item.book.ID = item.id
+			item.book.LibraryID = req.LibraryID
+			stampCreated(&item.book.Timestamps, req.Actor)
+			item.write = mongo.NewInsertOneModel().SetDocument(item.book)
+		case bulkOpUpdate:
+			update := markUserEdited(bookUpdateDocument(item.book, req.Actor), item.before, item.book)
+			if item.replaceLinks {
+				update["$set"].(bson.M)["authors"] = item.authorIDs
+			}
+			item.write = mongo.NewUpdateOneModel().
+				SetFilter(scoped(req.LibraryID, bson.M{"_id": item.id})).
+				SetUpdate(update)
+		case bulkOpDelete:
+			item.write = mongo.NewDeleteOneModel().SetFilter(scoped(req.LibraryID, bson.M{"_id": item.id}))
+		}
+
+		if item.op != bulkOpCreate && (item.replaceLinks || item.op == bulkOpDelete) {
+			item.linksBefore = snapshots(bookAuthor, scoped(req.LibraryID, bson.M{"book": item.id}))
+		}
+		if item.op == bulkOpDelete {
+			item.statesBefore = snapshots(readingStateCollection, scoped(req.LibraryID, bson.M{"book": item.id}))
+			item.tagsBefore = snapshots(personalTagCollection, scoped(req.LibraryID, bson.M{"book": item.id}))
+		}
+		if item.replaceLinks {
+			// written in after() and audited in record()
+			item.linksCreated = newLinks(item.id, item.contributors, req)
+		}
+	}
+
+	if creates > 0 {
+		if err := checkQuota(req.LibraryID, auditEntityBook, creates); err != nil {
+			for _, item := range items {
+				if item.op == bulkOpCreate && plan.results[item.index].Status == "" {
+					plan.fail(item.index, err.Error())
+				}
+			}
+		}
+	}
+
+	plan.items = items
+
+	plan.prepare = func(ctx context.Context, items []*bulkItem) error {
+		for _, item := range items {
+			if item.op == bulkOpUpdate {
+				item.revision = captureRevision(bookRevisionTarget(), item.id, req)
+			}
+		}
+		return nil
+	}
+
+	plan.after = func(ctx context.Context, items []*bulkItem) error {
+		for _, item := range items {
+			if err := storeRevision(ctx, item.revision); err != nil {
+				return err
+			}
+			if item.op != bulkOpCreate && (item.replaceLinks || item.op == bulkOpDelete) {
+				linkFilter := scoped(req.LibraryID, bson.M{"book": item.id})
+				if _, err := bookAuthor.DeleteMany(ctx, linkFilter); err != nil {
+					return err
+				}
+				pull := bson.M{"$pull": bson.M{"books": item.id}, "$set": stampUpdated(bson.M{}, req.Actor)}
+				if _, err := readingListCollection.UpdateMany(ctx, scoped(req.LibraryID, bson.M{"books": item.id}), pull); err != nil {
+					return err
+				}
+			}
+			if item.op == bulkOpDelete {
+				if _, err := readingStateCollection.DeleteMany(ctx, scoped(req.LibraryID, bson.M{"book": item.id})); err != nil {
+					return err
+				}
+				if _, err := personalTagCollection.DeleteMany(ctx, scoped(req.LibraryID, bson.M{"book": item.id})); err != nil {
+					return err
+				}
+			}
+			if len(item.linksCreated) > 0 {
+				docs := make([]interface{}, len(item.linksCreated))
+				for i, link := range item.linksCreated {
+					docs[i] = link
+				}
+				if _, err := bookAuthor.InsertMany(ctx, docs); err != nil {
+					return err
+				}
+				add := bson.M{"$addToSet": bson.M{"books": item.id}, "$set": stampUpdated(bson.M{}, req.Actor)}
+				if _, err := readingListCollection.UpdateMany(ctx, scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": item.authorIDs}}), add); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	plan.record = func(items []*bulkItem) {
+		after := documentsByID(bookCollection, items)
+		for _, item := range items {
+			switch item.op {
+			case bulkOpCreate:
+				recordAudit(req, auditEntityBook, auditActionCreate, item.id, nil, after[item.id])
+			case bulkOpUpdate:
+				recordAudit(req, auditEntityBook, auditActionUpdate, item.id, item.before, after[item.id])
+			case bulkOpDelete:
+				recordAudit(req, auditEntityBook, auditActionDelete, item.id, item.before, nil)
+			}
+			recordBulkLinks(req, item)
+
+			// Reading states are per reader and written once the book is
+			if item.state != nil {
+				if _, err := setReadingState(item.id, *item.state, req); err != nil {
+					plan.fail(item.index, "saved, but saving the reading state failed: "+err.Error())
+				}
+			}
+		}
+	}
+
+	return plan
+}
+
+func recordBulkLinks(req requestInfo, item *bulkItem) {
+	for _, link := range item.linksBefore {
+		recordAudit(req, auditEntityBookAuthor, auditActionDelete, link["_id"].(primitive.ObjectID), link, nil)
+	}
+	for _, link := range item.linksCreated {
+		recordAudit(req, auditEntityBookAuthor, auditActionCreate, link.ID, nil, snapshot(bookAuthor, link.ID))
+	}
+	for _, state := range item.statesBefore {
+		recordAudit(req, auditEntityReadingState, auditActionDelete, state["_id"].(primitive.ObjectID), state, nil)
+	}
+	for _, tag := range item.tagsBefore {
+		recordAudit(req, auditEntityPersonalTag, auditActionDelete, tag["_id"].(primitive.ObjectID), tag, nil)
+	}
+}
+
+func planAuthorBulk(ops []model.BulkOperation, req requestInfo) *bulkPlan {
+	plan := newBulkPlan(collection, ops)
+	items := parseBulkItems(plan, ops)
+
+	for _, item := range items {
+		if item.op == bulkOpDelete {
+			continue
+		}
+		if err := json.Unmarshal(ops[item.index].Data, &item.author); err != nil {
+			plan.fail(item.index, "invalid data: "+err.Error())
+			continue
+		}
+		// Duplicate external IDs are left to the unique indexes and come back as write errors
+		if err := normalizeAuthorDetails(&item.author); err != nil {
+			plan.fail(item.index, err.Error())
+		}
+	}
+
+	existing := existingByID(collection, req.LibraryID, items)
+	creates := int64(0)
+
+	for _, item := range items {
+		if plan.results[item.index].Status != "" {
+			continue
+		}
+		if item.op != bulkOpCreate {
+			item.before = existing[item.id]
+			if item.before == nil {
+				plan.fail(item.index, "Author not found")
+				continue
+			}
+		}
+
+		switch item.op {
+		case bulkOpCreate:
+			creates++
+			item.id = primitive.NewObjectID()
+			item.author.ID = item.id
+			item.author.Books = []primitive.ObjectID{}
+			item.author.LibraryID = req.LibraryID
+			stampCreated(&item.author.Timestamps, req.Actor)
+			item.write = mongo.NewInsertOneModel().SetDocument(item.author)
+		case bulkOpUpdate:
+			item.write = mongo.NewUpdateOneModel().
+				SetFilter(scoped(req.LibraryID, bson.M{"_id": item.id})).
+				SetUpdate(authorUpdateDocument(item.author, req.Actor))
+		case bulkOpDelete:
+			// Same cascade as deleteAuthor
+			item.cascade = planAuthorCascade(item.id, req.LibraryID)
+			item.write = mongo.NewDeleteOneModel().SetFilter(scoped(req.LibraryID, bson.M{"_id": item.id}))
+		}
+	}
+
+	if creates > 0 {
+		if err := checkQuota(req.LibraryID, auditEntityAuthor, creates); err != nil {
+			for _, item := range items {
+				if item.op == bulkOpCreate && plan.results[item.index].Status == "" {
+					plan.fail(item.index, err.Error())
+				}
+			}
+		}
+	}
+
+	plan.items = items
+
+	plan.prepare = func(ctx context.Context, items []*bulkItem) error {
+		for _, item := range items {
+			if item.op == bulkOpUpdate {
+				item.revision = captureRevision(authorRevisionTarget(), item.id, req)
+			}
+		}
+		return nil
+	}
+
+	plan.after = func(ctx context.Context, items []*bulkItem) error {
+		for _, item := range items {
+			if err := storeRevision(ctx, item.revision); err != nil {
+				return err
+			}
+			if item.op != bulkOpDelete {
+				continue
+			}
+			if err := item.cascade.apply(ctx, item.id, req); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	plan.record = func(items []*bulkItem) {
+		after := documentsByID(collection, items)
+		for _, item := range items {
+			switch item.op {
+			case bulkOpCreate:
+				recordAudit(req, auditEntityAuthor, auditActionCreate, item.id, nil, after[item.id])
+			case bulkOpUpdate:
+				recordAudit(req, auditEntityAuthor, auditActionUpdate, item.id, item.before, after[item.id])
+			case bulkOpDelete:
+				recordAudit(req, auditEntityAuthor, auditActionDelete, item.id, item.before, nil)
+				item.cascade.record(req)
+			}
+		}
+	}
+
+	return plan
+}
+
+func BulkBooks(c *gin.Context) {
+	mode, ok := bulkMode(c)
+	if !ok {
+		return
+	}
+
+	ops, err := decodeBulkOperations(c)
+	if err == errBulkTooLarge {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canBulkDelete(c, ops, auth.PermBookDelete) {
+		return
+	}
+
+	plan := planBookBulk(ops, requestFrom(c))
+	respondBulk(c, executeBulk(c.Request.Context(), plan, mode))
+}
+
+func BulkAuthors(c *gin.Context) {
+	mode, ok := bulkMode(c)
+	if !ok {
+		return
+	}
+
+	ops, err := decodeBulkOperations(c)
+	if err == errBulkTooLarge {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canBulkDelete(c, ops, auth.PermAuthorDelete) {
+		return
+	}
+
+	plan := planAuthorBulk(ops, requestFrom(c))
+	respondBulk(c, executeBulk(c.Request.Context(), plan, mode))
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDecodeBulkOperations(t *testing.T) {
+	many := strings.Repeat(`{"op":"delete","id":"64b000000000000000000000"},`, maxBulkItems+1)
+	manyLines := strings.Repeat(`{"op":"delete","id":"64b000000000000000000000"}`+"\n", maxBulkItems+1)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+		wantErr     error
+	}{
+		{name: "array", body: `[{"op":"create","data":{}},{"op":"delete","id":"64b000000000000000000000"}]`, want: 2},
+		{name: "ndjson", contentType: "application/x-ndjson", body: "{\"op\":\"create\",\"data\":{}}\n{\"op\":\"delete\"}\n", want: 2},
+		{name: "empty array", body: `[]`},
+		{name: "not an array", body: `{"op":"create"}`},
+		{name: "unterminated array", body: `[{"op":"create"}`},
+		{name: "invalid ndjson line", contentType: "application/x-ndjson", body: "{\"op\":\"create\"}\n{op}\n"},
+		{name: "too many items", body: "[" + many + "{}]", wantErr: errBulkTooLarge},
+		{name: "too many lines", contentType: "application/x-ndjson", body: manyLines, wantErr: errBulkTooLarge},
+		{name: "body too large", body: `[{"op":"create","data":"` + strings.Repeat("x", maxBulkBytes) + `"}]`, wantErr: errBulkTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/book/bulk", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				c.Request.Header.Set("Content-Type", tt.contentType)
+			}
+
+			ops, err := decodeBulkOperations(c)
+			if tt.want > 0 {
+				if err != nil || len(ops) != tt.want {
+					t.Fatalf("decodeBulkOperations = %d operations, %v, want %d", len(ops), err, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("decodeBulkOperations accepted %.40q", tt.body)
+			}
+			if tt.wantErr != nil && err != tt.wantErr {
+				t.Errorf("decodeBulkOperations error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
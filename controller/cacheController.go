@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"encoding/json"
+	"example/books-api/cache"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultCacheSize = 1000
+	defaultCacheTTL  = 30 * time.Second
+)
+
+var responseCache *cache.Metered
+var cacheTTL time.Duration
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		responseCache = cache.NewMetered(cache.NewLRU(cacheSizeFromEnv()))
+		cacheTTL = cacheTTLFromEnv()
+		return nil
+	})
+}
+
+func cacheSizeFromEnv() int {
+	if size, err := strconv.Atoi(os.Getenv("CACHE_SIZE")); err == nil && size > 0 {
+		return size
+	}
+	return defaultCacheSize
+}
+
+func cacheTTLFromEnv() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("CACHE_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultCacheTTL
+}
+
+// SetResponseCache swaps the in-process LRU for another store, call before serving
+func SetResponseCache(c cache.Cache) {
+	responseCache = cache.NewMetered(c)
+}
+
+func entityTag(entity string, id primitive.ObjectID) string {
+	return entity + ":" + id.Hex()
+}
+
+func listTag(libraryID primitive.ObjectID, entity string) string {
+	return "list:" + entity + ":" + libraryID.Hex()
+}
+
+// responses are per library, per user, since personal tags differ, and per query string
+func cacheKey(c *gin.Context) string {
+	req := requestFrom(c)
+	return req.LibraryID.Hex() + ":" + req.Actor + ":" + c.Request.URL.Path + "?" + c.Request.URL.Query().Encode()
+}
+
+func setCacheControl(c *gin.Context) {
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(cacheTTL.Seconds())))
+}
+
+// answer from the cache if possible, returns true when the response was written
+func serveCached(c *gin.Context) bool {
+	data, ok := responseCache.Get(cacheKey(c))
+	if !ok {
+		c.Header("X-Cache", "MISS")
+		return false
+	}
+
+	c.Header("X-Cache", "HIT")
+	setCacheControl(c)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+	return true
+}
+
+// cache and write a successful response, tagged with every record it was built from
+func respondCached(c *gin.Context, value interface{}, tags ...string) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error encoding response"})
+		return
+	}
+
+	responseCache.Set(cacheKey(c), data, cacheTTL, tags...)
+	setCacheControl(c)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// drop cached responses that include a changed record. Authors show their book
+// titles and books show their author names, so both lists depend on both entities.
+// A series or work shows its books, so a book change also drops the series and work it is or was in.
+// Books show their genre names, a genre change drops the books cached with it.
+// A reader's own reading state or tag drops the book, and so the series and work pages listing it.
+func invalidateCached(libraryID primitive.ObjectID, entity string, id primitive.ObjectID, before bson.M, after bson.M) {
+	tags := []string{listTag(libraryID, auditEntityAuthor), listTag(libraryID, auditEntityBook)}
+
+	switch entity {
+	case auditEntityAuthor:
+		tags = append(tags, entityTag(entity, id))
+	case auditEntityBook:
+		tags = append(tags, entityTag(entity, id))
+		for _, book := range []bson.M{before, after} {
+			if seriesID, ok := book["seriesId"].(primitive.ObjectID); ok {
+				tags = append(tags, entityTag(auditEntitySeries, seriesID))
+			}
+			if workID, ok := book["workId"].(primitive.ObjectID); ok {
+				tags = append(tags, entityTag(auditEntityWork, workID))
+			}
+		}
+	case auditEntitySeries, auditEntityGenre, auditEntityPublisher, auditEntityWork:
+		tags = append(tags, entityTag(entity, id), listTag(libraryID, entity))
+	case auditEntityReadingState, auditEntityPersonalTag:
+		for _, doc := range []bson.M{before, after} {
+			if bookID, ok := doc["book"].(primitive.ObjectID); ok {
+				tags = append(tags, entityTag(auditEntityBook, bookID))
+			}
+		}
+	case auditEntityBookAuthor:
+		for _, link := range []bson.M{before, after} {
+			if bookID, ok := link["book"].(primitive.ObjectID); ok {
+				tags = append(tags, entityTag(auditEntityBook, bookID))
+			}
+			if authorID, ok := link["author"].(primitive.ObjectID); ok {
+				tags = append(tags, entityTag(auditEntityAuthor, authorID))
+			}
+		}
+	}
+
+	responseCache.InvalidateTags(tags...)
+}
+
+func GetCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, responseCache.Stats())
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"example/books-api/cache"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// a cache that only records the tags it was asked to drop
+type recordingCache struct {
+	invalidated []string
+}
+
+func (c *recordingCache) Get(key string) ([]byte, bool) { return nil, false }
+
+func (c *recordingCache) Set(key string, value []byte, ttl time.Duration, tags ...string) {}
+
+func (c *recordingCache) InvalidateTags(tags ...string) {
+	c.invalidated = append(c.invalidated, tags...)
+}
+
+// swap in a recording cache for the test
+func recordInvalidations(t *testing.T) *recordingCache {
+	t.Helper()
+	previous := responseCache
+	recorder := &recordingCache{}
+	SetResponseCache(recorder)
+	t.Cleanup(func() { responseCache = previous })
+	return recorder
+}
+
+var _ cache.Cache = (*recordingCache)(nil)
+
+func TestInvalidateCached(t *testing.T) {
+	libraryID := primitive.NewObjectID()
+	id := primitive.NewObjectID()
+	seriesID, otherSeriesID := primitive.NewObjectID(), primitive.NewObjectID()
+	workID := primitive.NewObjectID()
+	bookID, authorID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	authors, books := listTag(libraryID, auditEntityAuthor), listTag(libraryID, auditEntityBook)
+
+	tests := []struct {
+		name          string
+		entity        string
+		before, after bson.M
+		want          []string
+	}{
+		{
+			name:   "author",
+			entity: auditEntityAuthor,
+			want:   []string{authors, books, entityTag(auditEntityAuthor, id)},
+		},
+		{
+			name:   "book moved to another series",
+			entity: auditEntityBook,
+			before: bson.M{"seriesId": seriesID},
+			after:  bson.M{"seriesId": otherSeriesID, "workId": workID},
+			want: []string{authors, books, entityTag(auditEntityBook, id),
+				entityTag(auditEntitySeries, seriesID), entityTag(auditEntitySeries, otherSeriesID), entityTag(auditEntityWork, workID)},
+		},
+		{
+			name:   "book created outside any series",
+			entity: auditEntityBook,
+			after:  bson.M{"title": "Dune"},
+			want:   []string{authors, books, entityTag(auditEntityBook, id)},
+		},
+		{
+			name:   "genre",
+			entity: auditEntityGenre,
+			want:   []string{authors, books, entityTag(auditEntityGenre, id), listTag(libraryID, auditEntityGenre)},
+		},
+		{
+			name:   "work",
+			entity: auditEntityWork,
+			want:   []string{authors, books, entityTag(auditEntityWork, id), listTag(libraryID, auditEntityWork)},
+		},
+		{
+			name:   "reading state",
+			entity: auditEntityReadingState,
+			after:  bson.M{"book": bookID, "actor": "ada"},
+			want:   []string{authors, books, entityTag(auditEntityBook, bookID)},
+		},
+		{
+			name:   "link removed",
+			entity: auditEntityBookAuthor,
+			before: bson.M{"book": bookID, "author": authorID},
+			want:   []string{authors, books, entityTag(auditEntityBook, bookID), entityTag(auditEntityAuthor, authorID)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := recordInvalidations(t)
+			invalidateCached(libraryID, tt.entity, id, tt.before, tt.after)
+
+			sort.Strings(recorder.invalidated)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(recorder.invalidated, tt.want) {
+				t.Errorf("invalidated %v, want %v", recorder.invalidated, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidateCachedDropsCachedResponses(t *testing.T) {
+	previous := responseCache
+	t.Cleanup(func() { responseCache = previous })
+	SetResponseCache(cache.NewLRU(10))
+
+	libraryID, otherLibraryID := primitive.NewObjectID(), primitive.NewObjectID()
+	bookID := primitive.NewObjectID()
+	responseCache.Set("book", []byte("{}"), time.Hour, entityTag(auditEntityBook, bookID))
+	responseCache.Set("books", []byte("[]"), time.Hour, listTag(libraryID, auditEntityBook))
+	responseCache.Set("other library", []byte("[]"), time.Hour, listTag(otherLibraryID, auditEntityBook))
+
+	invalidateCached(libraryID, auditEntityBook, bookID, nil, bson.M{"title": "Dune"})
+
+	for key, want := range map[string]bool{"book": false, "books": false, "other library": true} {
+		if _, ok := responseCache.Get(key); ok != want {
+			t.Errorf("%s cached = %v, want %v", key, ok, want)
+		}
+	}
+}
@@ -0,0 +1,806 @@
+package controller
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"example/books-api/model"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// several authors in one cell are separated by this
+	catalogAuthorSeparator = ";"
+	// the report keeps at most this many row errors so large imports stay bounded
+	maxImportErrors = 1000
+	exportFlushRows = 500
+
+	importActionCreate = "create"
+	importActionUpdate = "update"
+	importActionSkip   = "skip"
+	importActionError  = "error"
+)
+
+var exportColumns = []string{
+	"id", "title", "subtitle", "authors", "genre", "read", "publisher", "publicationYear", "publishedDate",
+	"edition", "pageCount", "language", "format", "isbn", "description", "createdAt", "updatedAt",
+}
+
+// fields an import column can be mapped onto, title and authors are required;
+// an export can be imported again as it is
+var importFields = []string{
+	"title", "subtitle", "authors", "genre", "read", "publisher", "publicationYear", "publishedDate",
+	"edition", "pageCount", "language", "format", "isbn", "description",
+}
+
+// one row of an import, independent of the file format it came from
+type catalogRecord struct {
+	Title         string
+	Subtitle      string
+	Description   string
+	Authors       []string
+	Genre         string
+	Genres        []primitive.ObjectID
+	Publisher     string
+	PublisherID   primitive.ObjectID
+	Year          int
+	PublishedDate string
+	Edition       string
+	PageCount     int
+	Language      string
+	Format        string
+	Read          *bool
+	ReadingStatus string
+	Rating        float64
+	DateRead      *time.Time
+	Shelves       []string
+	ISBN10        string
+	ISBN13        string
+}
+
+// turns one CSV row into a record, built from the header row of the file
+type catalogRowParser func(values []string) (catalogRecord, error)
+
+type catalogImporter struct {
+	req     requestInfo
+	authors map[string]primitive.ObjectID
+	report  model.ImportReport
+	// a dry run resolves everything but writes nothing; authors and books it
+	// would create are remembered so later rows in the same file match them
+	pendingAuthors map[primitive.ObjectID]bool
+	pendingBooks   map[string]bool
+}
+
+func newCatalogImporter(req requestInfo, source string, dryRun bool) *catalogImporter {
+	return &catalogImporter{
+		req:            req,
+		authors:        map[string]primitive.ObjectID{},
+		report:         model.ImportReport{Source: source, DryRun: dryRun},
+		pendingAuthors: map[primitive.ObjectID]bool{},
+		pendingBooks:   map[string]bool{},
+	}
+}
+
+func (imp *catalogImporter) fail(row int, err error) {
+	imp.report.Errored++
+	if len(imp.report.Errors) < maxImportErrors {
+		imp.report.Errors = append(imp.report.Errors, model.ImportRowError{Row: row, Error: err.Error()})
+	}
+}
+
+func (imp *catalogImporter) preview(row int, action string, record catalogRecord, err error) {
+	if !imp.report.DryRun || len(imp.report.Preview) >= maxImportErrors {
+		return
+	}
+	previewRow := model.ImportPreviewRow{
+		Row:           row,
+		Action:        action,
+		Title:         record.Title,
+		Authors:       record.Authors,
+		ReadingStatus: record.ReadingStatus,
+		Rating:        record.Rating,
+		DateRead:      record.DateRead,
+		Shelves:       record.Shelves,
+		ISBN10:        record.ISBN10,
+		ISBN13:        record.ISBN13,
+	}
+	if err != nil {
+		previewRow.Error = err.Error()
+	}
+	imp.report.Preview = append(imp.report.Preview, previewRow)
+}
+
+// case-insensitive exact match, so "ursula k. le guin" finds "Ursula K. Le Guin"
+func exactNameFilter(value string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(value) + "$", Options: "i"}
+}
+
+// find an author of the library by name or pen name, creating it when it does not exist yet
+func (imp *catalogImporter) resolveAuthor(name string) (primitive.ObjectID, error) {
+	key := strings.ToLower(name)
+	if id, ok := imp.authors[key]; ok {
+		return id, nil
+	}
+
+	var existing model.Author
+	nameFilter := exactNameFilter(name)
+	filter := scoped(imp.req.LibraryID, bson.M{"$or": []bson.M{{"name": nameFilter}, {"aliases": nameFilter}}})
+	err := collection.FindOne(context.Background(), filter).Decode(&existing)
+	if err == nil {
+		imp.authors[key] = existing.ID
+		return existing.ID, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return primitive.NilObjectID, err
+	}
+
+	if err := checkQuota(imp.req.LibraryID, auditEntityAuthor, 1); err == errQuotaExceeded {
+		return primitive.NilObjectID, errors.New("author quota exceeded for this library")
+	} else if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	if imp.report.DryRun {
+		id := primitive.NewObjectID()
+		imp.pendingAuthors[id] = true
+		imp.report.AuthorsCreated++
+		imp.authors[key] = id
+		return id, nil
+	}
+
+	author := model.Author{Name: name, Books: []primitive.ObjectID{}}
+	if err := insertAuthor(&author, imp.req); err != nil {
+		return primitive.NilObjectID, err
+	}
+	imp.report.AuthorsCreated++
+	imp.authors[key] = author.ID
+	return author.ID, nil
+}
+
+// a book of the library with the same title (ignoring case) and exactly the same authors;
+// editors, translators and illustrators are not compared
+func findCatalogBook(libraryID primitive.ObjectID, title string, authorIDs []primitive.ObjectID) (*model.Book, error) {
+	cursor, err := bookCollection.Find(context.Background(), scoped(libraryID, bson.M{"title": exactNameFilter(title)}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	want := map[primitive.ObjectID]bool{}
+	for _, id := range authorIDs {
+		want[id] = true
+	}
+
+	for cursor.Next(context.Background()) {
+		var book model.Book
+		if err := cursor.Decode(&book); err != nil {
+			return nil, err
+		}
+		linkFilter := scoped(libraryID, bson.M{"book": book.ID, "role": roleFilter(model.AuthorRoleAuthor)})
+		linked, err := bookAuthor.Distinct(context.Background(), "author", linkFilter)
+		if err != nil {
+			return nil, err
+		}
+		if len(linked) != len(want) {
+			continue
+		}
+		same := true
+		for _, value := range linked {
+			if id, ok := value.(primitive.ObjectID); !ok || !want[id] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return &book, nil
+		}
+	}
+
+	return nil, cursor.Err()
+}
+
+func updateImportedBook(id primitive.ObjectID, set bson.M, req requestInfo) error {
+	revision := captureRevision(bookRevisionTarget(), id, req)
+	before := snapshot(bookCollection, id)
+
+	// Imported values are user data, so enrichment must not overwrite them later
+	var imported []string
+	for field := range set {
+		imported = append(imported, field)
+	}
+	update := bson.M{"$set": stampUpdated(set, req.Actor), "$pull": bson.M{"enrichedFields": bson.M{"$in": imported}}}
+	if _, err := bookCollection.UpdateOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}), update); err != nil {
+		return err
+	}
+	if err := storeRevision(context.Background(), revision); err != nil {
+		return err
+	}
+
+	recordAudit(req, auditEntityBook, auditActionUpdate, id, before, snapshot(bookCollection, id))
+	return nil
+}
+
+func (imp *catalogImporter) apply(row int, record catalogRecord) {
+	action, err := imp.importRecord(record)
+	switch {
+	case err != nil:
+		action = importActionError
+		imp.fail(row, err)
+	case action == importActionCreate:
+		imp.report.Created++
+	case action == importActionUpdate:
+		imp.report.Updated++
+	default:
+		imp.report.Skipped++
+	}
+	imp.preview(row, action, record, err)
+}
+
+// dedupe key of a book that a dry run would create
+func pendingBookKey(title string, authorIDs []primitive.ObjectID) string {
+	ids := make([]string, len(authorIDs))
+	for i, id := range authorIDs {
+		ids[i] = id.Hex()
+	}
+	sort.Strings(ids)
+	return strings.ToLower(title) + "|" + strings.Join(ids, ",")
+}
+
+// the fields of an existing book that an imported record changes, empty values change nothing
+func importChanges(existing *model.Book, record catalogRecord) bson.M {
+	set := bson.M{}
+	for field, values := range map[string][2]string{
+		"subtitle":      {record.Subtitle, existing.Subtitle},
+		"description":   {record.Description, existing.Description},
+		"genre":         {record.Genre, existing.Genre},
+		"publisher":     {record.Publisher, existing.Publisher},
+		"publishedDate": {record.PublishedDate, existing.PublishedDate},
+		"edition":       {record.Edition, existing.Edition},
+		"language":      {record.Language, existing.Language},
+		"format":        {record.Format, existing.Format},
+		"isbn10":        {record.ISBN10, existing.ISBN10},
+		"isbn13":        {record.ISBN13, existing.ISBN13},
+	} {
+		if values[0] != "" && values[0] != values[1] {
+			set[field] = values[0]
+		}
+	}
+	for field, values := range map[string][2]int{
+		"publicationYear": {record.Year, existing.PublicationYear},
+		"pageCount":       {record.PageCount, existing.PageCount},
+	} {
+		if values[0] != 0 && values[0] != values[1] {
+			set[field] = values[0]
+		}
+	}
+	if !record.PublisherID.IsZero() && record.PublisherID != existing.PublisherID {
+		set["publisherId"] = record.PublisherID
+	} else if record.PublisherID.IsZero() && !existing.PublisherID.IsZero() {
+		// a name no publisher is known by never replaces the name of a linked publisher
+		delete(set, "publisher")
+	}
+	// Genres are merged, an import never takes a genre off a book
+	genres := append([]primitive.ObjectID{}, existing.Genres...)
+	for _, genreID := range record.Genres {
+		if !containsID(genres, genreID) {
+			genres = append(genres, genreID)
+		}
+	}
+	if len(genres) > len(existing.Genres) {
+		set["genres"] = genres
+	}
+	return set
+}
+
+// the importing reader's state of a book after a record, empty values keep the current ones;
+// false when the record changes nothing
+func importedState(state model.ReadingState, record catalogRecord) (model.ReadingState, bool) {
+	changed := false
+	if record.ReadingStatus != "" && record.ReadingStatus != state.ReadingStatus {
+		state.ReadingStatus, changed = record.ReadingStatus, true
+	}
+	if record.Read != nil && *record.Read != state.Read {
+		state.Read, changed = *record.Read, true
+	}
+	if record.Rating != 0 && record.Rating != state.Rating {
+		state.Rating, changed = record.Rating, true
+	}
+	if record.DateRead != nil && (state.DateRead == nil || !record.DateRead.Equal(*state.DateRead)) {
+		state.DateRead, changed = record.DateRead, true
+	}
+
+	// Shelves are merged, an import never takes a book off a shelf
+	shelves := append([]string{}, state.Shelves...)
+	for _, shelf := range record.Shelves {
+		found := false
+		for _, current := range shelves {
+			if strings.EqualFold(current, shelf) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			shelves = append(shelves, shelf)
+		}
+	}
+	if len(shelves) > len(state.Shelves) {
+		state.Shelves, changed = shelves, true
+	}
+	return state, changed
+}
+
+// create the book, update the fields that changed or skip it when nothing did
+func (imp *catalogImporter) importRecord(record catalogRecord) (string, error) {
+	if record.Title == "" {
+		return "", errors.New("title is required")
+	}
+	if len(record.Authors) == 0 {
+		return "", errors.New("at least one author is required")
+	}
+	identifiers := model.Book{ISBN10: record.ISBN10, ISBN13: record.ISBN13}
+	if err := normalizeBookISBN(&identifiers); err != nil {
+		return "", err
+	}
+	record.ISBN10, record.ISBN13 = identifiers.ISBN10, identifiers.ISBN13
+
+	details := model.Book{
+		Genre:           record.Genre,
+		Publisher:       record.Publisher,
+		PublicationYear: record.Year,
+		PublishedDate:   record.PublishedDate,
+		PageCount:       record.PageCount,
+		Language:        record.Language,
+		Format:          record.Format,
+	}
+	if err := normalizeBookDetails(&details); err != nil {
+		return "", err
+	}
+	record.Year, record.PublishedDate = details.PublicationYear, details.PublishedDate
+	record.Language, record.Format = details.Language, details.Format
+
+	// Genres the taxonomy knows are linked, the rest is kept as free text
+	if err := resolveBookGenre(imp.req.LibraryID, &details); err != nil {
+		return "", err
+	}
+	record.Genre, record.Genres = details.Genre, details.Genres
+	if err := resolveBookPublisher(imp.req.LibraryID, &details); err != nil {
+		return "", err
+	}
+	record.Publisher, record.PublisherID = details.Publisher, details.PublisherID
+
+	var authorIDs []primitive.ObjectID
+	seen := map[primitive.ObjectID]bool{}
+	pending := false
+	for _, name := range record.Authors {
+		id, err := imp.resolveAuthor(name)
+		if err != nil {
+			return "", err
+		}
+		if !seen[id] {
+			seen[id] = true
+			authorIDs = append(authorIDs, id)
+		}
+		pending = pending || imp.pendingAuthors[id]
+	}
+
+	// A matching ISBN identifies the book regardless of title and authors
+	var existing *model.Book
+	if record.ISBN13 != "" {
+		var err error
+		if existing, err = findBookByISBN(imp.req.LibraryID, record.ISBN13); err != nil {
+			return "", err
+		}
+	}
+	if existing == nil && !pending {
+		var err error
+		if existing, err = findCatalogBook(imp.req.LibraryID, record.Title, authorIDs); err != nil {
+			return "", err
+		}
+	}
+
+	if existing == nil {
+		key := pendingBookKey(record.Title, authorIDs)
+		if imp.pendingBooks[key] || (record.ISBN13 != "" && imp.pendingBooks[record.ISBN13]) {
+			return importActionSkip, nil
+		}
+		if err := checkQuota(imp.req.LibraryID, auditEntityBook, 1); err == errQuotaExceeded {
+			return "", errors.New("book quota exceeded for this library")
+		} else if err != nil {
+			return "", err
+		}
+		if imp.report.DryRun {
+			imp.pendingBooks[key] = true
+			if record.ISBN13 != "" {
+				imp.pendingBooks[record.ISBN13] = true
+			}
+			return importActionCreate, nil
+		}
+
+		book := model.Book{
+			Title:           record.Title,
+			Subtitle:        record.Subtitle,
+			Description:     record.Description,
+			Publisher:       record.Publisher,
+			PublisherID:     record.PublisherID,
+			PublicationYear: record.Year,
+			PublishedDate:   record.PublishedDate,
+			Edition:         record.Edition,
+			PageCount:       record.PageCount,
+			Language:        record.Language,
+			Format:          record.Format,
+			Genre:           record.Genre,
+			Genres:          record.Genres,
+			Authors:         authorIDs,
+			ReadingStatus:   record.ReadingStatus,
+			Rating:          record.Rating,
+			DateRead:        record.DateRead,
+			Shelves:         record.Shelves,
+			ISBN10:          record.ISBN10,
+			ISBN13:          record.ISBN13,
+		}
+		if record.Read != nil {
+			book.Read = *record.Read
+		}
+		contributors, err := bookContributors(&book)
+		if err != nil {
+			return "", err
+		}
+		if err := insertBook(&book, contributors, imp.req); err != nil {
+			return "", err
+		}
+		return importActionCreate, nil
+	}
+
+	set := importChanges(existing, record)
+
+	// Read, rating, date and shelves are the importing reader's own
+	states, err := readingStatesFor(imp.req.LibraryID, imp.req.Actor, []primitive.ObjectID{existing.ID})
+	if err != nil {
+		return "", err
+	}
+	state, stateChanged := importedState(states[existing.ID], record)
+
+	if len(set) == 0 && !stateChanged {
+		return importActionSkip, nil
+	}
+	if imp.report.DryRun {
+		return importActionUpdate, nil
+	}
+	if len(set) > 0 {
+		if err := updateImportedBook(existing.ID, set, imp.req); err != nil {
+			return "", err
+		}
+	}
+	if stateChanged {
+		if _, err := setReadingState(existing.ID, state, imp.req); err != nil {
+			return "", err
+		}
+	}
+	return importActionUpdate, nil
+}
+
+// split an author cell, e.g. "Terry Pratchett; Neil Gaiman"
+func splitAuthorNames(value string, separator string) []string {
+	var names []string
+	for _, name := range strings.Split(value, separator) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func parseReadValue(value string) (*bool, error) {
+	var read bool
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return nil, nil
+	case "true", "yes", "y", "1", "read":
+		read = true
+	case "false", "no", "n", "0", "unread", "to-read":
+		read = false
+	default:
+		return nil, fmt.Errorf("invalid read value %q", value)
+	}
+	return &read, nil
+}
+
+// parse ?mapping=title:Book Title,authors:Written By into field -> column header
+func parseColumnMapping(value string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, field := range importFields {
+		mapping[field] = field
+	}
+	if value == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		field, column, ok := strings.Cut(pair, ":")
+		field = strings.TrimSpace(field)
+		if !ok || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("invalid mapping %q: expected field:column", pair)
+		}
+		if _, known := mapping[field]; !known {
+			return nil, fmt.Errorf("unknown mapping field %q", field)
+		}
+		mapping[field] = strings.TrimSpace(column)
+	}
+	return mapping, nil
+}
+
+// position of each mapped field in the header row, -1 when the column is absent
+func columnIndexes(header []string, mapping map[string]string) (map[string]int, error) {
+	indexes := map[string]int{}
+	for field, column := range mapping {
+		indexes[field] = -1
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), column) {
+				indexes[field] = i
+				break
+			}
+		}
+	}
+	if indexes["title"] < 0 {
+		return nil, fmt.Errorf("missing title column %q", mapping["title"])
+	}
+	if indexes["authors"] < 0 {
+		return nil, fmt.Errorf("missing authors column %q", mapping["authors"])
+	}
+	return indexes, nil
+}
+
+// the uploaded file, either the "file" part of a multipart form or the raw request body;
+// multipart parts are read as they arrive instead of being spooled to disk
+func importReader(c *gin.Context) (io.Reader, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return c.Request.Body, nil
+	}
+
+	parts, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("missing file field")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+// a parser for the generic CSV format, using the column mapping
+func mappedRowParser(mapping map[string]string) func(header []string) (catalogRowParser, error) {
+	return func(header []string) (catalogRowParser, error) {
+		indexes, err := columnIndexes(header, mapping)
+		if err != nil {
+			return nil, err
+		}
+
+		column := func(values []string, field string) string {
+			if i := indexes[field]; i >= 0 && i < len(values) {
+				return strings.TrimSpace(values[i])
+			}
+			return ""
+		}
+
+		return func(values []string) (catalogRecord, error) {
+			record := catalogRecord{
+				Title:         column(values, "title"),
+				Subtitle:      column(values, "subtitle"),
+				Description:   column(values, "description"),
+				Authors:       splitAuthorNames(column(values, "authors"), catalogAuthorSeparator),
+				Genre:         column(values, "genre"),
+				Publisher:     column(values, "publisher"),
+				PublishedDate: column(values, "publishedDate"),
+				Edition:       column(values, "edition"),
+				Language:      column(values, "language"),
+				Format:        column(values, "format"),
+				ISBN13:        column(values, "isbn"),
+			}
+
+			var err error
+			if record.Read, err = parseReadValue(column(values, "read")); err != nil {
+				return record, err
+			}
+			if record.Year, err = parseImportInt(column(values, "publicationYear"), "publicationYear"); err != nil {
+				return record, err
+			}
+			if record.PageCount, err = parseImportInt(column(values, "pageCount"), "pageCount"); err != nil {
+				return record, err
+			}
+			return record, nil
+		}, nil
+	}
+}
+
+// read the CSV row by row and import each one as soon as it is parsed
+func importRows(r io.Reader, newParser func(header []string) (catalogRowParser, error), imp *catalogImporter) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("empty file")
+	}
+	if err != nil {
+		return err
+	}
+	// Exports saved from spreadsheet tools often start with a byte order mark
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	parse, err := newParser(header)
+	if err != nil {
+		return err
+	}
+
+	for row := 2; ; row++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			imp.fail(row, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		record, err := parse(values)
+		if err != nil {
+			imp.fail(row, err)
+			imp.preview(row, importActionError, record, err)
+			continue
+		}
+		imp.apply(row, record)
+	}
+}
+
+// books with their author names, read with a cursor so the export is never held in memory
+func exportCursor(libraryID primitive.ObjectID, actor string, match bson.M, sort bson.D) (*mongo.Cursor, error) {
+	if len(sort) == 0 {
+		sort = bson.D{{Key: "_id", Value: 1}}
+	}
+
+	pipeline := []bson.M{{"$match": scoped(libraryID, match)}}
+	pipeline = append(pipeline, contributorStages(libraryID)...)
+	pipeline = append(pipeline, genreStages(libraryID)...)
+	pipeline = append(pipeline,
+		bson.M{"$project": bookProjection(bson.M{"_id": 1, "contributors": 1})},
+	)
+	// The read column is the exporting reader's own
+	pipeline = append(pipeline, readingStateStages(libraryID, actor)...)
+	pipeline = append(pipeline, bson.M{"$sort": sort})
+
+	return bookCollection.Aggregate(context.Background(), pipeline)
+}
+
+// genre names of a book followed by any free text not mapped onto the taxonomy yet,
+// in the form the import splits again
+func exportGenre(book model.BookWithAuthor) string {
+	var values []string
+	for _, genre := range book.Genres {
+		values = append(values, genre.Name)
+	}
+	if book.Genre != "" {
+		values = append(values, book.Genre)
+	}
+	return strings.Join(values, "; ")
+}
+
+func formatExportInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func ExportCSV(c *gin.Context) {
+	match, sort, err := timestampListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cursor, err := exportCursor(requestFrom(c).LibraryID, requestFrom(c).Actor, match, sort)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error exporting books"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="books.csv"`)
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(exportColumns)
+
+	for rows := 1; cursor.Next(context.Background()); rows++ {
+		var book model.BookWithAuthor
+		if err := cursor.Decode(&book); err != nil {
+			continue
+		}
+		groupContributors(&book)
+		names := make([]string, len(book.Authors))
+		for i, author := range book.Authors {
+			names[i] = author.Name
+		}
+		writer.Write([]string{
+			book.ID.Hex(),
+			book.Title,
+			book.Subtitle,
+			strings.Join(names, catalogAuthorSeparator+" "),
+			exportGenre(book),
+			fmt.Sprint(book.Read),
+			book.Publisher,
+			formatExportInt(book.PublicationYear),
+			book.PublishedDate,
+			book.Edition,
+			formatExportInt(book.PageCount),
+			book.Language,
+			book.Format,
+			book.ISBN13,
+			book.Description,
+			formatExportTime(book.CreatedAt),
+			formatExportTime(book.UpdatedAt),
+		})
+		if rows%exportFlushRows == 0 {
+			writer.Flush()
+		}
+	}
+
+	writer.Flush()
+}
+
+func ImportCSV(c *gin.Context) {
+	mapping, err := parseColumnMapping(c.Query("mapping"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reader, err := importReader(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	imp := newCatalogImporter(requestFrom(c), importSourceCSV, c.Query("dryRun") == "true")
+	if err := importRows(reader, mappedRowParser(mapping), imp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": imp.report})
+		return
+	}
+
+	c.JSON(http.StatusOK, imp.report)
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"example/books-api/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestParseReadValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *bool
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: " ", want: nil},
+		{value: "true", want: boolPtr(true)},
+		{value: "Yes", want: boolPtr(true)},
+		{value: "read", want: boolPtr(true)},
+		{value: "1", want: boolPtr(true)},
+		{value: "false", want: boolPtr(false)},
+		{value: "to-read", want: boolPtr(false)},
+		{value: "N", want: boolPtr(false)},
+		{value: "maybe", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseReadValue(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseReadValue(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseReadValue(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSplitAuthorNames(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "Terry Pratchett; Neil Gaiman", want: []string{"Terry Pratchett", "Neil Gaiman"}},
+		{value: " Frank Herbert ", want: []string{"Frank Herbert"}},
+		{value: "Le Guin, Ursula K.", want: []string{"Le Guin, Ursula K."}},
+		{value: ";;", want: nil},
+		{value: "", want: nil},
+	}
+	for _, tt := range tests {
+		if got := splitAuthorNames(tt.value, catalogAuthorSeparator); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitAuthorNames(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseColumnMapping(t *testing.T) {
+	tests := []struct {
+		value   string
+		changed map[string]string
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "title:Book Title, authors : Written By", changed: map[string]string{"title": "Book Title", "authors": "Written By"}},
+		{value: "isbn:ISBN13", changed: map[string]string{"isbn": "ISBN13"}},
+		{value: "title", wantErr: true},
+		{value: "title:", wantErr: true},
+		{value: "rating:My Rating", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseColumnMapping(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseColumnMapping(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		// Fields that are not mapped read the column of the same name
+		want := map[string]string{}
+		for _, field := range importFields {
+			want[field] = field
+		}
+		for field, column := range tt.changed {
+			want[field] = column
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parseColumnMapping(%q) = %v, want %v", tt.value, got, want)
+		}
+	}
+}
+
+func TestMappedRowParser(t *testing.T) {
+	defaults, _ := parseColumnMapping("")
+	renamed, _ := parseColumnMapping("title:Book Title,authors:Written By")
+
+	tests := []struct {
+		name       string
+		mapping    map[string]string
+		header     []string
+		values     []string
+		want       catalogRecord
+		wantHeader bool
+		wantErr    bool
+	}{
+		{
+			name:    "export columns",
+			mapping: defaults,
+			header:  []string{"id", "Title", "authors", "read", "publicationYear", "pageCount", "format", "isbn"},
+			values:  []string{"64b0", " Good Omens ", "Terry Pratchett; Neil Gaiman", "yes", "1990", "288", "paperback", "9780060853983"},
+			want: catalogRecord{
+				Title:     "Good Omens",
+				Authors:   []string{"Terry Pratchett", "Neil Gaiman"},
+				Read:      boolPtr(true),
+				Year:      1990,
+				PageCount: 288,
+				Format:    "paperback",
+				ISBN13:    "9780060853983",
+			},
+		},
+		{
+			name:    "mapped columns, short row",
+			mapping: renamed,
+			header:  []string{"Written By", "Book Title", "genre"},
+			values:  []string{"Frank Herbert", "Dune"},
+			want:    catalogRecord{Title: "Dune", Authors: []string{"Frank Herbert"}},
+		},
+		{
+			name:       "missing title column",
+			mapping:    renamed,
+			header:     []string{"title", "Written By"},
+			wantHeader: true,
+		},
+		{
+			name:       "missing authors column",
+			mapping:    defaults,
+			header:     []string{"title", "author"},
+			wantHeader: true,
+		},
+		{
+			name:    "invalid year",
+			mapping: defaults,
+			header:  []string{"title", "authors", "publicationYear"},
+			values:  []string{"Dune", "Frank Herbert", "nineteen sixty-five"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid read value",
+			mapping: defaults,
+			header:  []string{"title", "authors", "read"},
+			values:  []string{"Dune", "Frank Herbert", "halfway"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parse, err := mappedRowParser(tt.mapping)(tt.header)
+			if tt.wantHeader {
+				if err == nil {
+					t.Fatalf("header %q was accepted", tt.header)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("header error: %v", err)
+			}
+
+			got, err := parse(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportedState(t *testing.T) {
+	read := datePtr(2021, time.March, 4)
+	current := model.ReadingState{ReadingStatus: model.ReadingStatusReading, Rating: 3, Shelves: []string{"Owned"}}
+
+	tests := []struct {
+		name    string
+		record  catalogRecord
+		want    model.ReadingState
+		changed bool
+	}{
+		{name: "empty record", want: current},
+		{name: "same values", record: catalogRecord{ReadingStatus: model.ReadingStatusReading, Rating: 3, Shelves: []string{"owned"}}, want: current},
+		{
+			name:   "finished with a rating and a new shelf",
+			record: catalogRecord{ReadingStatus: model.ReadingStatusRead, Read: boolPtr(true), Rating: 4.5, DateRead: read, Shelves: []string{"owned", "favorites"}},
+			want: model.ReadingState{
+				Read: true, ReadingStatus: model.ReadingStatusRead, Rating: 4.5, DateRead: read, Shelves: []string{"Owned", "favorites"},
+			},
+			changed: true,
+		},
+	}
+	for _, tt := range tests {
+		got, changed := importedState(current, tt.record)
+		if changed != tt.changed || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: importedState = %+v, %v, want %+v, %v", tt.name, got, changed, tt.want, tt.changed)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"example/books-api/citation"
+	"example/books-api/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// authors of each book in citation order: the order they were given on the book,
+// then any author linked later
+func orderedBookAuthors(book model.Book, linked []primitive.ObjectID) []primitive.ObjectID {
+	isLinked := map[primitive.ObjectID]bool{}
+	for _, id := range linked {
+		isLinked[id] = true
+	}
+
+	var ordered []primitive.ObjectID
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range append(append([]primitive.ObjectID{}, book.Authors...), linked...) {
+		if isLinked[id] && !seen[id] {
+			seen[id] = true
+			ordered = append(ordered, id)
+		}
+	}
+	return ordered
+}
+
+// the citation of one book by its author names in citation order
+func citationEntry(book model.Book, authors []string) citation.Entry {
+	entry := citation.Entry{
+		ID:        book.ID.Hex(),
+		Title:     book.Title,
+		Subtitle:  book.Subtitle,
+		Publisher: book.Publisher,
+		Year:      book.PublicationYear,
+		ISBN:      book.ISBN13,
+	}
+	if entry.ISBN == "" {
+		entry.ISBN = book.ISBN10
+	}
+	for _, name := range authors {
+		entry.Authors = append(entry.Authors, citation.ParseName(name))
+	}
+	return entry
+}
+
+// citation entries for the books of a library matching a filter
+func citationEntries(libraryID primitive.ObjectID, match bson.M, sort bson.D) ([]citation.Entry, error) {
+	if len(sort) == 0 {
+		sort = bson.D{{Key: "_id", Value: 1}}
+	}
+
+	var books []model.Book
+	cursor, err := bookCollection.Find(context.Background(), scoped(libraryID, match), options.Find().SetSort(sort))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.Background(), &books); err != nil {
+		return nil, err
+	}
+	if len(books) == 0 {
+		return nil, nil
+	}
+
+	bookIDs := make([]primitive.ObjectID, len(books))
+	for i, book := range books {
+		bookIDs[i] = book.ID
+	}
+
+	var links []model.BookAuthor
+	// Only authors are cited, editors, translators and illustrators are not
+	linkFilter := scoped(libraryID, bson.M{"book": bson.M{"$in": bookIDs}, "role": roleFilter(model.AuthorRoleAuthor)})
+	cursor, err = bookAuthor.Find(context.Background(), linkFilter, options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.Background(), &links); err != nil {
+		return nil, err
+	}
+
+	linked := map[primitive.ObjectID][]primitive.ObjectID{}
+	var authorIDs []primitive.ObjectID
+	for _, link := range links {
+		linked[link.Book] = append(linked[link.Book], link.Author)
+		authorIDs = append(authorIDs, link.Author)
+	}
+
+	var authors []model.Author
+	cursor, err = collection.Find(context.Background(), scoped(libraryID, bson.M{"_id": bson.M{"$in": authorIDs}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.Background(), &authors); err != nil {
+		return nil, err
+	}
+	names := map[primitive.ObjectID]string{}
+	for _, author := range authors {
+		names[author.ID] = author.Name
+	}
+
+	entries := make([]citation.Entry, len(books))
+	for i, book := range books {
+		var bookAuthors []string
+		for _, authorID := range orderedBookAuthors(book, linked[book.ID]) {
+			if name, ok := names[authorID]; ok {
+				bookAuthors = append(bookAuthors, name)
+			}
+		}
+		entries[i] = citationEntry(book, bookAuthors)
+	}
+	return entries, nil
+}
+
+// the requested citation format, answering 400 when it is missing or unknown
+func citationFormat(c *gin.Context) (string, bool) {
+	format := c.Query("format")
+	if _, ok := citation.ContentType(format); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be bibtex, ris, csl-json, apa or mla"})
+		return "", false
+	}
+	return format, true
+}
+
+func respondCitation(c *gin.Context, format string, entries []citation.Entry) {
+	data, err := citation.Format(format, entries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error formatting citation"})
+		return
+	}
+
+	contentType, _ := citation.ContentType(format)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+func CiteBook(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("bookId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+	format, ok := citationFormat(c)
+	if !ok {
+		return
+	}
+
+	entries, err := citationEntries(requestFrom(c).LibraryID, bson.M{"_id": id}, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error loading book"})
+		return
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	respondCitation(c, format, entries)
+}
+
+// GET /book/cite?format=...&<book list filters>
+func CiteBooks(c *gin.Context) {
+	match, sort, err := bookListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, ok := citationFormat(c)
+	if !ok {
+		return
+	}
+
+	entries, err := citationEntries(requestFrom(c).LibraryID, match, sort)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error loading books"})
+		return
+	}
+
+	respondCitation(c, format, entries)
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"example/books-api/citation"
+	"example/books-api/model"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestOrderedBookAuthors(t *testing.T) {
+	a, b, c := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	tests := []struct {
+		name   string
+		given  []primitive.ObjectID
+		linked []primitive.ObjectID
+		want   []primitive.ObjectID
+	}{
+		{name: "book order wins", given: []primitive.ObjectID{b, a}, linked: []primitive.ObjectID{a, b}, want: []primitive.ObjectID{b, a}},
+		{name: "authors linked later come last", given: []primitive.ObjectID{b}, linked: []primitive.ObjectID{c, b}, want: []primitive.ObjectID{b, c}},
+		{name: "unlinked authors are left out", given: []primitive.ObjectID{a, b}, linked: []primitive.ObjectID{b}, want: []primitive.ObjectID{b}},
+		{name: "no links", given: []primitive.ObjectID{a}, want: nil},
+	}
+	for _, tt := range tests {
+		if got := orderedBookAuthors(model.Book{Authors: tt.given}, tt.linked); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: orderedBookAuthors = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCitationEntry(t *testing.T) {
+	id := primitive.NewObjectID()
+	book := model.Book{
+		ID:              id,
+		Title:           "The Left Hand of Darkness",
+		Subtitle:        "50th Anniversary Edition",
+		Publisher:       "Ace",
+		PublicationYear: 1969,
+		ISBN10:          "0441478123",
+	}
+
+	entry := citationEntry(book, []string{"Ursula K. Le Guin", "Harold Bloom"})
+	want := citation.Entry{
+		ID:        id.Hex(),
+		Title:     "The Left Hand of Darkness",
+		Subtitle:  "50th Anniversary Edition",
+		Publisher: "Ace",
+		Year:      1969,
+		ISBN:      "0441478123",
+		Authors:   []citation.Name{{Given: "Ursula K.", Family: "Le Guin"}, {Given: "Harold", Family: "Bloom"}},
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Fatalf("citationEntry = %+v, want %+v", entry, want)
+	}
+
+	book.ISBN13 = "9780441478125"
+	if got := citationEntry(book, nil).ISBN; got != book.ISBN13 {
+		t.Errorf("ISBN = %q, want the ISBN-13", got)
+	}
+
+	// The publisher and year reach every style
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: citation.FormatAPA, want: "Le Guin, U. K., & Bloom, H. (1969). The Left Hand of Darkness: 50th Anniversary Edition. Ace.\n"},
+		{format: citation.FormatMLA, want: "Le Guin, Ursula K., and Harold Bloom. The Left Hand of Darkness: 50th Anniversary Edition. Ace, 1969.\n"},
+	}
+	for _, tt := range tests {
+		data, err := citation.Format(tt.format, []citation.Entry{entry})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s = %q, want %q", tt.format, data, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"context"
+	"example/books-api/model"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// roles in the order a book lists them
+var authorRoles = []string{
+	model.AuthorRoleAuthor,
+	model.AuthorRoleEditor,
+	model.AuthorRoleTranslator,
+	model.AuthorRoleIllustrator,
+}
+
+func authorRoleRank(role string) int {
+	for i, known := range authorRoles {
+		if role == known {
+			return i
+		}
+	}
+	return -1
+}
+
+// bookAuthor condition for a role, links written before roles existed are authors
+func roleFilter(role string) interface{} {
+	if role == model.AuthorRoleAuthor {
+		return bson.M{"$in": []interface{}{role, nil}}
+	}
+	return role
+}
+
+// the contributors of a book, from contributors when given and otherwise from authors,
+// validated and sorted by role then position; book.Authors is set to their IDs in that order.
+// Positions count from 1 within each role, contributors without one keep the order they were given in
+func bookContributors(book *model.Book) ([]model.Contributor, error) {
+	contributors := book.Contributors
+	if contributors == nil {
+		if book.Authors == nil {
+			return nil, nil
+		}
+		contributors = make([]model.Contributor, len(book.Authors))
+		for i, authorID := range book.Authors {
+			contributors[i] = model.Contributor{Author: authorID}
+		}
+	}
+
+	seen := map[string]bool{}
+	ordered := make([]model.Contributor, len(contributors))
+	for i, contributor := range contributors {
+		contributor.Role = strings.ToLower(strings.TrimSpace(contributor.Role))
+		if contributor.Role == "" {
+			contributor.Role = model.AuthorRoleAuthor
+		}
+		if authorRoleRank(contributor.Role) < 0 {
+			return nil, fmt.Errorf("invalid role %q: expected %s", contributor.Role, strings.Join(authorRoles, ", "))
+		}
+		if contributor.Author.IsZero() {
+			return nil, fmt.Errorf("contributor %d has no author", i+1)
+		}
+		if contributor.Position < 0 {
+			return nil, fmt.Errorf("contributor %d has a negative position", i+1)
+		}
+		key := contributor.Author.Hex() + "/" + contributor.Role
+		if seen[key] {
+			return nil, fmt.Errorf("author %s is listed twice as %s", contributor.Author.Hex(), contributor.Role)
+		}
+		seen[key] = true
+		ordered[i] = contributor
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if a.Role != b.Role {
+			return authorRoleRank(a.Role) < authorRoleRank(b.Role)
+		}
+		// unpositioned contributors go after the positioned ones
+		if (a.Position == 0) != (b.Position == 0) {
+			return b.Position == 0
+		}
+		return a.Position < b.Position
+	})
+
+	authorIDs := []primitive.ObjectID{}
+	listed := map[primitive.ObjectID]bool{}
+	for i := range ordered {
+		ordered[i].Position = 1
+		if i > 0 && ordered[i-1].Role == ordered[i].Role {
+			ordered[i].Position = ordered[i-1].Position + 1
+		}
+		if !listed[ordered[i].Author] {
+			listed[ordered[i].Author] = true
+			authorIDs = append(authorIDs, ordered[i].Author)
+		}
+	}
+
+	book.Contributors = ordered
+	book.Authors = authorIDs
+	return ordered, nil
+}
+
+// links for a new set of contributors
+func newLinks(bookID primitive.ObjectID, contributors []model.Contributor, req requestInfo) []model.BookAuthor {
+	links := make([]model.BookAuthor, len(contributors))
+	for i, contributor := range contributors {
+		links[i] = model.BookAuthor{
+			ID:        primitive.NewObjectID(),
+			Book:      bookID,
+			Author:    contributor.Author,
+			Role:      contributor.Role,
+			Position:  contributor.Position,
+			LibraryID: req.LibraryID,
+		}
+		stampCreated(&links[i].Timestamps, req.Actor)
+	}
+	return links
+}
+
+// replace the links of a book with the given contributors, keeping the authors' book lists in line
+func replaceBookLinks(bookID primitive.ObjectID, contributors []model.Contributor, req requestInfo) {
+	linkFilter := scoped(req.LibraryID, bson.M{"book": bookID})
+	linksBefore := snapshots(bookAuthor, linkFilter)
+	if _, err := bookAuthor.DeleteMany(context.Background(), linkFilter); err != nil {
+		log.Fatal(err)
+	}
+	for _, link := range linksBefore {
+		recordAudit(req, auditEntityBookAuthor, auditActionDelete, link["_id"].(primitive.ObjectID), link, nil)
+	}
+
+	pull := bson.M{"$pull": bson.M{"books": bookID}, "$set": stampUpdated(bson.M{}, req.Actor)}
+	if _, err := readingListCollection.UpdateMany(context.Background(), scoped(req.LibraryID, bson.M{"books": bookID}), pull); err != nil {
+		log.Fatal(err)
+	}
+
+	var authorIDs []primitive.ObjectID
+	for _, link := range newLinks(bookID, contributors, req) {
+		if _, err := bookAuthor.InsertOne(context.Background(), link); err != nil {
+			log.Fatal(err)
+		}
+		recordAudit(req, auditEntityBookAuthor, auditActionCreate, link.ID, nil, snapshot(bookAuthor, link.ID))
+		authorIDs = append(authorIDs, link.Author)
+	}
+
+	if len(authorIDs) > 0 {
+		add := bson.M{"$addToSet": bson.M{"books": bookID}, "$set": stampUpdated(bson.M{}, req.Actor)}
+		if _, err := readingListCollection.UpdateMany(context.Background(), scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": authorIDs}}), add); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// lookups adding the linked authors of each book as contributors {_id, name, role, position};
+// links to authors that no longer exist are left out
+func contributorStages(libraryID primitive.ObjectID) []bson.M {
+	return []bson.M{
+		scopedLookup("bookAuthor", "_id", "book", "bookAuthorRelations", libraryID),
+		scopedLookup("readList", "bookAuthorRelations.author", "_id", "linkedAuthors", libraryID),
+		{"$addFields": bson.M{"contributors": bson.M{"$map": bson.M{
+			"input": bson.M{"$filter": bson.M{
+				"input": "$bookAuthorRelations",
+				"as":    "link",
+				"cond":  bson.M{"$in": []interface{}{"$$link.author", "$linkedAuthors._id"}},
+			}},
+			"as": "link",
+			"in": bson.M{
+				"_id": "$$link.author",
+				"name": bson.M{"$arrayElemAt": []interface{}{
+					"$linkedAuthors.name",
+					bson.M{"$indexOfArray": []interface{}{"$linkedAuthors._id", "$$link.author"}},
+				}},
+				"role":     "$$link.role",
+				"position": "$$link.position",
+			},
+		}}}},
+	}
+}
+
+// split the contributors of a book into its role lists, each ordered by position;
+// links without a position keep the order they were created in
+func groupContributors(book *model.BookWithAuthor) {
+	sort.SliceStable(book.Contributors, func(i, j int) bool {
+		return book.Contributors[i].Position < book.Contributors[j].Position
+	})
+
+	book.Authors, book.Editors, book.Translators, book.Illustrators = nil, nil, nil, nil
+	for _, contributor := range book.Contributors {
+		switch contributor.Role {
+		case model.AuthorRoleEditor:
+			book.Editors = append(book.Editors, contributor)
+		case model.AuthorRoleTranslator:
+			book.Translators = append(book.Translators, contributor)
+		case model.AuthorRoleIllustrator:
+			book.Illustrators = append(book.Illustrators, contributor)
+		default:
+			book.Authors = append(book.Authors, contributor)
+		}
+	}
+	if book.Authors == nil {
+		book.Authors = []model.AuthorInfo{}
+	}
+}
+
+// $match conditions for ?author=, ?editor=, ?translator= and ?illustrator=, each an
+// author ID or a name or pen name; a book matches when that author has that role on it
+func contributorConditions(c *gin.Context, libraryID primitive.ObjectID) ([]bson.M, error) {
+	var conditions []bson.M
+	for _, role := range authorRoles {
+		value := strings.TrimSpace(c.Query(role))
+		if value == "" {
+			continue
+		}
+
+		authorFilter := bson.M{}
+		if id, err := primitive.ObjectIDFromHex(value); err == nil {
+			authorFilter["_id"] = id
+		} else {
+			name := exactNameFilter(value)
+			authorFilter["$or"] = []bson.M{{"name": name}, {"aliases": name}}
+		}
+		authorIDs, err := collection.Distinct(context.Background(), "_id", scoped(libraryID, authorFilter))
+		if err != nil {
+			return nil, err
+		}
+
+		bookIDs := []interface{}{}
+		if len(authorIDs) > 0 {
+			linkFilter := scoped(libraryID, bson.M{"author": bson.M{"$in": authorIDs}, "role": roleFilter(role)})
+			if bookIDs, err = bookAuthor.Distinct(context.Background(), "book", linkFilter); err != nil {
+				return nil, err
+			}
+		}
+		conditions = append(conditions, bson.M{"_id": bson.M{"$in": append([]interface{}{}, bookIDs...)}})
+	}
+	return conditions, nil
+}
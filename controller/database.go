@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// each controller registers the collections and settings it needs from its init,
+// they are set up by Setup once the environment is loaded
+var setups []func(db *mongo.Database) error
+
+func onSetup(setup func(db *mongo.Database) error) {
+	setups = append(setups, setup)
+}
+
+// Setup connects to CONNECTION_STRING and prepares every controller,
+// call it after loading the environment and before serving or running a command
+func Setup() error {
+	clientOptions := options.Client().ApplyURI(os.Getenv("CONNECTION_STRING"))
+
+	client, err := mongo.Connect(context.TODO(), clientOptions)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Mongodb connection success")
+
+	db := client.Database(os.Getenv("DBNAME"))
+	for _, setup := range setups {
+		if err := setup(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/isbn"
+	"example/books-api/metadata"
+	"example/books-api/model"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var metadataProvider metadata.Provider
+
+var (
+	errBookNotFound = errors.New("book not found")
+	errNoISBN       = errors.New("book has no ISBN")
+	errNoProvider   = errors.New("no metadata provider configured")
+)
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		provider, err := metadata.NewProviderFromEnv()
+		if err != nil {
+			return fmt.Errorf("configuring metadata providers: %w", err)
+		}
+		metadataProvider = provider
+		return nil
+	})
+}
+
+// SetMetadataProvider replaces the providers configured from the environment, e.g. with a local stub
+func SetMetadataProvider(provider metadata.Provider) {
+	metadataProvider = provider
+}
+
+// pointers to the book fields a provider can fill, by their bson name
+func enrichableFields(book *model.Book) map[string]interface{} {
+	return map[string]interface{}{
+		"title":           &book.Title,
+		"subtitle":        &book.Subtitle,
+		"description":     &book.Description,
+		"publisher":       &book.Publisher,
+		"publishedDate":   &book.PublishedDate,
+		"publicationYear": &book.PublicationYear,
+		"pageCount":       &book.PageCount,
+		"language":        &book.Language,
+		"coverUrl":        &book.CoverURL,
+	}
+}
+
+// provider values in the form books store them, dates and languages that do not validate are dropped
+func metadataValues(found metadata.Metadata) map[string]interface{} {
+	details := model.Book{PublishedDate: found.PublishedDate, Language: found.Language}
+	cleanBookDetails(&details)
+
+	return map[string]interface{}{
+		"title":           found.Title,
+		"subtitle":        found.Subtitle,
+		"description":     found.Description,
+		"publisher":       found.Publisher,
+		"publishedDate":   details.PublishedDate,
+		"publicationYear": details.PublicationYear,
+		"pageCount":       found.PageCount,
+		"language":        details.Language,
+		"coverUrl":        found.CoverURL,
+	}
+}
+
+// fill the book from provider metadata: empty fields and fields a provider filled
+// before are written, anything a user entered is left alone; returns the changed fields
+func applyMetadata(book *model.Book, found metadata.Metadata) []string {
+	enriched := map[string]bool{}
+	for _, field := range book.EnrichedFields {
+		enriched[field] = true
+	}
+
+	values := metadataValues(found)
+	var changed []string
+	for field, pointer := range enrichableFields(book) {
+		switch current := pointer.(type) {
+		case *string:
+			value := values[field].(string)
+			if value == "" || (*current != "" && !enriched[field]) {
+				continue
+			}
+			if *current != value {
+				*current = value
+				changed = append(changed, field)
+			}
+		case *int:
+			value := values[field].(int)
+			if value == 0 || (*current != 0 && !enriched[field]) {
+				continue
+			}
+			if *current != value {
+				*current = value
+				changed = append(changed, field)
+			}
+		}
+		enriched[field] = true
+	}
+
+	book.EnrichedFields = book.EnrichedFields[:0]
+	for field := range enrichableFields(book) {
+		if enriched[field] {
+			book.EnrichedFields = append(book.EnrichedFields, field)
+		}
+	}
+	sort.Strings(book.EnrichedFields)
+	return changed
+}
+
+// enriched fields a user update gives a different value, they count as user-edited from then on
+func userEditedFields(before bson.M, book model.Book) []string {
+	var edited []string
+	for field, pointer := range enrichableFields(&book) {
+		var value, stored string
+		switch current := pointer.(type) {
+		case *string:
+			value = *current
+		case *int:
+			value = fmt.Sprint(*current)
+		}
+		if before[field] != nil {
+			stored = fmt.Sprint(before[field])
+		} else if _, ok := pointer.(*int); ok {
+			stored = "0"
+		}
+		if value != stored {
+			edited = append(edited, field)
+		}
+	}
+	return edited
+}
+
+// drop the fields a user just changed from the book's enriched fields
+func markUserEdited(update bson.M, before bson.M, book model.Book) bson.M {
+	if edited := userEditedFields(before, book); len(edited) > 0 {
+		update["$pull"] = bson.M{"enrichedFields": bson.M{"$in": edited}}
+	}
+	return update
+}
+
+// fill a new book from its ISBN, lookup failures only mean the book is saved as entered
+func enrichNewBook(ctx context.Context, book *model.Book) {
+	book.EnrichedFields = nil
+	if metadataProvider == nil || book.ISBN13 == "" {
+		return
+	}
+
+	found, err := metadataProvider.LookupISBN(ctx, book.ISBN13)
+	if err != nil {
+		if err != metadata.ErrNotFound {
+			log.Println("Error looking up ISBN "+book.ISBN13+": ", err)
+		}
+		return
+	}
+	applyMetadata(book, found)
+}
+
+// re-run enrichment on a stored book
+func enrichBook(ctx context.Context, bookID string, req requestInfo) (model.Book, []string, error) {
+	var book model.Book
+
+	id, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return book, nil, errBookNotFound
+	}
+	err = bookCollection.FindOne(ctx, scoped(req.LibraryID, bson.M{"_id": id})).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		return book, nil, errBookNotFound
+	} else if err != nil {
+		return book, nil, err
+	}
+	if book.ISBN13 == "" {
+		return book, nil, errNoISBN
+	}
+	if metadataProvider == nil {
+		return book, nil, errNoProvider
+	}
+
+	found, err := metadataProvider.LookupISBN(ctx, book.ISBN13)
+	if err != nil {
+		return book, nil, err
+	}
+
+	changed := applyMetadata(&book, found)
+	set := bson.M{"enrichedFields": book.EnrichedFields}
+	fields := enrichableFields(&book)
+	for _, field := range changed {
+		switch value := fields[field].(type) {
+		case *string:
+			set[field] = *value
+		case *int:
+			set[field] = *value
+		}
+	}
+
+	revision := captureRevision(bookRevisionTarget(), id, req)
+	before := snapshot(bookCollection, id)
+	if _, err := bookCollection.UpdateOne(ctx, scoped(req.LibraryID, bson.M{"_id": id}), bson.M{"$set": stampUpdated(set, req.Actor)}); err != nil {
+		return book, nil, err
+	}
+	if err := storeRevision(ctx, revision); err != nil {
+		return book, nil, err
+	}
+	recordAudit(req, auditEntityBook, auditActionUpdate, id, before, snapshot(bookCollection, id))
+
+	return book, changed, nil
+}
+
+func EnrichBook(c *gin.Context) {
+	book, changed, err := enrichBook(c.Request.Context(), c.Param("bookId"), requestFrom(c))
+	if err == errBookNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	} else if err == errNoISBN {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Book has no ISBN to look up"})
+		return
+	} else if err == errNoProvider {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No metadata provider configured"})
+		return
+	} else if err == metadata.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No metadata found for this ISBN"})
+		return
+	} else if errors.Is(err, metadata.ErrRateLimited) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Metadata provider is busy, try again later"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Error looking up metadata"})
+		return
+	}
+
+	if changed == nil {
+		changed = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"book": book, "changed": changed})
+}
+
+// GET /book/metadata/:isbn previews what enrichment would fill in
+func GetISBNMetadata(c *gin.Context) {
+	_, isbn13, err := isbn.Parse(c.Param("isbn"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ISBN"})
+		return
+	}
+	if metadataProvider == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No metadata provider configured"})
+		return
+	}
+
+	found, err := metadataProvider.LookupISBN(c.Request.Context(), isbn13)
+	if err == metadata.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No metadata found for this ISBN"})
+		return
+	} else if errors.Is(err, metadata.ErrRateLimited) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Metadata provider is busy, try again later"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Error looking up metadata"})
+		return
+	}
+
+	c.JSON(http.StatusOK, found)
+}
@@ -0,0 +1,560 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var genreCollection *mongo.Collection
+
+var errGenreNotFound = errors.New("genre not found")
+var errGenreParentNotFound = errors.New("parent genre does not exist")
+var errGenreNameTaken = errors.New("another genre of this library has the same name or alias")
+var errGenreCycle = errors.New("a genre cannot be moved under itself or one of its subgenres")
+var errGenreHasChildren = errors.New("genre has subgenres")
+
+// everything but letters and digits, dropped when comparing genre names
+var genreKeyStrip = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		genreCollection = db.Collection("genres")
+
+		// A name or alias identifies one genre of a library, so free text maps onto at most one
+		ensureIndexes(genreCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "libraryId", Value: 1}, {Key: "keys", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "ancestors", Value: 1}},
+		}, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "parentId", Value: 1}},
+		})
+
+		fmt.Println("Genre collection istance is ready")
+		return nil
+	})
+}
+
+// "Sci-Fi", "sci fi" and "SciFi" -> "scifi"
+func genreKey(value string) string {
+	return genreKeyStrip.ReplaceAllString(strings.ToLower(value), "")
+}
+
+func validGenre(genre *model.Genre) error {
+	genre.Name = strings.TrimSpace(genre.Name)
+	if genreKey(genre.Name) == "" {
+		return errors.New("name must contain letters or digits")
+	}
+
+	genre.Keys = []string{genreKey(genre.Name)}
+	var aliases []string
+	for _, alias := range normalizeAliases(genre.Name, genre.Aliases) {
+		key := genreKey(alias)
+		if key == "" || containsString(genre.Keys, key) {
+			continue
+		}
+		genre.Keys = append(genre.Keys, key)
+		aliases = append(aliases, alias)
+	}
+	genre.Aliases = aliases
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// errGenreNameTaken when another genre of the library has one of the names or aliases
+func checkGenreKeysAvailable(libraryID primitive.ObjectID, keys []string, genreID primitive.ObjectID) error {
+	filter := scoped(libraryID, bson.M{"keys": bson.M{"$in": keys}, "_id": bson.M{"$ne": genreID}})
+	err := genreCollection.FindOne(context.Background(), filter).Err()
+	if err == mongo.ErrNoDocuments {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return errGenreNameTaken
+}
+
+func findGenre(libraryID primitive.ObjectID, id primitive.ObjectID) (model.Genre, error) {
+	var genre model.Genre
+	err := genreCollection.FindOne(context.Background(), scoped(libraryID, bson.M{"_id": id})).Decode(&genre)
+	if err == mongo.ErrNoDocuments {
+		return genre, errGenreNotFound
+	}
+	return genre, err
+}
+
+// ancestors of a genre placed under parentID, nil for a top-level genre
+func genreAncestors(libraryID primitive.ObjectID, parentID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if parentID.IsZero() {
+		return nil, nil
+	}
+	parent, err := findGenre(libraryID, parentID)
+	if err == errGenreNotFound {
+		return nil, errGenreParentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return append(append([]primitive.ObjectID{}, parent.Ancestors...), parent.ID), nil
+}
+
+func insertGenre(genre *model.Genre, req requestInfo) error {
+	if err := checkQuota(req.LibraryID, auditEntityGenre, 1); err != nil {
+		return err
+	}
+	if err := checkGenreKeysAvailable(req.LibraryID, genre.Keys, primitive.NilObjectID); err != nil {
+		return err
+	}
+	ancestors, err := genreAncestors(req.LibraryID, genre.ParentID)
+	if err != nil {
+		return err
+	}
+
+	genre.Ancestors = ancestors
+	genre.LibraryID = req.LibraryID
+	stampCreated(&genre.Timestamps, req.Actor)
+
+	inserted, err := genreCollection.InsertOne(context.Background(), genre)
+	if mongo.IsDuplicateKeyError(err) {
+		return errGenreNameTaken
+	} else if err != nil {
+		return err
+	}
+
+	genre.ID = inserted.InsertedID.(primitive.ObjectID)
+	recordAudit(req, auditEntityGenre, auditActionCreate, genre.ID, nil, snapshot(genreCollection, genre.ID))
+	return nil
+}
+
+// rename a genre or move it with its subgenres under another parent
+func updateGenre(id primitive.ObjectID, genre model.Genre, req requestInfo) error {
+	current, err := findGenre(req.LibraryID, id)
+	if err != nil {
+		return err
+	}
+	if err := checkGenreKeysAvailable(req.LibraryID, genre.Keys, id); err != nil {
+		return err
+	}
+	ancestors, err := genreAncestors(req.LibraryID, genre.ParentID)
+	if err != nil {
+		return err
+	}
+	if genre.ParentID == id || containsID(ancestors, id) {
+		return errGenreCycle
+	}
+
+	set := bson.M{"name": genre.Name, "aliases": genre.Aliases, "keys": genre.Keys, "ancestors": ancestors}
+	update := bson.M{"$set": stampUpdated(set, req.Actor)}
+	if genre.ParentID.IsZero() {
+		update["$unset"] = bson.M{"parentId": ""}
+	} else {
+		set["parentId"] = genre.ParentID
+	}
+
+	before := snapshot(genreCollection, id)
+	_, err = genreCollection.UpdateOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}), update)
+	if mongo.IsDuplicateKeyError(err) {
+		return errGenreNameTaken
+	} else if err != nil {
+		return err
+	}
+	recordAudit(req, auditEntityGenre, auditActionUpdate, id, before, snapshot(genreCollection, id))
+
+	if current.ParentID == genre.ParentID {
+		return nil
+	}
+
+	// Subgenres keep their place below the genre, only the path above it changes
+	var descendants []model.Genre
+	cursor, err := genreCollection.Find(context.Background(), scoped(req.LibraryID, bson.M{"ancestors": id}))
+	if err != nil {
+		return err
+	}
+	if err := cursor.All(context.Background(), &descendants); err != nil {
+		return err
+	}
+	for _, descendant := range descendants {
+		below := descendant.Ancestors
+		for i, ancestor := range descendant.Ancestors {
+			if ancestor == id {
+				below = descendant.Ancestors[i+1:]
+				break
+			}
+		}
+		moved := append(append(append([]primitive.ObjectID{}, ancestors...), id), below...)
+
+		before := snapshot(genreCollection, descendant.ID)
+		update := bson.M{"$set": stampUpdated(bson.M{"ancestors": moved}, req.Actor)}
+		if _, err := genreCollection.UpdateOne(context.Background(), bson.M{"_id": descendant.ID}, update); err != nil {
+			return err
+		}
+		recordAudit(req, auditEntityGenre, auditActionUpdate, descendant.ID, before, snapshot(genreCollection, descendant.ID))
+	}
+	return nil
+}
+
+// delete a genre without subgenres, its books lose the genre
+func deleteGenre(id primitive.ObjectID, req requestInfo) error {
+	children, err := genreCollection.CountDocuments(context.Background(), scoped(req.LibraryID, bson.M{"parentId": id}))
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return errGenreHasChildren
+	}
+
+	before := snapshot(genreCollection, id)
+	result, err := genreCollection.DeleteOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errGenreNotFound
+	}
+	recordAudit(req, auditEntityGenre, auditActionDelete, id, before, nil)
+
+	bookFilter := scoped(req.LibraryID, bson.M{"genres": id})
+	booksBefore := snapshots(bookCollection, bookFilter)
+	update := bson.M{"$pull": bson.M{"genres": id}, "$set": stampUpdated(bson.M{}, req.Actor)}
+	if _, err := bookCollection.UpdateMany(context.Background(), bookFilter, update); err != nil {
+		return err
+	}
+	for _, book := range booksBefore {
+		bookID := book["_id"].(primitive.ObjectID)
+		recordAudit(req, auditEntityBook, auditActionUpdate, bookID, book, snapshot(bookCollection, bookID))
+	}
+	return nil
+}
+
+func getAllGenres(libraryID primitive.ObjectID, match bson.M, sort bson.D) ([]model.Genre, error) {
+	genres := []model.Genre{}
+
+	opts := options.Find()
+	if len(sort) > 0 {
+		opts.SetSort(sort)
+	} else {
+		opts.SetSort(bson.D{{Key: "name", Value: 1}})
+	}
+
+	cursor, err := genreCollection.Find(context.Background(), scoped(libraryID, match), opts)
+	if err != nil {
+		return genres, err
+	}
+	err = cursor.All(context.Background(), &genres)
+	return genres, err
+}
+
+// the genres of a library as a tree, siblings ordered by name
+func getGenreTree(libraryID primitive.ObjectID) ([]model.GenreNode, error) {
+	genres, err := getAllGenres(libraryID, bson.M{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[primitive.ObjectID][]model.Genre{}
+	for _, genre := range genres {
+		children[genre.ParentID] = append(children[genre.ParentID], genre)
+	}
+
+	var build func(parentID primitive.ObjectID) []model.GenreNode
+	build = func(parentID primitive.ObjectID) []model.GenreNode {
+		nodes := []model.GenreNode{}
+		for _, genre := range children[parentID] {
+			nodes = append(nodes, model.GenreNode{Genre: genre, Children: build(genre.ID)})
+		}
+		return nodes
+	}
+	return build(primitive.NilObjectID), nil
+}
+
+// the given genres and all their subgenres
+func genreDescendants(libraryID primitive.ObjectID, ids []primitive.ObjectID) ([]interface{}, error) {
+	filter := scoped(libraryID, bson.M{"$or": []bson.M{
+		{"_id": bson.M{"$in": ids}},
+		{"ancestors": bson.M{"$in": ids}},
+	}})
+	return genreCollection.Distinct(context.Background(), "_id", filter)
+}
+
+// genres referred to by ID, name or alias; references that match no genre are returned as unresolved
+func lookupGenres(libraryID primitive.ObjectID, refs []string) ([]primitive.ObjectID, []string, error) {
+	if len(refs) == 0 {
+		return nil, nil, nil
+	}
+
+	var conditions []bson.M
+	for _, ref := range refs {
+		if id, err := primitive.ObjectIDFromHex(ref); err == nil {
+			conditions = append(conditions, bson.M{"_id": id})
+		} else {
+			conditions = append(conditions, bson.M{"keys": genreKey(ref)})
+		}
+	}
+
+	var genres []model.Genre
+	cursor, err := genreCollection.Find(context.Background(), scoped(libraryID, bson.M{"$or": conditions}))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cursor.All(context.Background(), &genres); err != nil {
+		return nil, nil, err
+	}
+
+	var ids []primitive.ObjectID
+	var unresolved []string
+	for _, ref := range refs {
+		found := false
+		for _, genre := range genres {
+			if genre.ID.Hex() == ref || containsString(genre.Keys, genreKey(ref)) {
+				found = true
+				if !containsID(ids, genre.ID) {
+					ids = append(ids, genre.ID)
+				}
+				break
+			}
+		}
+		if !found {
+			unresolved = append(unresolved, ref)
+		}
+	}
+	return ids, unresolved, nil
+}
+
+// free-text genres separated by commas, semicolons or pipes
+func splitGenreText(text string) []string {
+	var values []string
+	for _, value := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// map the free-text genre of a book onto the taxonomy: matched values are added to
+// its genres, anything unmatched stays in genre for the migration report
+func resolveBookGenre(libraryID primitive.ObjectID, book *model.Book) error {
+	var genres []primitive.ObjectID
+	for _, id := range book.Genres {
+		if !containsID(genres, id) {
+			genres = append(genres, id)
+		}
+	}
+	book.Genres = genres
+
+	if strings.TrimSpace(book.Genre) == "" {
+		book.Genre = ""
+		return nil
+	}
+	ids, unresolved, err := lookupGenres(libraryID, splitGenreText(book.Genre))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !containsID(book.Genres, id) {
+			book.Genres = append(book.Genres, id)
+		}
+	}
+	book.Genre = strings.Join(unresolved, "; ")
+	return nil
+}
+
+func genresExist(genreIDs []primitive.ObjectID, libraryID primitive.ObjectID) (bool, error) {
+	if len(genreIDs) == 0 {
+		return true, nil
+	}
+	count, err := genreCollection.CountDocuments(context.Background(), scoped(libraryID, bson.M{"_id": bson.M{"$in": genreIDs}}))
+	if err != nil {
+		return false, err
+	}
+	return count == int64(len(genreIDs)), nil
+}
+
+// resolve the free-text genre of a book and check its genres, errGenreNotFound when
+// one of them is not a genre of the library
+func checkBookGenres(libraryID primitive.ObjectID, book *model.Book) error {
+	if err := resolveBookGenre(libraryID, book); err != nil {
+		return err
+	}
+	exist, err := genresExist(book.Genres, libraryID)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return errGenreNotFound
+	}
+	return nil
+}
+
+// lookup replacing the genre IDs of each book with {_id, name}
+func genreStages(libraryID primitive.ObjectID) []bson.M {
+	return []bson.M{
+		scopedLookup("genres", "genres", "_id", "genreInfo", libraryID),
+		{"$addFields": bson.M{"genres": bson.M{"$map": bson.M{
+			"input": "$genreInfo",
+			"as":    "genre",
+			"in":    bson.M{"_id": "$$genre._id", "name": "$$genre.name"},
+		}}}},
+	}
+}
+
+func genreIdParam(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("genreId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid genre ID"})
+		return id, false
+	}
+	return id, true
+}
+
+// answer a genre write error, false when there was none
+func genreWriteError(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return false
+	case errGenreNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Genre not found"})
+	case errGenreNameTaken, errGenreHasChildren:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errGenreParentNotFound, errGenreCycle:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errQuotaExceeded:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Genre quota exceeded for this library"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing genre"})
+	}
+	return true
+}
+
+func CreateGenre(c *gin.Context) {
+	var genre model.Genre
+	if err := c.ShouldBindJSON(&genre); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validGenre(&genre); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if genreWriteError(c, insertGenre(&genre, requestFrom(c))) {
+		return
+	}
+	c.JSON(http.StatusCreated, genre)
+}
+
+func GetAllGenres(c *gin.Context) {
+	match, sort, err := timestampListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if name := strings.TrimSpace(c.Query("name")); name != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(name), Options: "i"}
+		match["$or"] = []bson.M{{"name": pattern}, {"aliases": pattern}}
+	}
+	if serveCached(c) {
+		return
+	}
+
+	libraryID := requestFrom(c).LibraryID
+	genres, err := getAllGenres(libraryID, match, sort)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading genres"})
+		return
+	}
+	respondCached(c, genres, listTag(libraryID, auditEntityGenre))
+}
+
+func GetGenreTree(c *gin.Context) {
+	if serveCached(c) {
+		return
+	}
+
+	libraryID := requestFrom(c).LibraryID
+	tree, err := getGenreTree(libraryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading genres"})
+		return
+	}
+	respondCached(c, tree, listTag(libraryID, auditEntityGenre))
+}
+
+func GetGenre(c *gin.Context) {
+	id, ok := genreIdParam(c)
+	if !ok {
+		return
+	}
+	if serveCached(c) {
+		return
+	}
+
+	genre, err := findGenre(requestFrom(c).LibraryID, id)
+	if err == errGenreNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Genre not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading genre"})
+		return
+	}
+	respondCached(c, genre, entityTag(auditEntityGenre, id))
+}
+
+func UpdateGenre(c *gin.Context) {
+	id, ok := genreIdParam(c)
+	if !ok {
+		return
+	}
+	var genre model.Genre
+	if err := c.ShouldBindJSON(&genre); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validGenre(&genre); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if genreWriteError(c, updateGenre(id, genre, requestFrom(c))) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Updated"})
+}
+
+func DeleteGenre(c *gin.Context) {
+	id, ok := genreIdParam(c)
+	if !ok {
+		return
+	}
+
+	if genreWriteError(c, deleteGenre(id, requestFrom(c))) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Deleted"})
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"example/books-api/model"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// map the free-text genre of every book onto the taxonomy. Values matching a genre name
+// or alias are linked and removed from the text, values matching nothing stay there and
+// are listed in the report, most used first, so genres or aliases can be added for them
+// and the migration run again
+func migrateGenres(req requestInfo, dryRun bool) (model.GenreMigrationReport, error) {
+	report := model.GenreMigrationReport{DryRun: dryRun, Unmapped: []model.UnmappedGenre{}}
+
+	var books []model.Book
+	filter := scoped(req.LibraryID, bson.M{"genre": bson.M{"$nin": []interface{}{"", nil}}})
+	cursor, err := bookCollection.Find(context.Background(), filter)
+	if err != nil {
+		return report, err
+	}
+	if err := cursor.All(context.Background(), &books); err != nil {
+		return report, err
+	}
+
+	unmapped := map[string]*model.UnmappedGenre{}
+	for _, book := range books {
+		report.Books++
+		resolved := book
+		if err := resolveBookGenre(req.LibraryID, &resolved); err != nil {
+			return report, err
+		}
+
+		remaining := splitGenreText(resolved.Genre)
+		for _, value := range remaining {
+			key := genreKey(value)
+			if unmapped[key] == nil {
+				unmapped[key] = &model.UnmappedGenre{Value: value}
+			}
+			unmapped[key].Books++
+		}
+		if len(remaining) == len(splitGenreText(book.Genre)) {
+			continue
+		}
+		if resolved.Genre == "" {
+			report.Mapped++
+		} else {
+			report.Partial++
+		}
+		if dryRun {
+			continue
+		}
+
+		revision := captureRevision(bookRevisionTarget(), book.ID, req)
+		before := snapshot(bookCollection, book.ID)
+		update := bson.M{"$set": stampUpdated(bson.M{"genres": resolved.Genres}, req.Actor)}
+		if resolved.Genre == "" {
+			update["$unset"] = bson.M{"genre": ""}
+		} else {
+			update["$set"].(bson.M)["genre"] = resolved.Genre
+		}
+		if _, err := bookCollection.UpdateOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": book.ID}), update); err != nil {
+			return report, err
+		}
+		if err := storeRevision(context.Background(), revision); err != nil {
+			return report, err
+		}
+		recordAudit(req, auditEntityBook, auditActionUpdate, book.ID, before, snapshot(bookCollection, book.ID))
+	}
+
+	for _, value := range unmapped {
+		report.Unmapped = append(report.Unmapped, *value)
+	}
+	sort.Slice(report.Unmapped, func(i, j int) bool {
+		a, b := report.Unmapped[i], report.Unmapped[j]
+		if a.Books != b.Books {
+			return a.Books > b.Books
+		}
+		return strings.ToLower(a.Value) < strings.ToLower(b.Value)
+	})
+	return report, nil
+}
+
+// POST /genre/migrate?dryRun=true reports what the migration would map without writing anything
+func MigrateGenres(c *gin.Context) {
+	report, err := migrateGenres(requestFrom(c), c.Query("dryRun") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error migrating genres"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
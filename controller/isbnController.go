@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/isbn"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errISBNTaken = errors.New("a book with this ISBN already exists")
+
+// validate the ISBNs of a book, normalize them and fill in the missing form
+func normalizeBookISBN(book *model.Book) error {
+	var isbn10, isbn13 string
+	for _, value := range []string{book.ISBN13, book.ISBN10} {
+		if value == "" {
+			continue
+		}
+		ten, thirteen, err := isbn.Parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid ISBN %q", value)
+		}
+		if isbn13 != "" && thirteen != isbn13 {
+			return errors.New("isbn10 and isbn13 identify different books")
+		}
+		isbn10, isbn13 = ten, thirteen
+	}
+
+	book.ISBN10, book.ISBN13 = isbn10, isbn13
+	return nil
+}
+
+// the book of the library with this ISBN-13, nil if there is none
+func findBookByISBN(libraryID primitive.ObjectID, isbn13 string) (*model.Book, error) {
+	var book model.Book
+	err := bookCollection.FindOne(context.Background(), scoped(libraryID, bson.M{"isbn13": isbn13})).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// errISBNTaken when another book of the library already has one of the book's ISBNs
+func checkISBNAvailable(libraryID primitive.ObjectID, book model.Book, bookID primitive.ObjectID) error {
+	var taken []bson.M
+	if book.ISBN13 != "" {
+		taken = append(taken, bson.M{"isbn13": book.ISBN13})
+	}
+	if book.ISBN10 != "" {
+		taken = append(taken, bson.M{"isbn10": book.ISBN10})
+	}
+	if len(taken) == 0 {
+		return nil
+	}
+
+	filter := scoped(libraryID, bson.M{"$or": taken, "_id": bson.M{"$ne": bookID}})
+	count, err := bookCollection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errISBNTaken
+	}
+	return nil
+}
+
+func GetBookByISBN(c *gin.Context) {
+	_, isbn13, err := isbn.Parse(c.Param("isbn"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ISBN"})
+		return
+	}
+	if serveCached(c) {
+		return
+	}
+
+	libraryID := requestFrom(c).LibraryID
+	book, err := findBookByISBN(libraryID, isbn13)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error looking up ISBN"})
+		return
+	}
+	if book == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	bookWithAuthor, err := getBookWithAuthor(book.ID.Hex(), libraryID, requestFrom(c).Actor)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	// Every book change drops the list tag, which covers the ISBN moving to another book
+	tags := []string{entityTag(auditEntityBook, book.ID), listTag(libraryID, auditEntityBook)}
+	for _, contributor := range bookWithAuthor.Contributors {
+		tags = append(tags, entityTag(auditEntityAuthor, contributor.ID))
+	}
+	respondCached(c, bookWithAuthor, tags...)
+}
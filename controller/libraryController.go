@@ -0,0 +1,447 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var libraryCollection *mongo.Collection
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+var errLibraryNotFound = errors.New("library not found")
+var errLibraryNotEmpty = errors.New("library is not empty")
+var errQuotaExceeded = errors.New("library quota exceeded")
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		libraryCollection = db.Collection("libraries")
+
+		ensureIndexes(libraryCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+
+		fmt.Println("Library collection istance is ready")
+		return nil
+	})
+}
+
+// restrict a filter to one library
+func scoped(libraryID primitive.ObjectID, filter bson.M) bson.M {
+	filter["libraryId"] = libraryID
+	return filter
+}
+
+// $lookup that only joins documents from the same library, localField may be a value or an array
+func scopedLookup(from string, localField string, foreignField string, as string, libraryID primitive.ObjectID) bson.M {
+	return bson.M{"$lookup": bson.M{
+		"from": from,
+		"let":  bson.M{"local": "$" + localField},
+		"pipeline": []bson.M{
+			{"$match": bson.M{"$expr": bson.M{"$and": []bson.M{
+				{"$eq": []interface{}{"$libraryId", libraryID}},
+				{"$in": []interface{}{"$" + foreignField, bson.M{"$cond": []interface{}{
+					bson.M{"$isArray": "$$local"}, "$$local", []interface{}{"$$local"},
+				}}}},
+			}}}},
+		},
+		"as": as,
+	}}
+}
+
+// ResolveLibrary is used by the tenant middleware, ref is a library ID or slug
+func ResolveLibrary(ref string) (string, bool) {
+	filter := bson.M{"slug": ref}
+	if id, err := primitive.ObjectIDFromHex(ref); err == nil {
+		filter = bson.M{"_id": id}
+	}
+
+	var library model.Library
+	if err := libraryCollection.FindOne(context.Background(), filter).Decode(&library); err != nil {
+		return "", false
+	}
+	return library.ID.Hex(), true
+}
+
+func getLibrary(libraryID primitive.ObjectID) (model.Library, error) {
+	var library model.Library
+	err := libraryCollection.FindOne(context.Background(), bson.M{"_id": libraryID}).Decode(&library)
+	if err == mongo.ErrNoDocuments {
+		return library, errLibraryNotFound
+	}
+	return library, err
+}
+
+func getLibraryUsage(libraryID primitive.ObjectID) (model.LibraryUsage, error) {
+	var usage model.LibraryUsage
+	var err error
+
+	filter := bson.M{"libraryId": libraryID}
+	if usage.Authors, err = collection.CountDocuments(context.Background(), filter); err != nil {
+		return usage, err
+	}
+	if usage.Books, err = bookCollection.CountDocuments(context.Background(), filter); err != nil {
+		return usage, err
+	}
+	if usage.Links, err = bookAuthor.CountDocuments(context.Background(), filter); err != nil {
+		return usage, err
+	}
+	if usage.Series, err = seriesCollection.CountDocuments(context.Background(), filter); err != nil {
+		return usage, err
+	}
+	if usage.Genres, err = genreCollection.CountDocuments(context.Background(), filter); err != nil {
+		return usage, err
+	}
+	if usage.Publishers, err = publisherCollection.CountDocuments(context.Background(), filter); err != nil {
+		return usage, err
+	}
+	usage.Works, err = workCollection.CountDocuments(context.Background(), filter)
+	return usage, err
+}
+
+// the quota limiting an entity and the collection counted against it
+func quotaFor(quota model.LibraryQuota, entity string) (int64, *mongo.Collection) {
+	switch entity {
+	case auditEntityAuthor:
+		return quota.MaxAuthors, collection
+	case auditEntityBook:
+		return quota.MaxBooks, bookCollection
+	case auditEntitySeries:
+		return quota.MaxSeries, seriesCollection
+	case auditEntityGenre:
+		return quota.MaxGenres, genreCollection
+	case auditEntityPublisher:
+		return quota.MaxPublishers, publisherCollection
+	case auditEntityWork:
+		return quota.MaxWorks, workCollection
+	}
+	return 0, nil
+}
+
+// check that adding more documents of a kind stays within the library's quota
+func checkQuota(libraryID primitive.ObjectID, entity string, adding int64) error {
+	library, err := getLibrary(libraryID)
+	if err != nil {
+		return err
+	}
+
+	limit, col := quotaFor(library.Quota, entity)
+	if limit == 0 {
+		return nil
+	}
+
+	count, err := col.CountDocuments(context.Background(), bson.M{"libraryId": libraryID})
+	if err != nil {
+		return err
+	}
+	if count+adding > limit {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+func insertLibrary(library *model.Library, req requestInfo) error {
+	stampCreated(&library.Timestamps, req.Actor)
+
+	inserted, err := libraryCollection.InsertOne(context.Background(), library)
+	if err != nil {
+		return err
+	}
+
+	library.ID = inserted.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func getAllLibraries() ([]model.Library, error) {
+	libraries := []model.Library{}
+
+	cursor, err := libraryCollection.Find(context.Background(), bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return libraries, err
+	}
+
+	err = cursor.All(context.Background(), &libraries)
+	return libraries, err
+}
+
+func updateLibrary(libraryID primitive.ObjectID, library model.Library, req requestInfo) error {
+	update := bson.M{"$set": stampUpdated(bson.M{"name": library.Name, "slug": library.Slug, "quota": library.Quota}, req.Actor)}
+
+	result, err := libraryCollection.UpdateOne(context.Background(), bson.M{"_id": libraryID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errLibraryNotFound
+	}
+	return nil
+}
+
+// only empty libraries can be deleted, the catalog has to be removed first
+func deleteLibrary(libraryID primitive.ObjectID) error {
+	usage, err := getLibraryUsage(libraryID)
+	if err != nil {
+		return err
+	}
+	if usage.Authors+usage.Books+usage.Links+usage.Series+usage.Genres+usage.Publishers+usage.Works > 0 {
+		return errLibraryNotEmpty
+	}
+
+	result, err := libraryCollection.DeleteOne(context.Background(), bson.M{"_id": libraryID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errLibraryNotFound
+	}
+	return nil
+}
+
+// move documents created before libraries existed into a library
+func adoptUnscoped(libraryID primitive.ObjectID, req requestInfo) (model.LibraryUsage, error) {
+	var adopted model.LibraryUsage
+
+	filter := bson.M{"libraryId": bson.M{"$exists": false}}
+	update := bson.M{"$set": stampUpdated(bson.M{"libraryId": libraryID}, req.Actor)}
+
+	// Revisions and reading states are not counted in the usage but belong to the catalog all the same
+	var revisions, states int64
+	adopt := []struct {
+		col   *mongo.Collection
+		count *int64
+	}{
+		{collection, &adopted.Authors},
+		{bookCollection, &adopted.Books},
+		{bookAuthor, &adopted.Links},
+		{seriesCollection, &adopted.Series},
+		{genreCollection, &adopted.Genres},
+		{publisherCollection, &adopted.Publishers},
+		{workCollection, &adopted.Works},
+		{revisionCollection, &revisions},
+		{readingStateCollection, &states},
+	}
+	for _, a := range adopt {
+		result, err := a.col.UpdateMany(context.Background(), filter, update)
+		if err != nil {
+			return adopted, err
+		}
+		*a.count = result.ModifiedCount
+	}
+
+	tags := []string{}
+	for _, entity := range []string{auditEntityAuthor, auditEntityBook, auditEntitySeries, auditEntityGenre, auditEntityPublisher, auditEntityWork} {
+		tags = append(tags, listTag(libraryID, entity))
+	}
+	responseCache.InvalidateTags(tags...)
+	return adopted, nil
+}
+
+func assignUserLibrary(userID primitive.ObjectID, libraryID primitive.ObjectID, req requestInfo) error {
+	set := bson.M{"$set": stampUpdated(bson.M{"libraryId": libraryID}, req.Actor)}
+	if libraryID.IsZero() {
+		set = bson.M{"$unset": bson.M{"libraryId": ""}, "$set": stampUpdated(bson.M{}, req.Actor)}
+	}
+
+	result, err := userCollection.UpdateOne(context.Background(), bson.M{"_id": userID}, set)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func validLibrary(library model.Library) error {
+	if library.Name == "" {
+		return errors.New("name is required")
+	}
+	if !slugPattern.MatchString(library.Slug) {
+		return errors.New("slug must be lowercase letters, digits and dashes")
+	}
+	quota := library.Quota
+	if quota.MaxAuthors < 0 || quota.MaxBooks < 0 || quota.MaxSeries < 0 || quota.MaxGenres < 0 || quota.MaxPublishers < 0 || quota.MaxWorks < 0 {
+		return errors.New("quotas must not be negative")
+	}
+	return nil
+}
+
+func libraryIdParam(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("libraryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return id, false
+	}
+	return id, true
+}
+
+func CreateLibrary(c *gin.Context) {
+	var library model.Library
+	if err := c.ShouldBindJSON(&library); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validLibrary(library); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := insertLibrary(&library, requestFrom(c))
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Slug already taken"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating library"})
+		return
+	}
+	c.JSON(http.StatusCreated, library)
+}
+
+func GetAllLibraries(c *gin.Context) {
+	libraries, err := getAllLibraries()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading libraries"})
+		return
+	}
+	c.JSON(http.StatusOK, libraries)
+}
+
+func GetLibrary(c *gin.Context) {
+	libraryID, ok := libraryIdParam(c)
+	if !ok {
+		return
+	}
+
+	library, err := getLibrary(libraryID)
+	if err == errLibraryNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading library"})
+		return
+	}
+
+	usage, err := getLibraryUsage(libraryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading library usage"})
+		return
+	}
+	c.JSON(http.StatusOK, model.LibraryWithUsage{Library: library, Usage: usage})
+}
+
+func UpdateLibrary(c *gin.Context) {
+	libraryID, ok := libraryIdParam(c)
+	if !ok {
+		return
+	}
+
+	var library model.Library
+	if err := c.ShouldBindJSON(&library); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validLibrary(library); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := updateLibrary(libraryID, library, requestFrom(c))
+	if err == errLibraryNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		return
+	} else if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Slug already taken"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating library"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Updated"})
+}
+
+func DeleteLibrary(c *gin.Context) {
+	libraryID, ok := libraryIdParam(c)
+	if !ok {
+		return
+	}
+
+	err := deleteLibrary(libraryID)
+	if err == errLibraryNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		return
+	} else if err == errLibraryNotEmpty {
+		c.JSON(http.StatusConflict, gin.H{"error": "Library still has authors or books"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting library"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Deleted"})
+}
+
+func AdoptUnscoped(c *gin.Context) {
+	libraryID, ok := libraryIdParam(c)
+	if !ok {
+		return
+	}
+	if _, err := getLibrary(libraryID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		return
+	}
+
+	adopted, err := adoptUnscoped(libraryID, requestFrom(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adopting documents"})
+		return
+	}
+	c.JSON(http.StatusOK, adopted)
+}
+
+// SetUserLibrary binds a user to a library, an empty libraryId unbinds them
+func SetUserLibrary(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var body model.LibraryAssignment
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var libraryID primitive.ObjectID
+	if body.LibraryID != "" {
+		libraryID, err = primitive.ObjectIDFromHex(body.LibraryID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+			return
+		}
+		if _, err := getLibrary(libraryID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+			return
+		}
+	}
+
+	err = assignUserLibrary(userID, libraryID, requestFrom(c))
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Updated"})
+}
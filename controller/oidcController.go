@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/auth"
+	"example/books-api/model"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const loginStateTTL = 10 * time.Minute
+
+var loginStateCollection *mongo.Collection
+var oidcUserCollection *mongo.Collection
+
+// nil when OIDC_ISSUER is not set, local accounts keep working either way
+var identityProvider auth.IdentityProvider
+
+var errLoginStateNotFound = errors.New("unknown or expired login state")
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		loginStateCollection = db.Collection("loginStates")
+		oidcUserCollection = db.Collection("users")
+
+		ensureIndexes(loginStateCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "state", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		ensureIndexes(oidcUserCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"provider": bson.M{"$exists": true}}),
+		})
+
+		if provider := auth.NewOIDCProviderFromEnv(); provider != nil {
+			identityProvider = provider
+			fmt.Println("OIDC provider configured: ", provider.Name())
+		}
+		return nil
+	})
+}
+
+// store the values the callback needs to finish the login
+func createLoginState() (model.LoginState, error) {
+	loginState := model.LoginState{
+		State:        auth.RandomToken(),
+		CodeVerifier: auth.RandomToken(),
+		Nonce:        auth.RandomToken(),
+		ExpiresAt:    time.Now().UTC().Add(loginStateTTL),
+	}
+
+	_, err := loginStateCollection.InsertOne(context.Background(), loginState)
+	return loginState, err
+}
+
+// a login state can only be used once
+func consumeLoginState(state string) (model.LoginState, error) {
+	var loginState model.LoginState
+
+	filter := bson.M{"state": state, "expiresAt": bson.M{"$gt": time.Now().UTC()}}
+	err := loginStateCollection.FindOneAndDelete(context.Background(), filter).Decode(&loginState)
+	if err == mongo.ErrNoDocuments {
+		return loginState, errLoginStateNotFound
+	}
+
+	return loginState, err
+}
+
+// find or create the local user for an external identity, syncing the role from its groups;
+// a user whose groups no longer map to a role is demoted to viewer
+func provisionUser(identity model.User, groupRole string, req requestInfo) (model.User, error) {
+	var user model.User
+	if groupRole == "" {
+		groupRole = auth.RoleViewer
+	}
+
+	filter := bson.M{"provider": identity.Provider, "subject": identity.Subject}
+	err := oidcUserCollection.FindOne(context.Background(), filter).Decode(&user)
+	if err == nil {
+		if groupRole == user.Role {
+			return user, nil
+		}
+		update := bson.M{"$set": stampUpdated(bson.M{"role": groupRole}, req.Actor)}
+		if _, err := oidcUserCollection.UpdateOne(context.Background(), bson.M{"_id": user.ID}, update); err != nil {
+			return user, err
+		}
+		user.Role = groupRole
+		return user, nil
+	} else if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+
+	user = identity
+	user.Role = groupRole
+	stampCreated(&user.Timestamps, req.Actor)
+
+	inserted, err := oidcUserCollection.InsertOne(context.Background(), user)
+	if mongo.IsDuplicateKeyError(err) {
+		// The username belongs to a local account, keep them apart
+		user.Username = identity.Username + "@" + identity.Subject
+		inserted, err = oidcUserCollection.InsertOne(context.Background(), user)
+	}
+	if err != nil {
+		return user, err
+	}
+
+	user.ID = inserted.InsertedID.(primitive.ObjectID)
+	return user, nil
+}
+
+// OIDCLogin redirects to the identity provider with a fresh state, nonce and PKCE challenge
+func OIDCLogin(c *gin.Context) {
+	if identityProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	loginState, err := createLoginState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error starting login"})
+		return
+	}
+
+	url, err := identityProvider.AuthCodeURL(c.Request.Context(), loginState.State, loginState.Nonce, auth.PKCEChallenge(loginState.CodeVerifier))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// OIDCCallback finishes the login and issues the same token pair as a local login
+func OIDCCallback(c *gin.Context) {
+	if identityProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed: " + providerError})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing authorization code"})
+		return
+	}
+
+	loginState, err := consumeLoginState(c.Query("state"))
+	if err == errLoginStateNotFound {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or expired login state"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error finishing login"})
+		return
+	}
+
+	identity, err := identityProvider.Exchange(c.Request.Context(), code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		log.Println("OIDC exchange failed: ", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	external := model.User{
+		Username: identity.Username,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+	}
+	req := requestFrom(c)
+	req.Actor = identity.Username
+
+	user, err := provisionUser(external, identityProvider.RoleForGroups(identity.Groups), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error provisioning user"})
+		return
+	}
+
+	pair, err := issueTokens(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error issuing tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
@@ -0,0 +1,545 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var publisherCollection *mongo.Collection
+
+var errPublisherNotFound = errors.New("publisher not found")
+var errPublisherParentNotFound = errors.New("parent publisher does not exist")
+var errPublisherHasImprints = errors.New("publisher has imprints")
+var errPublisherSelfMerge = errors.New("a publisher cannot be merged into itself")
+var errPublisherMergeIntoImprint = errors.New("a publisher cannot be merged into one of its imprints")
+
+// imprints hang directly below a publisher, an imprint has no imprints of its own
+var errImprintNesting = errors.New("an imprint cannot have imprints of its own")
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		publisherCollection = db.Collection("publishers")
+
+		ensureIndexes(publisherCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "name", Value: 1}},
+		}, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "aliases", Value: 1}},
+		}, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "parentId", Value: 1}},
+		})
+
+		fmt.Println("Publisher collection istance is ready")
+		return nil
+	})
+}
+
+func validPublisher(publisher *model.Publisher) error {
+	publisher.Name = strings.TrimSpace(publisher.Name)
+	if publisher.Name == "" {
+		return errors.New("name is required")
+	}
+	publisher.Aliases = normalizeAliases(publisher.Name, publisher.Aliases)
+	publisher.Website = strings.TrimSpace(publisher.Website)
+	return nil
+}
+
+func findPublisher(libraryID primitive.ObjectID, id primitive.ObjectID) (model.Publisher, error) {
+	var publisher model.Publisher
+	err := publisherCollection.FindOne(context.Background(), scoped(libraryID, bson.M{"_id": id})).Decode(&publisher)
+	if err == mongo.ErrNoDocuments {
+		return publisher, errPublisherNotFound
+	}
+	return publisher, err
+}
+
+// the publisher and its imprints, whose books count as the publisher's
+func publisherFamily(libraryID primitive.ObjectID, id primitive.ObjectID) ([]interface{}, error) {
+	filter := scoped(libraryID, bson.M{"$or": []bson.M{{"_id": id}, {"parentId": id}}})
+	return publisherCollection.Distinct(context.Background(), "_id", filter)
+}
+
+// check that a publisher can be placed under parentID
+func checkPublisherParent(libraryID primitive.ObjectID, id primitive.ObjectID, parentID primitive.ObjectID) error {
+	if parentID.IsZero() {
+		return nil
+	}
+	if parentID == id {
+		return errImprintNesting
+	}
+	parent, err := findPublisher(libraryID, parentID)
+	if err == errPublisherNotFound {
+		return errPublisherParentNotFound
+	} else if err != nil {
+		return err
+	}
+	if !parent.ParentID.IsZero() {
+		return errImprintNesting
+	}
+	if id.IsZero() {
+		return nil
+	}
+	imprints, err := publisherCollection.CountDocuments(context.Background(), scoped(libraryID, bson.M{"parentId": id}))
+	if err != nil {
+		return err
+	}
+	if imprints > 0 {
+		return errImprintNesting
+	}
+	return nil
+}
+
+// link a book to its publisher: a given publisherId must exist and sets the publisher
+// name, otherwise a publisher name matching a publisher's name or alias links that one
+func checkBookPublisher(libraryID primitive.ObjectID, book *model.Book) error {
+	if book.PublisherID.IsZero() {
+		return resolveBookPublisher(libraryID, book)
+	}
+	publisher, err := findPublisher(libraryID, book.PublisherID)
+	if err != nil {
+		return err
+	}
+	book.Publisher = publisher.Name
+	return nil
+}
+
+// link a book with only a publisher name to the publisher of that name, if there is exactly one
+func resolveBookPublisher(libraryID primitive.ObjectID, book *model.Book) error {
+	if !book.PublisherID.IsZero() || book.Publisher == "" {
+		return nil
+	}
+	name := exactNameFilter(book.Publisher)
+	cursor, err := publisherCollection.Find(context.Background(), scoped(libraryID, bson.M{"$or": []bson.M{{"name": name}, {"aliases": name}}}))
+	if err != nil {
+		return err
+	}
+	var publishers []model.Publisher
+	if err := cursor.All(context.Background(), &publishers); err != nil {
+		return err
+	}
+	if len(publishers) == 1 {
+		book.PublisherID = publishers[0].ID
+		book.Publisher = publishers[0].Name
+	}
+	return nil
+}
+
+func insertPublisher(publisher *model.Publisher, req requestInfo) error {
+	if err := checkQuota(req.LibraryID, auditEntityPublisher, 1); err != nil {
+		return err
+	}
+	if err := checkPublisherParent(req.LibraryID, primitive.NilObjectID, publisher.ParentID); err != nil {
+		return err
+	}
+	stampCreated(&publisher.Timestamps, req.Actor)
+	publisher.LibraryID = req.LibraryID
+
+	inserted, err := publisherCollection.InsertOne(context.Background(), publisher)
+	if err != nil {
+		return err
+	}
+
+	publisher.ID = inserted.InsertedID.(primitive.ObjectID)
+	recordAudit(req, auditEntityPublisher, auditActionCreate, publisher.ID, nil, snapshot(publisherCollection, publisher.ID))
+	return nil
+}
+
+// set the publisher name of the books linked to a publisher, e.g. after a rename or merge
+func relinkPublisherBooks(filter bson.M, publisher model.Publisher, req requestInfo) error {
+	filter = scoped(req.LibraryID, filter)
+	booksBefore := snapshots(bookCollection, filter)
+	update := bson.M{"$set": stampUpdated(bson.M{"publisherId": publisher.ID, "publisher": publisher.Name}, req.Actor)}
+	if _, err := bookCollection.UpdateMany(context.Background(), filter, update); err != nil {
+		return err
+	}
+	for _, book := range booksBefore {
+		bookID := book["_id"].(primitive.ObjectID)
+		recordAudit(req, auditEntityBook, auditActionUpdate, bookID, book, snapshot(bookCollection, bookID))
+	}
+	return nil
+}
+
+func updatePublisher(id primitive.ObjectID, publisher model.Publisher, req requestInfo) error {
+	current, err := findPublisher(req.LibraryID, id)
+	if err != nil {
+		return err
+	}
+	if err := checkPublisherParent(req.LibraryID, id, publisher.ParentID); err != nil {
+		return err
+	}
+
+	set := bson.M{"name": publisher.Name, "aliases": publisher.Aliases, "website": publisher.Website}
+	update := bson.M{"$set": stampUpdated(set, req.Actor)}
+	if publisher.ParentID.IsZero() {
+		update["$unset"] = bson.M{"parentId": ""}
+	} else {
+		set["parentId"] = publisher.ParentID
+	}
+
+	before := snapshot(publisherCollection, id)
+	if _, err := publisherCollection.UpdateOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}), update); err != nil {
+		return err
+	}
+	recordAudit(req, auditEntityPublisher, auditActionUpdate, id, before, snapshot(publisherCollection, id))
+
+	if current.Name == publisher.Name {
+		return nil
+	}
+	publisher.ID = id
+	return relinkPublisherBooks(bson.M{"publisherId": id}, publisher, req)
+}
+
+// delete a publisher without imprints, its books keep the publisher name but lose the link
+func deletePublisher(id primitive.ObjectID, req requestInfo) error {
+	imprints, err := publisherCollection.CountDocuments(context.Background(), scoped(req.LibraryID, bson.M{"parentId": id}))
+	if err != nil {
+		return err
+	}
+	if imprints > 0 {
+		return errPublisherHasImprints
+	}
+
+	before := snapshot(publisherCollection, id)
+	result, err := publisherCollection.DeleteOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errPublisherNotFound
+	}
+	recordAudit(req, auditEntityPublisher, auditActionDelete, id, before, nil)
+
+	bookFilter := scoped(req.LibraryID, bson.M{"publisherId": id})
+	booksBefore := snapshots(bookCollection, bookFilter)
+	update := bson.M{"$unset": bson.M{"publisherId": ""}, "$set": stampUpdated(bson.M{}, req.Actor)}
+	if _, err := bookCollection.UpdateMany(context.Background(), bookFilter, update); err != nil {
+		return err
+	}
+	for _, book := range booksBefore {
+		bookID := book["_id"].(primitive.ObjectID)
+		recordAudit(req, auditEntityBook, auditActionUpdate, bookID, book, snapshot(bookCollection, bookID))
+	}
+	return nil
+}
+
+// merge publishers entered twice into one: their books and imprints move over and
+// their names become aliases, so later imports link to the publisher that remains
+func mergePublishers(id primitive.ObjectID, duplicateIDs []primitive.ObjectID, req requestInfo) (model.Publisher, error) {
+	target, err := findPublisher(req.LibraryID, id)
+	if err != nil {
+		return target, err
+	}
+
+	var duplicates []model.Publisher
+	for _, duplicateID := range duplicateIDs {
+		if duplicateID == id {
+			return target, errPublisherSelfMerge
+		}
+		duplicate, err := findPublisher(req.LibraryID, duplicateID)
+		if err != nil {
+			return target, err
+		}
+		if duplicate.ID == target.ParentID {
+			return target, errPublisherMergeIntoImprint
+		}
+		if !target.ParentID.IsZero() {
+			count, err := publisherCollection.CountDocuments(context.Background(), scoped(req.LibraryID, bson.M{"parentId": duplicate.ID}))
+			if err != nil {
+				return target, err
+			}
+			if count > 0 {
+				return target, errImprintNesting
+			}
+		}
+		duplicates = append(duplicates, duplicate)
+	}
+
+	aliases := target.Aliases
+	for _, duplicate := range duplicates {
+		aliases = append(aliases, duplicate.Name)
+		aliases = append(aliases, duplicate.Aliases...)
+	}
+	target.Aliases = normalizeAliases(target.Name, aliases)
+
+	before := snapshot(publisherCollection, id)
+	update := bson.M{"$set": stampUpdated(bson.M{"aliases": target.Aliases}, req.Actor)}
+	if _, err := publisherCollection.UpdateOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}), update); err != nil {
+		return target, err
+	}
+	recordAudit(req, auditEntityPublisher, auditActionUpdate, id, before, snapshot(publisherCollection, id))
+
+	for _, duplicate := range duplicates {
+		if err := relinkPublisherBooks(bson.M{"publisherId": duplicate.ID}, target, req); err != nil {
+			return target, err
+		}
+
+		imprintFilter := scoped(req.LibraryID, bson.M{"parentId": duplicate.ID})
+		imprintsBefore := snapshots(publisherCollection, imprintFilter)
+		move := bson.M{"$set": stampUpdated(bson.M{"parentId": id}, req.Actor)}
+		if _, err := publisherCollection.UpdateMany(context.Background(), imprintFilter, move); err != nil {
+			return target, err
+		}
+		for _, imprint := range imprintsBefore {
+			imprintID := imprint["_id"].(primitive.ObjectID)
+			recordAudit(req, auditEntityPublisher, auditActionUpdate, imprintID, imprint, snapshot(publisherCollection, imprintID))
+		}
+
+		duplicateBefore := snapshot(publisherCollection, duplicate.ID)
+		if _, err := publisherCollection.DeleteOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": duplicate.ID})); err != nil {
+			return target, err
+		}
+		recordAudit(req, auditEntityPublisher, auditActionDelete, duplicate.ID, duplicateBefore, nil)
+	}
+	return target, nil
+}
+
+func getPublisherWithImprints(id primitive.ObjectID, libraryID primitive.ObjectID) (model.PublisherWithImprints, error) {
+	var publisher model.PublisherWithImprints
+
+	var err error
+	if publisher.Publisher, err = findPublisher(libraryID, id); err != nil {
+		return publisher, err
+	}
+
+	publisher.Imprints, err = getAllPublishers(libraryID, bson.M{"parentId": id}, nil)
+	if err != nil {
+		return publisher, err
+	}
+
+	family := []primitive.ObjectID{id}
+	for _, imprint := range publisher.Imprints {
+		family = append(family, imprint.ID)
+	}
+	publisher.Books, err = bookCollection.CountDocuments(context.Background(), scoped(libraryID, bson.M{"publisherId": bson.M{"$in": family}}))
+	return publisher, err
+}
+
+func getAllPublishers(libraryID primitive.ObjectID, match bson.M, sort bson.D) ([]model.Publisher, error) {
+	publishers := []model.Publisher{}
+
+	opts := options.Find()
+	if len(sort) > 0 {
+		opts.SetSort(sort)
+	} else {
+		opts.SetSort(bson.D{{Key: "name", Value: 1}})
+	}
+
+	cursor, err := publisherCollection.Find(context.Background(), scoped(libraryID, match), opts)
+	if err != nil {
+		return publishers, err
+	}
+	err = cursor.All(context.Background(), &publishers)
+	return publishers, err
+}
+
+func publisherIdParam(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("publisherId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid publisher ID"})
+		return id, false
+	}
+	return id, true
+}
+
+// answer a publisher write error, false when there was none
+func publisherWriteError(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return false
+	case errPublisherNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Publisher not found"})
+	case errPublisherHasImprints:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errPublisherParentNotFound, errImprintNesting, errPublisherSelfMerge, errPublisherMergeIntoImprint:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errQuotaExceeded:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Publisher quota exceeded for this library"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing publisher"})
+	}
+	return true
+}
+
+func CreatePublisher(c *gin.Context) {
+	var publisher model.Publisher
+	if err := c.ShouldBindJSON(&publisher); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validPublisher(&publisher); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if publisherWriteError(c, insertPublisher(&publisher, requestFrom(c))) {
+		return
+	}
+	c.JSON(http.StatusCreated, publisher)
+}
+
+// GET /publisher/all?name=penguin&imprints=false leaves imprints out
+func GetAllPublishers(c *gin.Context) {
+	match, sort, err := timestampListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if name := strings.TrimSpace(c.Query("name")); name != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(name), Options: "i"}
+		match["$or"] = []bson.M{{"name": pattern}, {"aliases": pattern}}
+	}
+	if c.Query("imprints") == "false" {
+		match["parentId"] = bson.M{"$exists": false}
+	}
+	if serveCached(c) {
+		return
+	}
+
+	libraryID := requestFrom(c).LibraryID
+	publishers, err := getAllPublishers(libraryID, match, sort)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading publishers"})
+		return
+	}
+	respondCached(c, publishers, listTag(libraryID, auditEntityPublisher))
+}
+
+func GetPublisher(c *gin.Context) {
+	id, ok := publisherIdParam(c)
+	if !ok {
+		return
+	}
+	if serveCached(c) {
+		return
+	}
+
+	libraryID := requestFrom(c).LibraryID
+	publisher, err := getPublisherWithImprints(id, libraryID)
+	if err == errPublisherNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Publisher not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading publisher"})
+		return
+	}
+	respondCached(c, publisher, listTag(libraryID, auditEntityPublisher), listTag(libraryID, auditEntityBook))
+}
+
+// the books of a publisher and its imprints, with the /book/all filters;
+// ?imprints=false only lists the books published under the publisher itself
+func GetPublisherBooks(c *gin.Context) {
+	id, ok := publisherIdParam(c)
+	if !ok {
+		return
+	}
+	match, sort, err := bookListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if serveCached(c) {
+		return
+	}
+
+	req := requestFrom(c)
+	if _, err := findPublisher(req.LibraryID, id); err == errPublisherNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Publisher not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading publisher"})
+		return
+	}
+
+	if c.Query("imprints") == "false" {
+		match["publisherId"] = id
+	} else {
+		family, err := publisherFamily(req.LibraryID, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading publisher"})
+			return
+		}
+		match["publisherId"] = bson.M{"$in": family}
+	}
+
+	books := getAllBooksWithAuthors(req.LibraryID, req.Actor, match, sort)
+	respondCached(c, books, listTag(req.LibraryID, auditEntityPublisher), listTag(req.LibraryID, auditEntityBook))
+}
+
+func UpdatePublisher(c *gin.Context) {
+	id, ok := publisherIdParam(c)
+	if !ok {
+		return
+	}
+	var publisher model.Publisher
+	if err := c.ShouldBindJSON(&publisher); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validPublisher(&publisher); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if publisherWriteError(c, updatePublisher(id, publisher, requestFrom(c))) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Updated"})
+}
+
+func DeletePublisher(c *gin.Context) {
+	id, ok := publisherIdParam(c)
+	if !ok {
+		return
+	}
+
+	if publisherWriteError(c, deletePublisher(id, requestFrom(c))) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Deleted"})
+}
+
+// POST /publisher/:publisherId/merge {"publishers": ["<duplicate id>"]}
+func MergePublishers(c *gin.Context) {
+	id, ok := publisherIdParam(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Publishers []string `json:"publishers"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body.Publishers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one publisher to merge is required"})
+		return
+	}
+	var duplicateIDs []primitive.ObjectID
+	for _, value := range body.Publishers {
+		duplicateID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid publisher ID " + value})
+			return
+		}
+		if !containsID(duplicateIDs, duplicateID) {
+			duplicateIDs = append(duplicateIDs, duplicateID)
+		}
+	}
+
+	publisher, err := mergePublishers(id, duplicateIDs, requestFrom(c))
+	if publisherWriteError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, publisher)
+}
@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"errors"
+	"example/books-api/isbn"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	importSourceCSV        = "csv"
+	importSourceGoodreads  = "goodreads"
+	importSourceStoryGraph = "storygraph"
+)
+
+// both services write dates as 2006/01/02, StoryGraph sometimes with dashes
+var importDateLayouts = []string{"2006/01/02", "2006-01-02", "2006/1/2"}
+
+// shelf and read status names mapped onto our reading states
+var readingStatusAliases = map[string]string{
+	"to-read":           model.ReadingStatusToRead,
+	"want-to-read":      model.ReadingStatusToRead,
+	"currently-reading": model.ReadingStatusReading,
+	"reading":           model.ReadingStatusReading,
+	"paused":            model.ReadingStatusReading,
+	"read":              model.ReadingStatusRead,
+	"did-not-finish":    model.ReadingStatusDidNotFinish,
+	"dnf":               model.ReadingStatusDidNotFinish,
+	"abandoned":         model.ReadingStatusDidNotFinish,
+}
+
+var importParsers = map[string]func(header []string) (catalogRowParser, error){
+	importSourceGoodreads:  goodreadsRowParser,
+	importSourceStoryGraph: storyGraphRowParser,
+}
+
+// column lookup by header name, ignoring case and surrounding spaces
+type importColumns map[string]int
+
+func newImportColumns(header []string) importColumns {
+	columns := importColumns{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return columns
+}
+
+func (columns importColumns) require(names ...string) error {
+	for _, name := range names {
+		if _, ok := columns[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("missing %s column", name)
+		}
+	}
+	return nil
+}
+
+func (columns importColumns) get(values []string, name string) string {
+	if i, ok := columns[strings.ToLower(name)]; ok && i < len(values) {
+		return strings.TrimSpace(values[i])
+	}
+	return ""
+}
+
+// Goodreads writes ISBNs as ="0439023483" so spreadsheets keep leading zeros
+func cleanImportISBN(value string) string {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "=")
+	return strings.Trim(value, `"`)
+}
+
+// fill both ISBN fields from either form, identifiers that are not valid ISBNs
+// (StoryGraph exports its own UIDs for some books) are dropped
+func assignImportISBN(record *catalogRecord, value string) {
+	if record.ISBN13 != "" {
+		return
+	}
+	if isbn10, isbn13, err := isbn.Parse(cleanImportISBN(value)); err == nil {
+		record.ISBN10, record.ISBN13 = isbn10, isbn13
+	}
+}
+
+// copy the descriptive fields of an export onto the record; exports carry
+// whatever their users typed, so values that do not validate are dropped
+func assignImportDetails(record *catalogRecord, details model.Book) {
+	cleanBookDetails(&details)
+	record.Publisher = details.Publisher
+	record.PublishedDate, record.Year = details.PublishedDate, details.PublicationYear
+	record.PageCount, record.Format = details.PageCount, details.Format
+}
+
+// a positive number or 0 for anything else
+func lenientImportInt(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+func parseImportDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range importDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date %q", value)
+}
+
+// ratings are 1-5 stars, 0 or empty means unrated
+func parseImportRating(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	rating, err := strconv.ParseFloat(value, 64)
+	if err != nil || rating < 0 || rating > 5 {
+		return 0, fmt.Errorf("invalid rating %q", value)
+	}
+	return rating, nil
+}
+
+// mark the record read or unread from its reading status
+func setImportStatus(record *catalogRecord, status string) {
+	record.ReadingStatus = status
+	read := status == model.ReadingStatusRead
+	record.Read = &read
+}
+
+func splitImportList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Goodreads "Export Library" CSV: Title, Author, Additional Authors, ISBN, ISBN13,
+// Publisher, Binding, Number of Pages, Year Published, My Rating, Date Read,
+// Bookshelves and Exclusive Shelf
+func goodreadsRowParser(header []string) (catalogRowParser, error) {
+	columns := newImportColumns(header)
+	if err := columns.require("Title", "Author", "Exclusive Shelf"); err != nil {
+		return nil, err
+	}
+
+	return func(values []string) (catalogRecord, error) {
+		record := catalogRecord{Title: columns.get(values, "Title")}
+
+		record.Authors = splitAuthorNames(columns.get(values, "Author"), catalogAuthorSeparator)
+		record.Authors = append(record.Authors, splitImportList(columns.get(values, "Additional Authors"))...)
+
+		assignImportISBN(&record, columns.get(values, "ISBN"))
+		assignImportISBN(&record, columns.get(values, "ISBN13"))
+		assignImportDetails(&record, model.Book{
+			Publisher:       columns.get(values, "Publisher"),
+			PublicationYear: lenientImportInt(columns.get(values, "Year Published")),
+			PageCount:       lenientImportInt(columns.get(values, "Number of Pages")),
+			Format:          columns.get(values, "Binding"),
+		})
+
+		var err error
+		if record.Rating, err = parseImportRating(columns.get(values, "My Rating")); err != nil {
+			return record, err
+		}
+		if record.DateRead, err = parseImportDate(columns.get(values, "Date Read")); err != nil {
+			return record, err
+		}
+
+		// The exclusive shelf is the reading state, every other shelf is kept as a shelf
+		exclusive := strings.ToLower(columns.get(values, "Exclusive Shelf"))
+		if status, ok := readingStatusAliases[exclusive]; ok {
+			setImportStatus(&record, status)
+		} else if exclusive != "" {
+			record.Shelves = append(record.Shelves, exclusive)
+		}
+		for _, shelf := range splitImportList(columns.get(values, "Bookshelves")) {
+			if _, ok := readingStatusAliases[strings.ToLower(shelf)]; !ok && !strings.EqualFold(shelf, exclusive) {
+				record.Shelves = append(record.Shelves, shelf)
+			}
+		}
+
+		if record.DateRead != nil && record.ReadingStatus == "" {
+			setImportStatus(&record, model.ReadingStatusRead)
+		}
+		return record, nil
+	}, nil
+}
+
+// StoryGraph CSV export: Title, Authors, ISBN/UID, Format, Read Status,
+// Star Rating, Last Date Read and Tags
+func storyGraphRowParser(header []string) (catalogRowParser, error) {
+	columns := newImportColumns(header)
+	if err := columns.require("Title", "Authors", "Read Status"); err != nil {
+		return nil, err
+	}
+
+	return func(values []string) (catalogRecord, error) {
+		record := catalogRecord{
+			Title:   columns.get(values, "Title"),
+			Authors: splitImportList(columns.get(values, "Authors")),
+			Shelves: splitImportList(columns.get(values, "Tags")),
+		}
+
+		assignImportISBN(&record, columns.get(values, "ISBN/UID"))
+		assignImportDetails(&record, model.Book{Format: columns.get(values, "Format")})
+
+		var err error
+		if record.Rating, err = parseImportRating(columns.get(values, "Star Rating")); err != nil {
+			return record, err
+		}
+		if record.DateRead, err = parseImportDate(columns.get(values, "Last Date Read")); err != nil {
+			return record, err
+		}
+
+		status := strings.ToLower(columns.get(values, "Read Status"))
+		if mapped, ok := readingStatusAliases[status]; ok {
+			setImportStatus(&record, mapped)
+		} else if status != "" {
+			return record, fmt.Errorf("unknown read status %q", status)
+		}
+		return record, nil
+	}, nil
+}
+
+// POST /import/:source?dryRun=true previews the import without writing anything
+func ImportLibrary(c *gin.Context) {
+	source := c.Param("source")
+	newParser, ok := importParsers[source]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown import source"})
+		return
+	}
+
+	reader, err := importReader(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	imp := newCatalogImporter(requestFrom(c), source, c.Query("dryRun") == "true")
+	if err := importRows(reader, newParser, imp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": imp.report})
+		return
+	}
+
+	c.JSON(http.StatusOK, imp.report)
+}
+
+// reading status must be a known state and the rating 0 to 5 stars
+func validateReadingState(book model.Book) error {
+	if book.ReadingStatus != "" {
+		if status, ok := readingStatusAliases[book.ReadingStatus]; !ok || status != book.ReadingStatus {
+			return fmt.Errorf("unknown readingStatus %q", book.ReadingStatus)
+		}
+	}
+	if book.Rating < 0 || book.Rating > 5 {
+		return errors.New("rating must be between 0 and 5")
+	}
+	return nil
+}
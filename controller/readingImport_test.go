@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"example/books-api/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func datePtr(year int, month time.Month, day int) *time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+var goodreadsHeader = []string{
+	"Book Id", "Title", "Author", "Additional Authors", "ISBN", "ISBN13", "My Rating", "Publisher",
+	"Binding", "Number of Pages", "Year Published", "Date Read", "Bookshelves", "Exclusive Shelf",
+}
+
+func TestGoodreadsRowParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    catalogRecord
+		wantErr bool
+	}{
+		{
+			name: "read book with shelves",
+			values: []string{"2767052", "The Hunger Games", "Suzanne Collins", "", `="0439023483"`, `="9780439023481"`, "5", "Scholastic Press",
+				"Hardcover", "374", "2008", "2012/01/15", "favorites, read", "read"},
+			want: catalogRecord{
+				Title:         "The Hunger Games",
+				Authors:       []string{"Suzanne Collins"},
+				ISBN10:        "0439023483",
+				ISBN13:        "9780439023481",
+				Publisher:     "Scholastic Press",
+				Year:          2008,
+				PageCount:     374,
+				Format:        model.BookFormatHardcover,
+				Rating:        5,
+				DateRead:      datePtr(2012, time.January, 15),
+				ReadingStatus: model.ReadingStatusRead,
+				Read:          boolPtr(true),
+				Shelves:       []string{"favorites"},
+			},
+		},
+		{
+			name: "additional authors, to-read, values that do not validate",
+			values: []string{"1", "Good Omens", "Terry Pratchett", "Neil Gaiman, ", `=""`, `=""`, "0", "",
+				"Comic", "-1", "unknown", "", "to-read", "to-read"},
+			want: catalogRecord{
+				Title:         "Good Omens",
+				Authors:       []string{"Terry Pratchett", "Neil Gaiman"},
+				ReadingStatus: model.ReadingStatusToRead,
+				Read:          boolPtr(false),
+			},
+		},
+		{
+			name: "custom exclusive shelf with a read date",
+			values: []string{"1", "Dune", "Frank Herbert", "", "", "", "", "",
+				"", "", "", "2020/5/3", "owned, sci-fi", "owned"},
+			want: catalogRecord{
+				Title:         "Dune",
+				Authors:       []string{"Frank Herbert"},
+				DateRead:      datePtr(2020, time.May, 3),
+				ReadingStatus: model.ReadingStatusRead,
+				Read:          boolPtr(true),
+				Shelves:       []string{"owned", "sci-fi"},
+			},
+		},
+		{
+			name:    "rating out of range",
+			values:  []string{"1", "Dune", "Frank Herbert", "", "", "", "6", "", "", "", "", "", "", "read"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid read date",
+			values:  []string{"1", "Dune", "Frank Herbert", "", "", "", "", "", "", "", "", "15 Jan", "", "read"},
+			wantErr: true,
+		},
+	}
+
+	parse, err := goodreadsRowParser(goodreadsHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := goodreadsRowParser([]string{"Title", "Author"}); err == nil {
+		t.Errorf("header without the Exclusive Shelf column was accepted")
+	}
+}
+
+func TestStoryGraphRowParser(t *testing.T) {
+	header := []string{"Title", "Authors", "Contributors", "ISBN/UID", "Format", "Read Status", "Last Date Read", "Star Rating", "Tags"}
+
+	tests := []struct {
+		name    string
+		values  []string
+		want    catalogRecord
+		wantErr bool
+	}{
+		{
+			name:   "read book",
+			values: []string{"Good Omens", "Terry Pratchett, Neil Gaiman", "", "9780060853983", "paperback", "read", "2021-03-04", "4.5", "fantasy, humor"},
+			want: catalogRecord{
+				Title:         "Good Omens",
+				Authors:       []string{"Terry Pratchett", "Neil Gaiman"},
+				ISBN10:        "0060853980",
+				ISBN13:        "9780060853983",
+				Format:        model.BookFormatPaperback,
+				Rating:        4.5,
+				DateRead:      datePtr(2021, time.March, 4),
+				ReadingStatus: model.ReadingStatusRead,
+				Read:          boolPtr(true),
+				Shelves:       []string{"fantasy", "humor"},
+			},
+		},
+		{
+			name:   "paused book with a StoryGraph UID",
+			values: []string{"Dune", "Frank Herbert", "", "a1b2c3", "digital", "Paused", "", "", ""},
+			want: catalogRecord{
+				Title:         "Dune",
+				Authors:       []string{"Frank Herbert"},
+				Format:        model.BookFormatEbook,
+				ReadingStatus: model.ReadingStatusReading,
+				Read:          boolPtr(false),
+			},
+		},
+		{
+			name:    "unknown read status",
+			values:  []string{"Dune", "Frank Herbert", "", "", "", "someday", "", "", ""},
+			wantErr: true,
+		},
+	}
+
+	parse, err := storyGraphRowParser(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportDate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *time.Time
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "2012/01/15", want: datePtr(2012, time.January, 15)},
+		{value: "2012-01-15", want: datePtr(2012, time.January, 15)},
+		{value: "2012/1/5", want: datePtr(2012, time.January, 5)},
+		{value: "15/01/2012", wantErr: true},
+		{value: "2012/13/01", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseImportDate(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseImportDate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseImportDate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"context"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var readingStateCollection *mongo.Collection
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		readingStateCollection = db.Collection("readingStates")
+
+		// One state per reader and book
+		ensureIndexes(readingStateCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "libraryId", Value: 1}, {Key: "actor", Value: 1}, {Key: "book", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+
+		fmt.Println("Reading state collection istance is ready")
+		return nil
+	})
+}
+
+// the reader's states of the given books by book ID, books the reader has no state for are left out
+func readingStatesFor(libraryID primitive.ObjectID, actor string, bookIDs []primitive.ObjectID) (map[primitive.ObjectID]model.ReadingState, error) {
+	states := map[primitive.ObjectID]model.ReadingState{}
+	if len(bookIDs) == 0 {
+		return states, nil
+	}
+
+	filter := scoped(libraryID, bson.M{"actor": actor, "book": bson.M{"$in": bookIDs}})
+	cursor, err := readingStateCollection.Find(context.Background(), filter)
+	if err != nil {
+		return states, err
+	}
+	var found []model.ReadingState
+	if err := cursor.All(context.Background(), &found); err != nil {
+		return states, err
+	}
+	for _, state := range found {
+		states[state.Book] = state
+	}
+	return states, nil
+}
+
+// create or replace the reader's state of a book; reading a book
+// without a status gives it the read status and the other way round
+func setReadingState(bookID primitive.ObjectID, state model.ReadingState, req requestInfo) (model.ReadingState, error) {
+	if state.Read && state.ReadingStatus == "" {
+		state.ReadingStatus = model.ReadingStatusRead
+	}
+	if state.ReadingStatus == model.ReadingStatusRead {
+		state.Read = true
+	}
+
+	filter := scoped(req.LibraryID, bson.M{"actor": req.Actor, "book": bookID})
+	before := snapshots(readingStateCollection, filter)
+
+	set := stampUpdated(bson.M{"read": state.Read}, req.Actor)
+	update := bson.M{
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"libraryId": req.LibraryID,
+			"actor":     req.Actor,
+			"book":      bookID,
+			"createdAt": set["updatedAt"],
+			"createdBy": req.Actor,
+		},
+	}
+	unset := bson.M{}
+	if state.ReadingStatus != "" {
+		set["readingStatus"] = state.ReadingStatus
+	} else {
+		unset["readingStatus"] = ""
+	}
+	if state.DateRead != nil {
+		set["dateRead"] = state.DateRead
+	} else {
+		unset["dateRead"] = ""
+	}
+	if state.Rating != 0 {
+		set["rating"] = state.Rating
+	} else {
+		unset["rating"] = ""
+	}
+	if len(state.Shelves) > 0 {
+		set["shelves"] = state.Shelves
+	} else {
+		unset["shelves"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var saved model.ReadingState
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := readingStateCollection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&saved); err != nil {
+		return saved, err
+	}
+
+	var previous bson.M
+	action := auditActionCreate
+	if len(before) > 0 {
+		previous, action = before[0], auditActionUpdate
+	}
+	recordAudit(req, auditEntityReadingState, action, saved.ID, previous, snapshot(readingStateCollection, saved.ID))
+	return saved, nil
+}
+
+// the reading state a book sent to the book routes carries, false when it carries none;
+// it belongs to the requesting reader and never to the shared book
+func bookReadingState(book model.Book) (model.ReadingState, bool) {
+	state := model.ReadingState{
+		Read:          book.Read,
+		ReadingStatus: book.ReadingStatus,
+		Rating:        book.Rating,
+		DateRead:      book.DateRead,
+		Shelves:       book.Shelves,
+	}
+	given := state.Read || state.ReadingStatus != "" || state.Rating != 0 || state.DateRead != nil || len(state.Shelves) > 0
+	return state, given
+}
+
+// $lookup and $addFields giving each book of an aggregation the reader's own
+// read, readingStatus, rating, dateRead and shelves; run them after the $project
+func readingStateStages(libraryID primitive.ObjectID, actor string) []bson.M {
+	fields := bson.M{}
+	for _, field := range []string{"read", "readingStatus", "rating", "dateRead", "shelves"} {
+		fields[field] = bson.M{"$arrayElemAt": []interface{}{"$readingState." + field, 0}}
+	}
+	return []bson.M{
+		{"$lookup": bson.M{
+			"from": "readingStates",
+			"let":  bson.M{"book": "$_id"},
+			"pipeline": []bson.M{
+				{"$match": bson.M{
+					"libraryId": libraryID,
+					"actor":     actor,
+					"$expr":     bson.M{"$eq": []interface{}{"$book", "$$book"}},
+				}},
+			},
+			"as": "readingState",
+		}},
+		{"$addFields": fields},
+		{"$project": bson.M{"readingState": 0}},
+	}
+}
+
+// the reader marked the book read today unless it already has a date
+func markReadFor(bookID primitive.ObjectID, req requestInfo) error {
+	states, err := readingStatesFor(req.LibraryID, req.Actor, []primitive.ObjectID{bookID})
+	if err != nil {
+		return err
+	}
+	state := states[bookID]
+	state.Read, state.ReadingStatus = true, model.ReadingStatusRead
+	if state.DateRead == nil {
+		now := time.Now().UTC()
+		state.DateRead = &now
+	}
+	_, err = setReadingState(bookID, state, req)
+	return err
+}
+
+// GET /book/:bookId/reading-state answers the requesting user's state of the book
+func GetReadingState(c *gin.Context) {
+	bookID, err := primitive.ObjectIDFromHex(c.Param("bookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	req := requestFrom(c)
+	if _, err := findTaggedBook(bookID, req.LibraryID); err == errBookNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading book"})
+		return
+	}
+
+	states, err := readingStatesFor(req.LibraryID, req.Actor, []primitive.ObjectID{bookID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading reading state"})
+		return
+	}
+	state, ok := states[bookID]
+	if !ok {
+		state = model.ReadingState{Actor: req.Actor, Book: bookID}
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// read and check the state sent with a request, answering when it is invalid
+func bindReadingState(c *gin.Context) (model.ReadingState, bool) {
+	var state model.ReadingState
+	if err := c.ShouldBindJSON(&state); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return state, false
+	}
+	if err := validateReadingState(model.Book{ReadingStatus: state.ReadingStatus, Rating: state.Rating}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return state, false
+	}
+	return state, true
+}
+
+// PUT /book/:bookId/reading-state sets the requesting user's state of the book,
+// other readers of the library keep their own
+func SetReadingState(c *gin.Context) {
+	bookID, err := primitive.ObjectIDFromHex(c.Param("bookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	state, ok := bindReadingState(c)
+	if !ok {
+		return
+	}
+
+	req := requestFrom(c)
+	if _, err := findTaggedBook(bookID, req.LibraryID); err == errBookNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading book"})
+		return
+	}
+
+	saved, err := setReadingState(bookID, state, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving reading state"})
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const anonymousActor = "anonymous"
+
+// who made the request, under which request ID and in which library; used for
+// stamps, audit entries and tenant scoping
+type requestInfo struct {
+	Actor     string
+	RequestID string
+	LibraryID primitive.ObjectID
+}
+
+// the actor only ever comes from the authenticated claims, never from a request header
+func requestFrom(c *gin.Context) requestInfo {
+	actor := c.GetString(middleware.ActorKey)
+	if actor == "" {
+		actor = anonymousActor
+	}
+
+	libraryID, _ := primitive.ObjectIDFromHex(c.GetString(middleware.LibraryIDKey))
+
+	return requestInfo{Actor: actor, RequestID: c.GetString(middleware.RequestIDKey), LibraryID: libraryID}
+}
@@ -0,0 +1,435 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const auditActionRestore = "restore"
+
+var revisionCollection *mongo.Collection
+
+var errRevisionNotFound = errors.New("revision not found")
+
+// how often saving a revision retries when another save took its number
+const maxRevisionAttempts = 5
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		revisionCollection = db.Collection("revisions")
+
+		// Revision numbers are unique per document, concurrent saves retry with the next one
+		ensureIndexes(revisionCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "entity", Value: 1}, {Key: "entityId", Value: 1}, {Key: "revision", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+
+		fmt.Println("Revision collection istance is ready")
+		return nil
+	})
+}
+
+// what a revision is taken of: the document and its bookAuthor links
+type revisionTarget struct {
+	entity     string
+	collection *mongo.Collection
+	linkField  string // field in bookAuthor pointing at this entity
+	otherField string // field in bookAuthor pointing at the linked entity
+}
+
+func bookRevisionTarget() revisionTarget {
+	return revisionTarget{entity: auditEntityBook, collection: bookCollection, linkField: "book", otherField: "author"}
+}
+
+func authorRevisionTarget() revisionTarget {
+	return revisionTarget{entity: auditEntityAuthor, collection: collection, linkField: "author", otherField: "book"}
+}
+
+// the current state of a document and its links, taken before a change and stored
+// with storeRevision once the change succeeded; nil if the document is not in the library
+func captureRevision(target revisionTarget, id primitive.ObjectID, req requestInfo) *model.Revision {
+	doc := snapshot(target.collection, id)
+	if doc == nil || doc["libraryId"] != req.LibraryID {
+		return nil
+	}
+
+	links := snapshots(bookAuthor, scoped(req.LibraryID, bson.M{target.linkField: id}))
+	if links == nil {
+		links = []bson.M{}
+	}
+
+	return &model.Revision{
+		LibraryID: req.LibraryID,
+		Entity:    target.entity,
+		EntityID:  id,
+		Document:  doc,
+		Links:     links,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: req.Actor,
+		RequestID: req.RequestID,
+	}
+}
+
+// store a captured revision under the next free number, ctx may be the session of a bulk transaction
+func storeRevision(ctx context.Context, revision *model.Revision) error {
+	if revision == nil {
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		latest, err := latestRevision(revision.Entity, revision.EntityID)
+		if err != nil {
+			return err
+		}
+		revision.Revision = latest + 1
+
+		_, err = revisionCollection.InsertOne(ctx, revision)
+		if !mongo.IsDuplicateKeyError(err) || attempt == maxRevisionAttempts {
+			return err
+		}
+	}
+}
+
+// highest revision number stored for a document, 0 if none
+func latestRevision(entity string, id primitive.ObjectID) (int, error) {
+	var revision model.Revision
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "revision", Value: -1}})
+	err := revisionCollection.FindOne(context.Background(), bson.M{"entity": entity, "entityId": id}, opts).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+
+	return revision.Revision, err
+}
+
+// get all revisions of a document, oldest first
+func getRevisions(entity string, id primitive.ObjectID, libraryID primitive.ObjectID) ([]model.Revision, error) {
+	revisions := []model.Revision{}
+
+	opts := options.Find().SetSort(bson.D{{Key: "revision", Value: 1}})
+	filter := scoped(libraryID, bson.M{"entity": entity, "entityId": id})
+	cursor, err := revisionCollection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return revisions, err
+	}
+
+	err = cursor.All(context.Background(), &revisions)
+	return revisions, err
+}
+
+func getRevision(entity string, id primitive.ObjectID, rev int, libraryID primitive.ObjectID) (model.Revision, error) {
+	var revision model.Revision
+
+	filter := scoped(libraryID, bson.M{"entity": entity, "entityId": id, "revision": rev})
+	err := revisionCollection.FindOne(context.Background(), filter).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return revision, errRevisionNotFound
+	}
+
+	return revision, err
+}
+
+// document plus the IDs it is linked to with their roles, so link changes show up in a diff
+func comparableRevision(target revisionTarget, doc bson.M, links []bson.M) bson.M {
+	comparable := bson.M{}
+	for key, value := range doc {
+		comparable[key] = value
+	}
+
+	linked := []string{}
+	for _, link := range links {
+		if id, ok := link[target.otherField].(primitive.ObjectID); ok {
+			if role, ok := link["role"].(string); ok && role != "" {
+				linked = append(linked, id.Hex()+":"+role)
+			} else {
+				linked = append(linked, id.Hex())
+			}
+		}
+	}
+	sort.Strings(linked)
+	comparable["links"] = linked
+
+	return comparable
+}
+
+// diff two revisions, to == 0 compares against the current document
+func diffRevisions(target revisionTarget, id primitive.ObjectID, from int, to int, libraryID primitive.ObjectID) (model.RevisionDiff, error) {
+	diff := model.RevisionDiff{From: from, To: to}
+
+	fromRevision, err := getRevision(target.entity, id, from, libraryID)
+	if err != nil {
+		return diff, err
+	}
+	before := comparableRevision(target, fromRevision.Document, fromRevision.Links)
+
+	var after bson.M
+	if to == 0 {
+		current := snapshot(target.collection, id)
+		if current == nil || current["libraryId"] != libraryID {
+			current = bson.M{}
+		}
+		after = comparableRevision(target, current, snapshots(bookAuthor, scoped(libraryID, bson.M{target.linkField: id})))
+	} else {
+		toRevision, err := getRevision(target.entity, id, to, libraryID)
+		if err != nil {
+			return diff, err
+		}
+		after = comparableRevision(target, toRevision.Document, toRevision.Links)
+	}
+
+	diff.Changes = diffDocuments(before, after)
+	return diff, nil
+}
+
+// roll a document and its author links back to a stored revision
+func restoreRevision(target revisionTarget, id primitive.ObjectID, rev int, req requestInfo) error {
+	revision, err := getRevision(target.entity, id, rev, req.LibraryID)
+	if err != nil {
+		return err
+	}
+
+	// Never let a restore take over an ID that now belongs to another library
+	if current := snapshot(target.collection, id); current != nil && current["libraryId"] != req.LibraryID {
+		return errRevisionNotFound
+	}
+
+	// Keep the state being replaced so the restore itself can be undone
+	replaced := captureRevision(target, id, req)
+
+	before := snapshot(target.collection, id)
+
+	// Links to books or authors deleted since the revision are not brought back
+	links, linkedIDs, err := restorableLinks(target, revision.Links, req.LibraryID)
+	if err != nil {
+		return err
+	}
+
+	doc := bson.M{}
+	for key, value := range revision.Document {
+		doc[key] = value
+	}
+	doc["_id"] = id
+	doc["libraryId"] = req.LibraryID
+	stampUpdated(doc, req.Actor)
+
+	// The book's authors and the author's books follow the restored links
+	if stored, ok := doc["authors"]; ok && target.entity == auditEntityBook {
+		doc["authors"] = keepIDs(stored, linkedIDs)
+	} else if target.entity == auditEntityAuthor {
+		doc["books"] = linkedIDs
+	}
+
+	// Tags belong to their users rather than to the catalog, a restore keeps the current ones
+	delete(doc, "tags")
+	if tags, ok := before["tags"]; ok {
+		doc["tags"] = tags
+	}
+
+	opts := options.Replace().SetUpsert(true)
+	if _, err := target.collection.ReplaceOne(context.Background(), bson.M{"_id": id}, doc, opts); err != nil {
+		return err
+	}
+	if err := storeRevision(context.Background(), replaced); err != nil {
+		return err
+	}
+	recordAudit(req, target.entity, auditActionRestore, id, before, snapshot(target.collection, id))
+
+	// Replace the current links with the ones from the revision
+	linkFilter := scoped(req.LibraryID, bson.M{target.linkField: id})
+	linksBefore := snapshots(bookAuthor, linkFilter)
+	if _, err := bookAuthor.DeleteMany(context.Background(), linkFilter); err != nil {
+		return err
+	}
+	for _, link := range linksBefore {
+		recordAudit(req, auditEntityBookAuthor, auditActionDelete, link["_id"].(primitive.ObjectID), link, nil)
+	}
+
+	for _, link := range links {
+		if _, err := bookAuthor.InsertOne(context.Background(), link); err != nil {
+			return err
+		}
+		linkID := link["_id"].(primitive.ObjectID)
+		recordAudit(req, auditEntityBookAuthor, auditActionRestore, linkID, nil, snapshot(bookAuthor, linkID))
+	}
+
+	// Authors keep their own list of books and books their list of authors,
+	// bring the other side in line with the restored links
+	otherCollection, listField := collection, "books"
+	if target.entity == auditEntityAuthor {
+		otherCollection, listField = bookCollection, "authors"
+	}
+	pull := bson.M{"$pull": bson.M{listField: id}}
+	if _, err := otherCollection.UpdateMany(context.Background(), scoped(req.LibraryID, bson.M{listField: id}), pull); err != nil {
+		return err
+	}
+	if len(linkedIDs) > 0 {
+		add := bson.M{"$addToSet": bson.M{listField: id}}
+		if _, err := otherCollection.UpdateMany(context.Background(), scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": linkedIDs}}), add); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// the links of a revision whose other side still exists in the library, with the IDs of those
+func restorableLinks(target revisionTarget, links []bson.M, libraryID primitive.ObjectID) ([]bson.M, []primitive.ObjectID, error) {
+	otherCollection := collection
+	if target.entity == auditEntityAuthor {
+		otherCollection = bookCollection
+	}
+
+	var otherIDs []primitive.ObjectID
+	for _, link := range links {
+		if otherID, ok := link[target.otherField].(primitive.ObjectID); ok {
+			otherIDs = append(otherIDs, otherID)
+		}
+	}
+	existing := map[primitive.ObjectID]bool{}
+	if len(otherIDs) > 0 {
+		found, err := otherCollection.Distinct(context.Background(), "_id", scoped(libraryID, bson.M{"_id": bson.M{"$in": otherIDs}}))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, value := range found {
+			if otherID, ok := value.(primitive.ObjectID); ok {
+				existing[otherID] = true
+			}
+		}
+	}
+
+	kept := []bson.M{}
+	linkedIDs := []primitive.ObjectID{}
+	for _, link := range links {
+		otherID, _ := link[target.otherField].(primitive.ObjectID)
+		if !existing[otherID] {
+			continue
+		}
+		kept = append(kept, link)
+		if !containsID(linkedIDs, otherID) {
+			linkedIDs = append(linkedIDs, otherID)
+		}
+	}
+	return kept, linkedIDs, nil
+}
+
+// the IDs of a stored list that are also in keep, in the stored order
+func keepIDs(stored interface{}, keep []primitive.ObjectID) []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	list, _ := stored.(primitive.A)
+	for _, value := range list {
+		if id, ok := value.(primitive.ObjectID); ok && containsID(keep, id) && !containsID(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func GetBookRevisions(c *gin.Context) {
+	getRevisionsHandler(c, bookRevisionTarget(), c.Param("bookId"))
+}
+
+func GetBookRevisionDiff(c *gin.Context) {
+	diffRevisionsHandler(c, bookRevisionTarget(), c.Param("bookId"))
+}
+
+func RestoreBookRevision(c *gin.Context) {
+	restoreRevisionHandler(c, bookRevisionTarget(), c.Param("bookId"))
+}
+
+func GetAuthorRevisions(c *gin.Context) {
+	getRevisionsHandler(c, authorRevisionTarget(), c.Param("authorId"))
+}
+
+func GetAuthorRevisionDiff(c *gin.Context) {
+	diffRevisionsHandler(c, authorRevisionTarget(), c.Param("authorId"))
+}
+
+func RestoreAuthorRevision(c *gin.Context) {
+	restoreRevisionHandler(c, authorRevisionTarget(), c.Param("authorId"))
+}
+
+func getRevisionsHandler(c *gin.Context, target revisionTarget, entityId string) {
+	id, err := primitive.ObjectIDFromHex(entityId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	revisions, err := getRevisions(target.entity, id, requestFrom(c).LibraryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading revisions"})
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+func diffRevisionsHandler(c *gin.Context, target revisionTarget, entityId string) {
+	id, err := primitive.ObjectIDFromHex(entityId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from revision"})
+		return
+	}
+
+	to := 0
+	if value := c.Query("to"); value != "" {
+		to, err = strconv.Atoi(value)
+		if err != nil || to < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to revision"})
+			return
+		}
+	}
+
+	diff, err := diffRevisions(target, id, from, to, requestFrom(c).LibraryID)
+	if err == errRevisionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading revisions"})
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+func restoreRevisionHandler(c *gin.Context, target revisionTarget, entityId string) {
+	id, err := primitive.ObjectIDFromHex(entityId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || rev < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+		return
+	}
+
+	err = restoreRevision(target, id, rev, requestFrom(c))
+	if err == errRevisionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error restoring revision"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Restored", "revision": rev})
+}
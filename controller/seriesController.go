@@ -0,0 +1,377 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var seriesCollection *mongo.Collection
+
+var errSeriesNotFound = errors.New("series not found")
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		seriesCollection = db.Collection("series")
+
+		ensureIndexes(seriesCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "name", Value: 1}},
+		})
+
+		fmt.Println("Series collection istance is ready")
+		return nil
+	})
+}
+
+func validSeries(series *model.Series) error {
+	series.Name = strings.TrimSpace(series.Name)
+	if series.Name == "" {
+		return errors.New("name is required")
+	}
+	if series.TotalVolumes < 0 {
+		return errors.New("totalVolumes must not be negative")
+	}
+	return nil
+}
+
+// errSeriesNotFound when the book is in a series the library does not have
+func checkBookSeries(libraryID primitive.ObjectID, book model.Book) error {
+	if book.SeriesID.IsZero() {
+		return nil
+	}
+
+	err := seriesCollection.FindOne(context.Background(), scoped(libraryID, bson.M{"_id": book.SeriesID})).Err()
+	if err == mongo.ErrNoDocuments {
+		return errSeriesNotFound
+	}
+	return err
+}
+
+// replace the book's reading state with the reader's, zero when they have none
+func applyReadingState(book *model.SeriesBook, state model.ReadingState) {
+	book.Read = state.Read
+	book.ReadingStatus = state.ReadingStatus
+	book.DateRead = state.DateRead
+}
+
+// a book counts as read once it is marked read or has the read status
+func isBookRead(book model.SeriesBook) bool {
+	return book.Read || book.ReadingStatus == model.ReadingStatusRead
+}
+
+func insertSeries(series *model.Series, req requestInfo) error {
+	if err := checkQuota(req.LibraryID, auditEntitySeries, 1); err != nil {
+		return err
+	}
+	stampCreated(&series.Timestamps, req.Actor)
+	series.LibraryID = req.LibraryID
+
+	inserted, err := seriesCollection.InsertOne(context.Background(), series)
+	if err != nil {
+		return err
+	}
+
+	series.ID = inserted.InsertedID.(primitive.ObjectID)
+	recordAudit(req, auditEntitySeries, auditActionCreate, series.ID, nil, snapshot(seriesCollection, series.ID))
+	return nil
+}
+
+func updateSeries(id primitive.ObjectID, series model.Series, req requestInfo) error {
+	before := snapshot(seriesCollection, id)
+	update := bson.M{"$set": stampUpdated(bson.M{
+		"name":         series.Name,
+		"description":  series.Description,
+		"totalVolumes": series.TotalVolumes,
+	}, req.Actor)}
+
+	result, err := seriesCollection.UpdateOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errSeriesNotFound
+	}
+
+	recordAudit(req, auditEntitySeries, auditActionUpdate, id, before, snapshot(seriesCollection, id))
+	return nil
+}
+
+// delete a series, its books stay in the library without series and volume
+func deleteSeries(id primitive.ObjectID, req requestInfo) error {
+	before := snapshot(seriesCollection, id)
+	result, err := seriesCollection.DeleteOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errSeriesNotFound
+	}
+	recordAudit(req, auditEntitySeries, auditActionDelete, id, before, nil)
+
+	bookFilter := scoped(req.LibraryID, bson.M{"seriesId": id})
+	booksBefore := snapshots(bookCollection, bookFilter)
+	update := bson.M{"$unset": bson.M{"seriesId": "", "volume": ""}, "$set": stampUpdated(bson.M{}, req.Actor)}
+	if _, err := bookCollection.UpdateMany(context.Background(), bookFilter, update); err != nil {
+		return err
+	}
+	for _, book := range booksBefore {
+		bookID := book["_id"].(primitive.ObjectID)
+		recordAudit(req, auditEntityBook, auditActionUpdate, bookID, book, snapshot(bookCollection, bookID))
+	}
+	return nil
+}
+
+// a series with its books in reading order: by volume, books without a volume
+// last by publication year, then title. The read state is the reader's own.
+func getSeriesWithBooks(id primitive.ObjectID, libraryID primitive.ObjectID, actor string) (model.SeriesWithBooks, error) {
+	var series model.SeriesWithBooks
+
+	err := seriesCollection.FindOne(context.Background(), scoped(libraryID, bson.M{"_id": id})).Decode(&series.Series)
+	if err == mongo.ErrNoDocuments {
+		return series, errSeriesNotFound
+	} else if err != nil {
+		return series, err
+	}
+
+	series.Books = []model.SeriesBook{}
+	cursor, err := bookCollection.Find(context.Background(), scoped(libraryID, bson.M{"seriesId": id}))
+	if err != nil {
+		return series, err
+	}
+	if err := cursor.All(context.Background(), &series.Books); err != nil {
+		return series, err
+	}
+
+	sort.SliceStable(series.Books, func(i, j int) bool {
+		a, b := series.Books[i], series.Books[j]
+		if (a.Volume == 0) != (b.Volume == 0) {
+			return b.Volume == 0
+		}
+		if a.Volume != b.Volume {
+			return a.Volume < b.Volume
+		}
+		if a.PublicationYear != b.PublicationYear {
+			return a.PublicationYear < b.PublicationYear
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	})
+
+	var bookIDs []primitive.ObjectID
+	for _, book := range series.Books {
+		bookIDs = append(bookIDs, book.ID)
+	}
+	states, err := readingStatesFor(libraryID, actor, bookIDs)
+	if err != nil {
+		return series, err
+	}
+	for i := range series.Books {
+		applyReadingState(&series.Books[i], states[series.Books[i].ID])
+		if isBookRead(series.Books[i]) {
+			series.ReadCount++
+		}
+	}
+	return series, nil
+}
+
+// the first book in reading order that is not read yet; books the reader gave up on
+// are passed over, nil when the series is finished
+func nextUnread(series model.SeriesWithBooks) *model.SeriesBook {
+	for i, book := range series.Books {
+		if !isBookRead(book) && book.ReadingStatus != model.ReadingStatusDidNotFinish {
+			return &series.Books[i]
+		}
+	}
+	return nil
+}
+
+func getAllSeries(libraryID primitive.ObjectID, match bson.M, sort bson.D) ([]model.Series, error) {
+	series := []model.Series{}
+
+	opts := options.Find()
+	if len(sort) > 0 {
+		opts.SetSort(sort)
+	} else {
+		opts.SetSort(bson.D{{Key: "name", Value: 1}})
+	}
+
+	cursor, err := seriesCollection.Find(context.Background(), scoped(libraryID, match), opts)
+	if err != nil {
+		return series, err
+	}
+	err = cursor.All(context.Background(), &series)
+	return series, err
+}
+
+func seriesIdParam(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("seriesId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return id, false
+	}
+	return id, true
+}
+
+// tags of a series response, any change to one of its books makes it stale
+func seriesTags(series model.SeriesWithBooks) []string {
+	tags := []string{entityTag(auditEntitySeries, series.ID)}
+	for _, book := range series.Books {
+		tags = append(tags, entityTag(auditEntityBook, book.ID))
+	}
+	return tags
+}
+
+func CreateSeries(c *gin.Context) {
+	var series model.Series
+	if err := c.ShouldBindJSON(&series); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validSeries(&series); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := insertSeries(&series, requestFrom(c)); err == errQuotaExceeded {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Series quota exceeded for this library"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating series"})
+		return
+	}
+	c.JSON(http.StatusCreated, series)
+}
+
+func GetAllSeries(c *gin.Context) {
+	match, sort, err := timestampListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if name := strings.TrimSpace(c.Query("name")); name != "" {
+		match["name"] = primitive.Regex{Pattern: regexp.QuoteMeta(name), Options: "i"}
+	}
+	if serveCached(c) {
+		return
+	}
+
+	libraryID := requestFrom(c).LibraryID
+	series, err := getAllSeries(libraryID, match, sort)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading series"})
+		return
+	}
+	respondCached(c, series, listTag(libraryID, auditEntitySeries))
+}
+
+func GetSeries(c *gin.Context) {
+	id, ok := seriesIdParam(c)
+	if !ok {
+		return
+	}
+	if serveCached(c) {
+		return
+	}
+
+	req := requestFrom(c)
+	series, err := getSeriesWithBooks(id, req.LibraryID, req.Actor)
+	if err == errSeriesNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading series"})
+		return
+	}
+	respondCached(c, series, seriesTags(series)...)
+}
+
+// the book the requesting user reads next in a series, with its authors
+func GetNextInSeries(c *gin.Context) {
+	id, ok := seriesIdParam(c)
+	if !ok {
+		return
+	}
+	if serveCached(c) {
+		return
+	}
+
+	libraryID := requestFrom(c).LibraryID
+	series, err := getSeriesWithBooks(id, libraryID, requestFrom(c).Actor)
+	if err == errSeriesNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading series"})
+		return
+	}
+
+	next := nextUnread(series)
+	if next == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No unread book left in this series"})
+		return
+	}
+	book, err := getBookWithAuthor(next.ID.Hex(), libraryID, requestFrom(c).Actor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading book"})
+		return
+	}
+
+	tags := seriesTags(series)
+	for _, contributor := range book.Contributors {
+		tags = append(tags, entityTag(auditEntityAuthor, contributor.ID))
+	}
+	respondCached(c, book, tags...)
+}
+
+func UpdateSeries(c *gin.Context) {
+	id, ok := seriesIdParam(c)
+	if !ok {
+		return
+	}
+	var series model.Series
+	if err := c.ShouldBindJSON(&series); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validSeries(&series); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := updateSeries(id, series, requestFrom(c))
+	if err == errSeriesNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating series"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Updated"})
+}
+
+func DeleteSeries(c *gin.Context) {
+	id, ok := seriesIdParam(c)
+	if !ok {
+		return
+	}
+
+	err := deleteSeries(id, requestFrom(c))
+	if err == errSeriesNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting series"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Deleted"})
+}
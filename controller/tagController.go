@@ -0,0 +1,623 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/auth"
+	"example/books-api/middleware"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	tagModeAnd = "and"
+	tagModeOr  = "or"
+
+	maxTagLength = 64
+)
+
+var personalTagCollection *mongo.Collection
+
+var errTagNotFound = errors.New("tag not found")
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		personalTagCollection = db.Collection("personalTags")
+
+		// A user puts a tag on a book once, filters and counts go by owner and name
+		ensureIndexes(personalTagCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "libraryId", Value: 1}, {Key: "owner", Value: 1}, {Key: "name", Value: 1}, {Key: "book", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		// and sees their tags of a book next to the shared ones
+		ensureIndexes(personalTagCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "owner", Value: 1}, {Key: "book", Value: 1}},
+		})
+
+		fmt.Println("Personal tag collection istance is ready")
+		return nil
+	})
+}
+
+// "Book Club  2026" -> "book club 2026"
+func normalizeTag(name string) (string, error) {
+	tag := strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if tag == "" {
+		return "", errors.New("tag must not be empty")
+	}
+	if len([]rune(tag)) > maxTagLength {
+		return "", fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+	}
+	return tag, nil
+}
+
+func normalizeTags(names []string) ([]string, error) {
+	var tags []string
+	for _, name := range names {
+		tag, err := normalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("at least one tag is required")
+	}
+	return tags, nil
+}
+
+// owner of the tags a request writes, shared tags have none
+func tagOwner(req requestInfo, shared bool) string {
+	if shared {
+		return ""
+	}
+	return req.Actor
+}
+
+// personal tags only need read access to the book, shared tags change it for everyone
+func canWriteTags(c *gin.Context, shared bool) bool {
+	if shared && !middleware.ClaimsFrom(c).Can(auth.PermBookWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + auth.PermBookWrite})
+		return false
+	}
+	return true
+}
+
+// $match conditions for ?tags=a,b&tagMode=and|or on the tags the actor sees: the shared ones
+// on the book and their own; with "and", the default, a book needs every tag, with "or" any of them
+func tagConditions(c *gin.Context, req requestInfo) ([]bson.M, error) {
+	var names []string
+	for _, name := range strings.Split(c.Query("tags"), ",") {
+		if strings.TrimSpace(name) != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := strings.ToLower(c.DefaultQuery("tagMode", tagModeAnd))
+	switch mode {
+	case tagModeOr:
+		condition, err := tagCondition(req, tags)
+		if err != nil {
+			return nil, err
+		}
+		return []bson.M{condition}, nil
+	case tagModeAnd:
+		var conditions []bson.M
+		for _, tag := range tags {
+			condition, err := tagCondition(req, []string{tag})
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+		return conditions, nil
+	default:
+		return nil, fmt.Errorf("invalid tagMode %q: expected %q or %q", mode, tagModeAnd, tagModeOr)
+	}
+}
+
+// books carrying any of the tags, shared or the actor's own
+func tagCondition(req requestInfo, names []string) (bson.M, error) {
+	filter := scoped(req.LibraryID, bson.M{"owner": req.Actor, "name": bson.M{"$in": names}})
+	bookIDs, err := personalTagCollection.Distinct(context.Background(), "book", filter)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$or": []bson.M{
+		{"tags": bson.M{"$elemMatch": bson.M{"name": bson.M{"$in": names}, "owner": bson.M{"$exists": false}}}},
+		{"_id": bson.M{"$in": append([]interface{}{}, bookIDs...)}},
+	}}, nil
+}
+
+// the actor's personal tags of the given books by book ID, by name
+func personalTagsFor(libraryID primitive.ObjectID, actor string, bookIDs []primitive.ObjectID) (map[primitive.ObjectID][]model.BookTag, error) {
+	tags := map[primitive.ObjectID][]model.BookTag{}
+	if len(bookIDs) == 0 {
+		return tags, nil
+	}
+
+	filter := scoped(libraryID, bson.M{"owner": actor, "book": bson.M{"$in": bookIDs}})
+	cursor, err := personalTagCollection.Find(context.Background(), filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return tags, err
+	}
+	var found []model.PersonalTag
+	if err := cursor.All(context.Background(), &found); err != nil {
+		return tags, err
+	}
+	for _, tag := range found {
+		tags[tag.Book] = append(tags[tag.Book], model.BookTag{Name: tag.Name, Owner: tag.Owner})
+	}
+	return tags, nil
+}
+
+// $lookup and $addFields adding the reader's personal tags after the shared tags
+// of each book of an aggregation; run them after the $project
+func personalTagStages(libraryID primitive.ObjectID, actor string) []bson.M {
+	return []bson.M{
+		{"$lookup": bson.M{
+			"from": "personalTags",
+			"let":  bson.M{"book": "$_id"},
+			"pipeline": []bson.M{
+				{"$match": bson.M{
+					"libraryId": libraryID,
+					"owner":     actor,
+					"$expr":     bson.M{"$eq": []interface{}{"$book", "$$book"}},
+				}},
+				{"$sort": bson.M{"name": 1}},
+				{"$project": bson.M{"_id": 0, "name": 1, "owner": 1}},
+			},
+			"as": "personalTags",
+		}},
+		{"$addFields": bson.M{"tags": bson.M{"$concatArrays": []interface{}{
+			bson.M{"$ifNull": []interface{}{"$tags", []interface{}{}}},
+			"$personalTags",
+		}}}},
+		{"$project": bson.M{"personalTags": 0}},
+	}
+}
+
+func findTaggedBook(id primitive.ObjectID, libraryID primitive.ObjectID) (model.Book, error) {
+	var book model.Book
+	err := bookCollection.FindOne(context.Background(), scoped(libraryID, bson.M{"_id": id})).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		return book, errBookNotFound
+	}
+	return book, err
+}
+
+// the shared tags of a book followed by the actor's own
+func bookTagsFor(id primitive.ObjectID, req requestInfo) ([]model.BookTag, error) {
+	book, err := findTaggedBook(id, req.LibraryID)
+	if err != nil {
+		return nil, err
+	}
+	personal, err := personalTagsFor(req.LibraryID, req.Actor, []primitive.ObjectID{id})
+	if err != nil {
+		return nil, err
+	}
+	return append(book.Tags, personal[id]...), nil
+}
+
+// add tags to a book, returns the tags the actor sees afterwards
+func addBookTags(id primitive.ObjectID, names []string, owner string, req requestInfo) ([]model.BookTag, error) {
+	if _, err := findTaggedBook(id, req.LibraryID); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if owner == "" {
+		err = addSharedTags(id, names, req)
+	} else {
+		err = addPersonalTags(id, names, owner, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bookTagsFor(id, req)
+}
+
+// shared tags are part of the catalog, adding them is a book update;
+// $addToSet keeps tags other users add at the same time
+func addSharedTags(id primitive.ObjectID, names []string, req requestInfo) error {
+	tags := make([]model.BookTag, len(names))
+	for i, name := range names {
+		tags[i] = model.BookTag{Name: name}
+	}
+
+	// Books that already have every tag are left as they are
+	filter := scoped(req.LibraryID, bson.M{"_id": id, "tags": bson.M{"$not": bson.M{"$all": tags}}})
+	update := bson.M{"$addToSet": bson.M{"tags": bson.M{"$each": tags}}, "$set": stampUpdated(bson.M{}, req.Actor)}
+
+	before := snapshot(bookCollection, id)
+	result, err := bookCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		recordAudit(req, auditEntityBook, auditActionUpdate, id, before, snapshot(bookCollection, id))
+	}
+	return nil
+}
+
+// personal tags are records of their own, the book is not touched
+func addPersonalTags(id primitive.ObjectID, names []string, owner string, req requestInfo) error {
+	for _, name := range names {
+		tag := model.PersonalTag{Owner: owner, Book: id, Name: name, LibraryID: req.LibraryID}
+		stampCreated(&tag.Timestamps, req.Actor)
+
+		filter := scoped(req.LibraryID, bson.M{"owner": owner, "book": id, "name": name})
+		opts := options.Update().SetUpsert(true)
+		result, err := personalTagCollection.UpdateOne(context.Background(), filter, bson.M{"$setOnInsert": tag}, opts)
+		if mongo.IsDuplicateKeyError(err) {
+			// added by a concurrent request
+			continue
+		} else if err != nil {
+			return err
+		}
+		if tagID, ok := result.UpsertedID.(primitive.ObjectID); ok {
+			recordAudit(req, auditEntityPersonalTag, auditActionCreate, tagID, nil, snapshot(personalTagCollection, tagID))
+		}
+	}
+	return nil
+}
+
+// remove a tag from a book, errTagNotFound when the book does not have it
+func removeBookTag(id primitive.ObjectID, name string, owner string, req requestInfo) ([]model.BookTag, error) {
+	if _, err := findTaggedBook(id, req.LibraryID); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if owner == "" {
+		err = removeSharedTag(id, name, req)
+	} else {
+		err = removePersonalTag(id, name, owner, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bookTagsFor(id, req)
+}
+
+func removeSharedTag(id primitive.ObjectID, name string, req requestInfo) error {
+	shared := bson.M{"name": name, "owner": bson.M{"$exists": false}}
+	filter := scoped(req.LibraryID, bson.M{"_id": id, "tags": bson.M{"$elemMatch": shared}})
+	update := bson.M{"$pull": bson.M{"tags": shared}, "$set": stampUpdated(bson.M{}, req.Actor)}
+
+	before := snapshot(bookCollection, id)
+	result, err := bookCollection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errTagNotFound
+	}
+	recordAudit(req, auditEntityBook, auditActionUpdate, id, before, snapshot(bookCollection, id))
+	return nil
+}
+
+func removePersonalTag(id primitive.ObjectID, name string, owner string, req requestInfo) error {
+	var tag bson.M
+	filter := scoped(req.LibraryID, bson.M{"owner": owner, "book": id, "name": name})
+	err := personalTagCollection.FindOneAndDelete(context.Background(), filter).Decode(&tag)
+	if err == mongo.ErrNoDocuments {
+		return errTagNotFound
+	} else if err != nil {
+		return err
+	}
+	recordAudit(req, auditEntityPersonalTag, auditActionDelete, tag["_id"].(primitive.ObjectID), tag, nil)
+	return nil
+}
+
+// the tags the actor sees with the number of books carrying each, most used first
+func getTagCounts(req requestInfo, name string) ([]model.TagCount, error) {
+	nameMatch := bson.M{}
+	if name != "" {
+		nameMatch["name"] = primitive.Regex{Pattern: regexp.QuoteMeta(name), Options: "i"}
+	}
+	group := bson.M{"$group": bson.M{"_id": "$name", "books": bson.M{"$sum": 1}}}
+
+	sources := []struct {
+		collection *mongo.Collection
+		pipeline   []bson.M
+		shared     bool
+	}{
+		{bookCollection, []bson.M{
+			{"$match": scoped(req.LibraryID, bson.M{"tags.0": bson.M{"$exists": true}})},
+			{"$unwind": "$tags"},
+			{"$replaceWith": "$tags"},
+			{"$match": nameMatch},
+			group,
+		}, true},
+		{personalTagCollection, []bson.M{
+			{"$match": scoped(req.LibraryID, bson.M{"owner": req.Actor})},
+			{"$match": nameMatch},
+			group,
+		}, false},
+	}
+
+	counts := []model.TagCount{}
+	for _, source := range sources {
+		cursor, err := source.collection.Aggregate(context.Background(), source.pipeline)
+		if err != nil {
+			return nil, err
+		}
+		var groups []struct {
+			Name  string `bson:"_id"`
+			Books int    `bson:"books"`
+		}
+		if err := cursor.All(context.Background(), &groups); err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			counts = append(counts, model.TagCount{Name: group.Name, Shared: source.shared, Books: group.Books})
+		}
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.Books != b.Books {
+			return a.Books > b.Books
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Shared
+	})
+	return counts, nil
+}
+
+// replace the given tags of one owner with target on every book, which renames a tag
+// or merges several into one; books that already have target keep it once
+func mergeTags(sources []string, target string, owner string, req requestInfo) (int, error) {
+	if owner == "" {
+		return mergeSharedTags(sources, target, req)
+	}
+	return mergePersonalTags(sources, target, owner, req)
+}
+
+// target is added before the sources are pulled so a book never misses both,
+// and neither write replaces tags other users change meanwhile
+func mergeSharedTags(sources []string, target string, req requestInfo) (int, error) {
+	elem := bson.M{"name": bson.M{"$in": sources}, "owner": bson.M{"$exists": false}}
+	books := snapshots(bookCollection, scoped(req.LibraryID, bson.M{"tags": bson.M{"$elemMatch": elem}}))
+	if len(books) == 0 {
+		return 0, errTagNotFound
+	}
+
+	var bookIDs []primitive.ObjectID
+	for _, book := range books {
+		bookIDs = append(bookIDs, book["_id"].(primitive.ObjectID))
+	}
+	filter := scoped(req.LibraryID, bson.M{"_id": bson.M{"$in": bookIDs}})
+
+	add := bson.M{"$addToSet": bson.M{"tags": model.BookTag{Name: target}}, "$set": stampUpdated(bson.M{}, req.Actor)}
+	if _, err := bookCollection.UpdateMany(context.Background(), filter, add); err != nil {
+		return 0, err
+	}
+	var replaced []string
+	for _, source := range sources {
+		if source != target {
+			replaced = append(replaced, source)
+		}
+	}
+	if len(replaced) > 0 {
+		pull := bson.M{"$pull": bson.M{"tags": bson.M{"name": bson.M{"$in": replaced}, "owner": bson.M{"$exists": false}}}}
+		if _, err := bookCollection.UpdateMany(context.Background(), filter, pull); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, book := range books {
+		bookID := book["_id"].(primitive.ObjectID)
+		recordAudit(req, auditEntityBook, auditActionUpdate, bookID, book, snapshot(bookCollection, bookID))
+	}
+	return len(books), nil
+}
+
+func mergePersonalTags(sources []string, target string, owner string, req requestInfo) (int, error) {
+	var tags []model.PersonalTag
+	cursor, err := personalTagCollection.Find(context.Background(), scoped(req.LibraryID, bson.M{"owner": owner, "name": bson.M{"$in": sources}}))
+	if err != nil {
+		return 0, err
+	}
+	if err := cursor.All(context.Background(), &tags); err != nil {
+		return 0, err
+	}
+	if len(tags) == 0 {
+		return 0, errTagNotFound
+	}
+
+	var bookIDs []primitive.ObjectID
+	for _, tag := range tags {
+		if !containsID(bookIDs, tag.Book) {
+			bookIDs = append(bookIDs, tag.Book)
+			if err := addPersonalTags(tag.Book, []string{target}, owner, req); err != nil {
+				return 0, err
+			}
+		}
+	}
+	for _, tag := range tags {
+		if tag.Name == target {
+			continue
+		}
+		before := snapshot(personalTagCollection, tag.ID)
+		if _, err := personalTagCollection.DeleteOne(context.Background(), bson.M{"_id": tag.ID}); err != nil {
+			return 0, err
+		}
+		recordAudit(req, auditEntityPersonalTag, auditActionDelete, tag.ID, before, nil)
+	}
+	return len(bookIDs), nil
+}
+
+// answer a tag error, false when there was none
+func tagError(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return false
+	case errBookNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+	case errTagNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing tags"})
+	}
+	return true
+}
+
+// POST /book/:bookId/tags {"tags": ["book-club-2026"], "shared": true}
+func AddBookTags(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("bookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+	var body struct {
+		Tags   []string `json:"tags"`
+		Shared bool     `json:"shared"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	names, err := normalizeTags(body.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !canWriteTags(c, body.Shared) {
+		return
+	}
+
+	req := requestFrom(c)
+	tags, err := addBookTags(id, names, tagOwner(req, body.Shared), req)
+	if tagError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// DELETE /book/:bookId/tags/:tag?shared=true
+func RemoveBookTag(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("bookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+	name, err := normalizeTag(c.Param("tag"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	shared := c.Query("shared") == "true"
+	if !canWriteTags(c, shared) {
+		return
+	}
+
+	req := requestFrom(c)
+	tags, err := removeBookTag(id, name, tagOwner(req, shared), req)
+	if tagError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// GET /tag/all?name=club
+func GetAllTags(c *gin.Context) {
+	if serveCached(c) {
+		return
+	}
+
+	req := requestFrom(c)
+	counts, err := getTagCounts(req, strings.TrimSpace(c.Query("name")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading tags"})
+		return
+	}
+	respondCached(c, counts, listTag(req.LibraryID, auditEntityBook))
+}
+
+// PUT /tag/:tag?shared=true {"name": "book-club-2027"}
+func RenameTag(c *gin.Context) {
+	source, err := normalizeTag(c.Param("tag"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	target, err := normalizeTag(body.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	shared := c.Query("shared") == "true"
+	if !canWriteTags(c, shared) {
+		return
+	}
+
+	req := requestFrom(c)
+	books, err := mergeTags([]string{source}, target, tagOwner(req, shared), req)
+	if tagError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Updated", "books": books})
+}
+
+// POST /tag/merge {"tags": ["bookclub", "book club"], "into": "book-club", "shared": true}
+func MergeTags(c *gin.Context) {
+	var body struct {
+		Tags   []string `json:"tags"`
+		Into   string   `json:"into"`
+		Shared bool     `json:"shared"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sources, err := normalizeTags(body.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	target, err := normalizeTag(body.Into)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !canWriteTags(c, body.Shared) {
+		return
+	}
+
+	req := requestFrom(c)
+	books, err := mergeTags(sources, target, tagOwner(req, body.Shared), req)
+	if tagError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Merged", "books": books})
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"example/books-api/model"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// stamp a new document
+func stampCreated(t *model.Timestamps, actor string) {
+	now := time.Now().UTC()
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	t.CreatedBy = actor
+	t.UpdatedBy = actor
+}
+
+// fields to $set on every update
+func stampUpdated(set bson.M, actor string) bson.M {
+	set["updatedAt"] = time.Now().UTC()
+	set["updatedBy"] = actor
+	return set
+}
+
+var timestampSortKeys = map[string]bool{
+	"createdAt": true,
+	"updatedAt": true,
+	"createdBy": true,
+	"updatedBy": true,
+}
+
+// build $match and $sort stages from the list query string, e.g.
+// ?sort=-createdAt&createdBy=ana&updatedAfter=2024-01-02T15:04:05Z
+func timestampListQuery(c *gin.Context) (bson.M, bson.D, error) {
+	match := bson.M{}
+	for _, key := range []string{"createdBy", "updatedBy"} {
+		if value := c.Query(key); value != "" {
+			match[key] = value
+		}
+	}
+
+	for _, field := range []string{"createdAt", "updatedAt"} {
+		prefix := strings.TrimSuffix(field, "At")
+		rng := bson.M{}
+		for param, op := range map[string]string{prefix + "After": "$gte", prefix + "Before": "$lt"} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %s: expected RFC3339 time", param)
+			}
+			rng[op] = t
+		}
+		if len(rng) > 0 {
+			match[field] = rng
+		}
+	}
+
+	var sort bson.D
+	if value := c.Query("sort"); value != "" {
+		for _, key := range strings.Split(value, ",") {
+			order := 1
+			if strings.HasPrefix(key, "-") {
+				order = -1
+				key = key[1:]
+			}
+			if !timestampSortKeys[key] {
+				return nil, nil, fmt.Errorf("invalid sort key: %s", key)
+			}
+			sort = append(sort, bson.E{Key: key, Value: order})
+		}
+	}
+
+	return match, sort, nil
+}
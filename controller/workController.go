@@ -0,0 +1,621 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"example/books-api/model"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var workCollection *mongo.Collection
+
+var errWorkNotFound = errors.New("work not found")
+var errEditionNotFound = errors.New("book is not an edition of this work")
+
+func init() {
+	onSetup(func(db *mongo.Database) error {
+		workCollection = db.Collection("works")
+
+		ensureIndexes(workCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "title", Value: 1}},
+		}, mongo.IndexModel{
+			Keys: bson.D{{Key: "libraryId", Value: 1}, {Key: "contributors.author", Value: 1}},
+		})
+
+		fmt.Println("Work collection istance is ready")
+		return nil
+	})
+}
+
+// validate a work and order its contributors the way books do; nil contributors
+// leave them unchanged on update and take those of the first edition on create
+func validWork(work *model.Work) error {
+	work.Title = strings.TrimSpace(work.Title)
+	if work.Title == "" && len(work.Editions) == 0 {
+		return errors.New("title is required")
+	}
+	if work.FirstPublished < 0 {
+		return errors.New("firstPublished must not be negative")
+	}
+
+	authors := model.Book{Authors: work.Authors, Contributors: work.Contributors}
+	contributors, err := bookContributors(&authors)
+	if err != nil {
+		return err
+	}
+	work.Contributors, work.Authors = contributors, authors.Authors
+	return nil
+}
+
+func findWork(libraryID primitive.ObjectID, id primitive.ObjectID) (model.Work, error) {
+	var work model.Work
+	err := workCollection.FindOne(context.Background(), scoped(libraryID, bson.M{"_id": id})).Decode(&work)
+	if err == mongo.ErrNoDocuments {
+		return work, errWorkNotFound
+	}
+	return work, err
+}
+
+// the contributors of a work in the form books take them and their distinct authors, never nil
+func workContributors(work model.Work) ([]model.Contributor, []primitive.ObjectID) {
+	authors := model.Book{Contributors: append([]model.Contributor{}, work.Contributors...)}
+	contributors, _ := bookContributors(&authors)
+	if contributors == nil {
+		return []model.Contributor{}, []primitive.ObjectID{}
+	}
+	return contributors, authors.Authors
+}
+
+// the contributors of an edition of a work, errWorkNotFound when the library has no
+// such work; nil when the book is not an edition. Sets book.Contributors and book.Authors
+func checkBookWork(libraryID primitive.ObjectID, book *model.Book) ([]model.Contributor, error) {
+	if book.WorkID.IsZero() {
+		return nil, nil
+	}
+	work, err := findWork(libraryID, book.WorkID)
+	if err != nil {
+		return nil, err
+	}
+	contributors, authorIDs := workContributors(work)
+	book.Contributors, book.Authors = contributors, authorIDs
+	return contributors, nil
+}
+
+// the contributors a book is linked to, by role and position
+func linkedContributors(bookID primitive.ObjectID, libraryID primitive.ObjectID) ([]model.Contributor, error) {
+	var links []model.BookAuthor
+	opts := options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := bookAuthor.Find(context.Background(), scoped(libraryID, bson.M{"book": bookID}), opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.Background(), &links); err != nil {
+		return nil, err
+	}
+
+	contributors := []model.Contributor{}
+	for _, link := range links {
+		contributors = append(contributors, model.Contributor{Author: link.Author, Role: link.Role, Position: link.Position})
+	}
+	book := model.Book{Contributors: contributors}
+	return bookContributors(&book)
+}
+
+// make a book an edition of a work, its links are replaced with the work's contributors
+func attachEdition(bookID primitive.ObjectID, work model.Work, req requestInfo) error {
+	contributors, authorIDs := workContributors(work)
+
+	revision := captureRevision(bookRevisionTarget(), bookID, req)
+	before := snapshot(bookCollection, bookID)
+	update := bson.M{"$set": stampUpdated(bson.M{"workId": work.ID, "authors": authorIDs}, req.Actor)}
+	if _, err := bookCollection.UpdateOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": bookID}), update); err != nil {
+		return err
+	}
+	if err := storeRevision(context.Background(), revision); err != nil {
+		return err
+	}
+	recordAudit(req, auditEntityBook, auditActionUpdate, bookID, before, snapshot(bookCollection, bookID))
+
+	replaceBookLinks(bookID, contributors, req)
+	return nil
+}
+
+// check that every book exists in the library
+func editionsExist(bookIDs []primitive.ObjectID, libraryID primitive.ObjectID) (bool, error) {
+	count, err := bookCollection.CountDocuments(context.Background(), scoped(libraryID, bson.M{"_id": bson.M{"$in": bookIDs}}))
+	if err != nil {
+		return false, err
+	}
+	return count == int64(len(bookIDs)), nil
+}
+
+// create a work with its first editions; without a title or contributors it takes
+// those of the first edition, so a work can be started from an existing book
+func insertWork(work *model.Work, req requestInfo) error {
+	if err := checkQuota(req.LibraryID, auditEntityWork, 1); err != nil {
+		return err
+	}
+	if len(work.Editions) > 0 {
+		if exist, err := editionsExist(work.Editions, req.LibraryID); err != nil {
+			return err
+		} else if !exist {
+			return errBookNotFound
+		}
+		first, err := findTaggedBook(work.Editions[0], req.LibraryID)
+		if err != nil {
+			return err
+		}
+		if work.Title == "" {
+			work.Title, work.Subtitle = first.Title, first.Subtitle
+		}
+		if work.Contributors == nil {
+			if work.Contributors, err = linkedContributors(first.ID, req.LibraryID); err != nil {
+				return err
+			}
+		}
+	}
+
+	stampCreated(&work.Timestamps, req.Actor)
+	work.LibraryID = req.LibraryID
+
+	inserted, err := workCollection.InsertOne(context.Background(), work)
+	if err != nil {
+		return err
+	}
+	work.ID = inserted.InsertedID.(primitive.ObjectID)
+	recordAudit(req, auditEntityWork, auditActionCreate, work.ID, nil, snapshot(workCollection, work.ID))
+
+	for _, bookID := range work.Editions {
+		if err := attachEdition(bookID, *work, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update a work; new contributors are passed on to all of its editions
+func updateWork(id primitive.ObjectID, work model.Work, req requestInfo) error {
+	set := bson.M{
+		"title":          work.Title,
+		"subtitle":       work.Subtitle,
+		"description":    work.Description,
+		"firstPublished": work.FirstPublished,
+	}
+	if work.Contributors != nil {
+		set["contributors"] = work.Contributors
+	}
+
+	before := snapshot(workCollection, id)
+	result, err := workCollection.UpdateOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}), bson.M{"$set": stampUpdated(set, req.Actor)})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errWorkNotFound
+	}
+	recordAudit(req, auditEntityWork, auditActionUpdate, id, before, snapshot(workCollection, id))
+
+	if work.Contributors == nil {
+		return nil
+	}
+	work.ID = id
+	editionIDs, err := bookCollection.Distinct(context.Background(), "_id", scoped(req.LibraryID, bson.M{"workId": id}))
+	if err != nil {
+		return err
+	}
+	for _, editionID := range editionIDs {
+		if err := attachEdition(editionID.(primitive.ObjectID), work, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// take editions out of a work, they keep their authors
+func detachEditions(filter bson.M, req requestInfo) error {
+	filter = scoped(req.LibraryID, filter)
+	booksBefore := snapshots(bookCollection, filter)
+	update := bson.M{"$unset": bson.M{"workId": ""}, "$set": stampUpdated(bson.M{}, req.Actor)}
+	if _, err := bookCollection.UpdateMany(context.Background(), filter, update); err != nil {
+		return err
+	}
+	for _, book := range booksBefore {
+		bookID := book["_id"].(primitive.ObjectID)
+		recordAudit(req, auditEntityBook, auditActionUpdate, bookID, book, snapshot(bookCollection, bookID))
+	}
+	return nil
+}
+
+// delete a work, its editions stay in the library as separate books
+func deleteWork(id primitive.ObjectID, req requestInfo) error {
+	before := snapshot(workCollection, id)
+	result, err := workCollection.DeleteOne(context.Background(), scoped(req.LibraryID, bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errWorkNotFound
+	}
+	recordAudit(req, auditEntityWork, auditActionDelete, id, before, nil)
+
+	return detachEditions(bson.M{"workId": id}, req)
+}
+
+func addEditions(id primitive.ObjectID, bookIDs []primitive.ObjectID, req requestInfo) error {
+	work, err := findWork(req.LibraryID, id)
+	if err != nil {
+		return err
+	}
+	if exist, err := editionsExist(bookIDs, req.LibraryID); err != nil {
+		return err
+	} else if !exist {
+		return errBookNotFound
+	}
+
+	for _, bookID := range bookIDs {
+		if err := attachEdition(bookID, work, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeEdition(id primitive.ObjectID, bookID primitive.ObjectID, req requestInfo) error {
+	if err := checkEdition(id, bookID, req.LibraryID); err != nil {
+		return err
+	}
+	return detachEditions(bson.M{"_id": bookID}, req)
+}
+
+// errEditionNotFound unless the book is an edition of the work
+func checkEdition(id primitive.ObjectID, bookID primitive.ObjectID, libraryID primitive.ObjectID) error {
+	count, err := bookCollection.CountDocuments(context.Background(), scoped(libraryID, bson.M{"_id": bookID, "workId": id}))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errEditionNotFound
+	}
+	return nil
+}
+
+// set the reader's own state of one edition of a work
+func setEditionReadingState(id primitive.ObjectID, bookID primitive.ObjectID, state model.ReadingState, req requestInfo) (model.ReadingState, error) {
+	if _, err := findWork(req.LibraryID, id); err != nil {
+		return state, err
+	}
+	if err := checkEdition(id, bookID, req.LibraryID); err != nil {
+		return state, err
+	}
+	return setReadingState(bookID, state, req)
+}
+
+// a work with the names of its contributors and its editions, oldest first;
+// the reading state of each edition is the reader's own
+func getWorkWithEditions(id primitive.ObjectID, libraryID primitive.ObjectID, actor string) (model.WorkWithEditions, error) {
+	var work model.WorkWithEditions
+
+	var err error
+	if work.Work, err = findWork(libraryID, id); err != nil {
+		return work, err
+	}
+
+	var authorIDs []primitive.ObjectID
+	for _, contributor := range work.Contributors {
+		authorIDs = append(authorIDs, contributor.Author)
+	}
+	names := map[primitive.ObjectID]string{}
+	if len(authorIDs) > 0 {
+		for _, author := range snapshots(readingListCollection, scoped(libraryID, bson.M{"_id": bson.M{"$in": authorIDs}})) {
+			names[author["_id"].(primitive.ObjectID)], _ = author["name"].(string)
+		}
+	}
+
+	// Contributors whose author was deleted are left out, as they are for books
+	grouped := model.BookWithAuthor{}
+	for _, contributor := range work.Contributors {
+		if name, ok := names[contributor.Author]; ok {
+			grouped.Contributors = append(grouped.Contributors, model.AuthorInfo{
+				ID: contributor.Author, Name: name, Role: contributor.Role, Position: contributor.Position,
+			})
+		}
+	}
+	groupContributors(&grouped)
+	work.Authors, work.Editors, work.Translators, work.Illustrators = grouped.Authors, grouped.Editors, grouped.Translators, grouped.Illustrators
+
+	work.Editions = []model.Edition{}
+	cursor, err := bookCollection.Find(context.Background(), scoped(libraryID, bson.M{"workId": id}))
+	if err != nil {
+		return work, err
+	}
+	if err := cursor.All(context.Background(), &work.Editions); err != nil {
+		return work, err
+	}
+
+	sort.SliceStable(work.Editions, func(i, j int) bool {
+		a, b := work.Editions[i], work.Editions[j]
+		// editions without a year go last
+		if (a.PublicationYear == 0) != (b.PublicationYear == 0) {
+			return b.PublicationYear == 0
+		}
+		if a.PublicationYear != b.PublicationYear {
+			return a.PublicationYear < b.PublicationYear
+		}
+		return a.Format < b.Format
+	})
+
+	var editionIDs []primitive.ObjectID
+	for _, edition := range work.Editions {
+		editionIDs = append(editionIDs, edition.ID)
+	}
+	states, err := readingStatesFor(libraryID, actor, editionIDs)
+	if err != nil {
+		return work, err
+	}
+	for i := range work.Editions {
+		edition := &work.Editions[i]
+		state := states[edition.ID]
+		edition.Read, edition.ReadingStatus, edition.DateRead, edition.Rating = state.Read, state.ReadingStatus, state.DateRead, state.Rating
+		if edition.Read || edition.ReadingStatus == model.ReadingStatusRead {
+			work.Read = true
+		}
+	}
+	return work, nil
+}
+
+func getAllWorks(libraryID primitive.ObjectID, match bson.M, sort bson.D) ([]model.Work, error) {
+	works := []model.Work{}
+
+	opts := options.Find()
+	if len(sort) > 0 {
+		opts.SetSort(sort)
+	} else {
+		opts.SetSort(bson.D{{Key: "title", Value: 1}})
+	}
+
+	cursor, err := workCollection.Find(context.Background(), scoped(libraryID, match), opts)
+	if err != nil {
+		return works, err
+	}
+	err = cursor.All(context.Background(), &works)
+	return works, err
+}
+
+func workIdParam(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("workId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return id, false
+	}
+	return id, true
+}
+
+// tags of a work response, its editions and authors change it too
+func workTags(work model.WorkWithEditions) []string {
+	tags := []string{entityTag(auditEntityWork, work.ID)}
+	for _, edition := range work.Editions {
+		tags = append(tags, entityTag(auditEntityBook, edition.ID))
+	}
+	for _, contributor := range work.Contributors {
+		tags = append(tags, entityTag(auditEntityAuthor, contributor.Author))
+	}
+	return tags
+}
+
+// answer a work write error, false when there was none
+func workWriteError(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return false
+	case errWorkNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+	case errEditionNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errBookNotFound:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Some editions do not exist"})
+	case errQuotaExceeded:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Work quota exceeded for this library"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing work"})
+	}
+	return true
+}
+
+// POST /work/add {"title": "Dune", "authors": ["<id>"], "editions": ["<book id>", "<book id>"]}
+func CreateWork(c *gin.Context) {
+	var work model.Work
+	if err := c.ShouldBindJSON(&work); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validWork(&work); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := requestFrom(c)
+	if exist, err := authorsExist(work.Authors, req.LibraryID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking author existence"})
+		return
+	} else if !exist {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Some authors do not exist"})
+		return
+	}
+
+	if workWriteError(c, insertWork(&work, req)) {
+		return
+	}
+	c.JSON(http.StatusCreated, work)
+}
+
+func GetAllWorks(c *gin.Context) {
+	match, sort, err := timestampListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if title := strings.TrimSpace(c.Query("title")); title != "" {
+		match["title"] = primitive.Regex{Pattern: regexp.QuoteMeta(title), Options: "i"}
+	}
+	if serveCached(c) {
+		return
+	}
+
+	libraryID := requestFrom(c).LibraryID
+	works, err := getAllWorks(libraryID, match, sort)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading works"})
+		return
+	}
+	respondCached(c, works, listTag(libraryID, auditEntityWork))
+}
+
+// GET /work/:workId with the authors and every edition with the reader's own reading state
+func GetWork(c *gin.Context) {
+	id, ok := workIdParam(c)
+	if !ok {
+		return
+	}
+	if serveCached(c) {
+		return
+	}
+
+	req := requestFrom(c)
+	work, err := getWorkWithEditions(id, req.LibraryID, req.Actor)
+	if err == errWorkNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading work"})
+		return
+	}
+	respondCached(c, work, workTags(work)...)
+}
+
+func UpdateWork(c *gin.Context) {
+	id, ok := workIdParam(c)
+	if !ok {
+		return
+	}
+	var work model.Work
+	if err := c.ShouldBindJSON(&work); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	work.Editions = nil
+	if err := validWork(&work); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := requestFrom(c)
+	if exist, err := authorsExist(work.Authors, req.LibraryID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking author existence"})
+		return
+	} else if !exist {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Some authors do not exist"})
+		return
+	}
+
+	if workWriteError(c, updateWork(id, work, req)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Updated"})
+}
+
+func DeleteWork(c *gin.Context) {
+	id, ok := workIdParam(c)
+	if !ok {
+		return
+	}
+
+	if workWriteError(c, deleteWork(id, requestFrom(c))) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Deleted"})
+}
+
+// POST /work/:workId/editions {"books": ["<book id>"]}
+func AddWorkEditions(c *gin.Context) {
+	id, ok := workIdParam(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Books []string `json:"books"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body.Books) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one book is required"})
+		return
+	}
+	var bookIDs []primitive.ObjectID
+	for _, value := range body.Books {
+		bookID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID " + value})
+			return
+		}
+		if !containsID(bookIDs, bookID) {
+			bookIDs = append(bookIDs, bookID)
+		}
+	}
+
+	if workWriteError(c, addEditions(id, bookIDs, requestFrom(c))) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Updated"})
+}
+
+func RemoveWorkEdition(c *gin.Context) {
+	id, ok := workIdParam(c)
+	if !ok {
+		return
+	}
+	bookID, err := primitive.ObjectIDFromHex(c.Param("bookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	if workWriteError(c, removeEdition(id, bookID, requestFrom(c))) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Updated"})
+}
+
+// PUT /work/:workId/editions/:bookId/reading-state sets the requesting user's state of the edition
+func SetEditionReadingState(c *gin.Context) {
+	id, ok := workIdParam(c)
+	if !ok {
+		return
+	}
+	bookID, err := primitive.ObjectIDFromHex(c.Param("bookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+	state, ok := bindReadingState(c)
+	if !ok {
+		return
+	}
+
+	saved, err := setEditionReadingState(id, bookID, state, requestFrom(c))
+	if workWriteError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
@@ -0,0 +1,112 @@
+// Package isbn validates ISBN-10 and ISBN-13 identifiers and converts between them.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalid  = errors.New("invalid ISBN")
+	ErrNoISBN10 = errors.New("ISBN-13 has no ISBN-10 form")
+)
+
+// Normalize strips an "ISBN" label, hyphens and spaces: "ISBN 978-0-441-01359-3" -> "9780441013593"
+func Normalize(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "ISBN-13")
+	s = strings.TrimPrefix(s, "ISBN-10")
+	s = strings.TrimPrefix(s, "ISBN")
+	s = strings.TrimPrefix(strings.TrimSpace(s), ":")
+
+	var b strings.Builder
+	for _, r := range s {
+		if r != '-' && r != ' ' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// check digit of the first nine digits of an ISBN-10, 'X' stands for 10
+func checkDigit10(s string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(s[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// check digit of the first twelve digits of an ISBN-13
+func checkDigit13(s string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(s[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// Valid10 reports whether s is a normalized ISBN-10 with a correct check digit
+func Valid10(s string) bool {
+	return len(s) == 10 && digits(s[:9]) && checkDigit10(s) == s[9]
+}
+
+// Valid13 reports whether s is a normalized ISBN-13 with a correct check digit
+func Valid13(s string) bool {
+	return len(s) == 13 && digits(s) && (strings.HasPrefix(s, "978") || strings.HasPrefix(s, "979")) && checkDigit13(s) == s[12]
+}
+
+// To13 converts a valid ISBN-10 to its 978-prefixed ISBN-13
+func To13(isbn10 string) (string, error) {
+	isbn10 = Normalize(isbn10)
+	if !Valid10(isbn10) {
+		return "", ErrInvalid
+	}
+	isbn13 := "978" + isbn10[:9]
+	return isbn13 + string(checkDigit13(isbn13)), nil
+}
+
+// To10 converts a 978-prefixed ISBN-13 to ISBN-10, 979 ISBNs have no ISBN-10
+func To10(isbn13 string) (string, error) {
+	isbn13 = Normalize(isbn13)
+	if !Valid13(isbn13) {
+		return "", ErrInvalid
+	}
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", ErrNoISBN10
+	}
+	isbn10 := isbn13[3:12]
+	return isbn10 + string(checkDigit10(isbn10)), nil
+}
+
+// Parse accepts either form, hyphenated or not, and returns both;
+// isbn10 is empty for ISBN-13s in the 979 range
+func Parse(s string) (isbn10 string, isbn13 string, err error) {
+	s = Normalize(s)
+	switch {
+	case Valid10(s):
+		isbn13, _ = To13(s)
+		return s, isbn13, nil
+	case Valid13(s):
+		isbn10, _ = To10(s)
+		return isbn10, s, nil
+	}
+	return "", "", ErrInvalid
+}
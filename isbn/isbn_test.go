@@ -0,0 +1,97 @@
+package isbn
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "9780441013593", want: "9780441013593"},
+		{value: "978-0-441-01359-3", want: "9780441013593"},
+		{value: " ISBN 978 0 441 01359 3 ", want: "9780441013593"},
+		{value: "ISBN-13: 978-0-441-01359-3", want: "9780441013593"},
+		{value: "ISBN-10: 0-8044-2957-x", want: "080442957X"},
+		{value: "isbn:0441013597", want: "0441013597"},
+		{value: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.value); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		value   string
+		valid10 bool
+		valid13 bool
+	}{
+		{value: "0441013597", valid10: true},
+		{value: "080442957X", valid10: true},
+		{value: "0441013598"},
+		{value: "080442957x"},
+		{value: "X441013597"},
+		{value: "044101359"},
+		{value: "9780441013593", valid13: true},
+		{value: "9791032305690", valid13: true},
+		{value: "9780441013594"},
+		{value: "9770441013596"},
+		{value: "978044101359X"},
+		{value: "978-0441013593"},
+	}
+	for _, tt := range tests {
+		if got := Valid10(tt.value); got != tt.valid10 {
+			t.Errorf("Valid10(%q) = %v, want %v", tt.value, got, tt.valid10)
+		}
+		if got := Valid13(tt.value); got != tt.valid13 {
+			t.Errorf("Valid13(%q) = %v, want %v", tt.value, got, tt.valid13)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		want10  string
+		want13  string
+		wantErr error
+	}{
+		{value: "0441013597", want10: "0441013597", want13: "9780441013593"},
+		{value: "0-8044-2957-X", want10: "080442957X", want13: "9780804429573"},
+		{value: "978-0-441-01359-3", want10: "0441013597", want13: "9780441013593"},
+		{value: "ISBN 979-10-323-0569-0", want13: "9791032305690"},
+		{value: "0441013598", wantErr: ErrInvalid},
+		{value: "not an isbn", wantErr: ErrInvalid},
+		{value: "", wantErr: ErrInvalid},
+	}
+	for _, tt := range tests {
+		isbn10, isbn13, err := Parse(tt.value)
+		if err != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if isbn10 != tt.want10 || isbn13 != tt.want13 {
+			t.Errorf("Parse(%q) = %q, %q, want %q, %q", tt.value, isbn10, isbn13, tt.want10, tt.want13)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	if got, err := To13("0-441-01359-7"); err != nil || got != "9780441013593" {
+		t.Errorf("To13 = %q, %v", got, err)
+	}
+	if _, err := To13("9780441013593"); err != ErrInvalid {
+		t.Errorf("To13 of an ISBN-13 error = %v, want ErrInvalid", err)
+	}
+	if got, err := To10("9780804429573"); err != nil || got != "080442957X" {
+		t.Errorf("To10 = %q, %v", got, err)
+	}
+	if _, err := To10("9791032305690"); err != ErrNoISBN10 {
+		t.Errorf("To10 of a 979 ISBN error = %v, want ErrNoISBN10", err)
+	}
+	if _, err := To10("0441013597"); err != ErrInvalid {
+		t.Errorf("To10 of an ISBN-10 error = %v, want ErrInvalid", err)
+	}
+}
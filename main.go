@@ -1,27 +1,52 @@
 package main
 
 import (
+	"errors"
+	"example/books-api/auth"
+	"example/books-api/controller"
 	"example/books-api/router"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	// Without a .env file the settings come from the process environment
 	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal("Error loading .env file: ", err)
+	}
+	if err := auth.LoadPolicy(); err != nil {
+		log.Fatal("Error loading policy file: ", err)
+	}
+	if err := controller.Setup(); err != nil {
+		log.Fatal("Error setting up the database: ", err)
+	}
+	if runCommand(os.Args[1:]) {
+		return
 	}
 	fmt.Println("Server is getting started...")
 
-	r := gin.Default()
+	r := router.NewEngine()
 
-	// Register both author and book routes
+	// Register author, book, series, genre, tag, publisher, work, audit, auth, library, cache, catalog and admin routes
 	authorRoutes := router.AuthorRoutes()
 	bookRoutes := router.BookRoutes()
+	seriesRoutes := router.SeriesRoutes()
+	genreRoutes := router.GenreRoutes()
+	tagRoutes := router.TagRoutes()
+	publisherRoutes := router.PublisherRoutes()
+	workRoutes := router.WorkRoutes()
+	auditRoutes := router.AuditRoutes()
+	authRoutes := router.AuthRoutes()
+	libraryRoutes := router.LibraryRoutes()
+	cacheRoutes := router.CacheRoutes()
+	catalogRoutes := router.CatalogRoutes()
+	adminRoutes := router.AdminRoutes()
 
 	// Combine the routes using groups
 	authorGroup := r.Group("/author")
@@ -30,6 +55,39 @@ func main() {
 	bookGroup := r.Group("/book")
 	bookGroup.Any("/*path", gin.WrapH(bookRoutes))
 
+	seriesGroup := r.Group("/series")
+	seriesGroup.Any("/*path", gin.WrapH(seriesRoutes))
+
+	genreGroup := r.Group("/genre")
+	genreGroup.Any("/*path", gin.WrapH(genreRoutes))
+
+	tagGroup := r.Group("/tag")
+	tagGroup.Any("/*path", gin.WrapH(tagRoutes))
+
+	publisherGroup := r.Group("/publisher")
+	publisherGroup.Any("/*path", gin.WrapH(publisherRoutes))
+
+	workGroup := r.Group("/work")
+	workGroup.Any("/*path", gin.WrapH(workRoutes))
+
+	r.Any("/audit", gin.WrapH(auditRoutes))
+
+	authGroup := r.Group("/auth")
+	authGroup.Any("/*path", gin.WrapH(authRoutes))
+
+	libraryGroup := r.Group("/library")
+	libraryGroup.Any("/*path", gin.WrapH(libraryRoutes))
+
+	cacheGroup := r.Group("/cache")
+	cacheGroup.Any("/*path", gin.WrapH(cacheRoutes))
+
+	r.GET("/export.csv", gin.WrapH(catalogRoutes))
+	r.POST("/import", gin.WrapH(catalogRoutes))
+	r.POST("/import/:source", gin.WrapH(catalogRoutes))
+
+	adminGroup := r.Group("/admin")
+	adminGroup.Any("/*path", gin.WrapH(adminRoutes))
+
 	// Start the server
 	log.Fatal(http.ListenAndServe(":8000", r))
 	fmt.Println("Listening on port 8000")
@@ -0,0 +1,81 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GoogleBooks searches the volumes API by ISBN
+type GoogleBooks struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+}
+
+type googleBooksVolumes struct {
+	TotalItems int `json:"totalItems"`
+	Items      []struct {
+		VolumeInfo struct {
+			Title         string   `json:"title"`
+			Subtitle      string   `json:"subtitle"`
+			Description   string   `json:"description"`
+			Authors       []string `json:"authors"`
+			Publisher     string   `json:"publisher"`
+			PublishedDate string   `json:"publishedDate"`
+			PageCount     int      `json:"pageCount"`
+			Language      string   `json:"language"`
+			ImageLinks    struct {
+				Thumbnail      string `json:"thumbnail"`
+				SmallThumbnail string `json:"smallThumbnail"`
+			} `json:"imageLinks"`
+		} `json:"volumeInfo"`
+	} `json:"items"`
+}
+
+func (p *GoogleBooks) Name() string {
+	return "googlebooks"
+}
+
+func (p *GoogleBooks) LookupISBN(ctx context.Context, isbn13 string) (Metadata, error) {
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		baseURL = "https://www.googleapis.com"
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	query := url.Values{"q": {"isbn:" + isbn13}}
+	if p.APIKey != "" {
+		query.Set("key", p.APIKey)
+	}
+
+	var volumes googleBooksVolumes
+	if err := getJSON(ctx, client, strings.TrimSuffix(baseURL, "/")+"/books/v1/volumes?"+query.Encode(), &volumes); err != nil {
+		return Metadata{}, err
+	}
+	if len(volumes.Items) == 0 {
+		return Metadata{}, ErrNotFound
+	}
+
+	info := volumes.Items[0].VolumeInfo
+	cover := info.ImageLinks.Thumbnail
+	if cover == "" {
+		cover = info.ImageLinks.SmallThumbnail
+	}
+	return Metadata{
+		Source:        p.Name(),
+		Title:         info.Title,
+		Subtitle:      info.Subtitle,
+		Description:   info.Description,
+		Authors:       info.Authors,
+		Publisher:     info.Publisher,
+		PublishedDate: info.PublishedDate,
+		PageCount:     info.PageCount,
+		Language:      info.Language,
+		CoverURL:      strings.Replace(cover, "http://", "https://", 1),
+	}, nil
+}
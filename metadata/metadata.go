@@ -0,0 +1,268 @@
+// Package metadata looks up bibliographic data for an ISBN from external
+// providers such as Open Library and Google Books.
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"example/books-api/cache"
+)
+
+var ErrNotFound = errors.New("no metadata for this ISBN")
+
+// ErrRateLimited is returned when a provider still refuses after the retries
+var ErrRateLimited = errors.New("metadata provider rate limit exceeded")
+
+// how often a rate limited request is retried, how long to wait without a
+// Retry-After header (doubled on every retry) and the longest wait accepted
+var (
+	maxRetries   = 2
+	retryBackoff = 500 * time.Millisecond
+	maxRetryWait = 5 * time.Second
+)
+
+// Metadata is what a provider knows about an edition, empty fields are unknown
+type Metadata struct {
+	Source        string   `json:"source"`
+	Title         string   `json:"title,omitempty"`
+	Subtitle      string   `json:"subtitle,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	Authors       []string `json:"authors,omitempty"`
+	Publisher     string   `json:"publisher,omitempty"`
+	PublishedDate string   `json:"publishedDate,omitempty"`
+	PageCount     int      `json:"pageCount,omitempty"`
+	Language      string   `json:"language,omitempty"`
+	CoverURL      string   `json:"coverUrl,omitempty"`
+}
+
+// Provider looks up an ISBN-13, returning ErrNotFound when it has no record
+type Provider interface {
+	Name() string
+	LookupISBN(ctx context.Context, isbn13 string) (Metadata, error)
+}
+
+// fetch and decode url, backing off while the provider answers 429 or 503
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	for attempt := 0; ; attempt++ {
+		wait, err := tryGetJSON(ctx, client, url, v)
+		if err != ErrRateLimited {
+			return err
+		}
+		if attempt == maxRetries {
+			return fmt.Errorf("%s: %w", url, ErrRateLimited)
+		}
+		if wait <= 0 {
+			wait = retryBackoff << attempt
+		}
+		if wait > maxRetryWait {
+			return fmt.Errorf("%s: %w, retry after %s", url, ErrRateLimited, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// one request; ErrRateLimited with the Retry-After wait, zero when not given
+func tryGetJSON(ctx context.Context, client *http.Client, url string, v interface{}) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return 0, json.NewDecoder(resp.Body).Decode(v)
+	case http.StatusNotFound:
+		return 0, ErrNotFound
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return retryAfter(resp.Header.Get("Retry-After")), ErrRateLimited
+	default:
+		return 0, fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode)
+	}
+}
+
+// a Retry-After header in seconds or as an HTTP date, zero when missing or invalid
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// Chain asks each provider in turn and fills every field from the first one that knows it
+type Chain []Provider
+
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, provider := range c {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (c Chain) LookupISBN(ctx context.Context, isbn13 string) (Metadata, error) {
+	var merged Metadata
+	var sources []string
+	var lastErr error
+
+	for _, provider := range c {
+		found, err := provider.LookupISBN(ctx, isbn13)
+		if err != nil {
+			if err != ErrNotFound {
+				lastErr = err
+			}
+			continue
+		}
+		sources = append(sources, found.Source)
+		merged = merge(merged, found)
+	}
+
+	if len(sources) == 0 {
+		if lastErr != nil {
+			return merged, lastErr
+		}
+		return merged, ErrNotFound
+	}
+	merged.Source = strings.Join(sources, ",")
+	return merged, nil
+}
+
+func merge(into Metadata, from Metadata) Metadata {
+	if into.Title == "" {
+		into.Title = from.Title
+	}
+	if into.Subtitle == "" {
+		into.Subtitle = from.Subtitle
+	}
+	if into.Description == "" {
+		into.Description = from.Description
+	}
+	if len(into.Authors) == 0 {
+		into.Authors = from.Authors
+	}
+	if into.Publisher == "" {
+		into.Publisher = from.Publisher
+	}
+	if into.PublishedDate == "" {
+		into.PublishedDate = from.PublishedDate
+	}
+	if into.PageCount == 0 {
+		into.PageCount = from.PageCount
+	}
+	if into.Language == "" {
+		into.Language = from.Language
+	}
+	if into.CoverURL == "" {
+		into.CoverURL = from.CoverURL
+	}
+	return into
+}
+
+// Cached keeps lookups, including misses, in a cache for ttl
+type Cached struct {
+	Provider Provider
+	Cache    cache.Cache
+	TTL      time.Duration
+}
+
+type cachedLookup struct {
+	Found    bool     `json:"found"`
+	Metadata Metadata `json:"metadata"`
+}
+
+func (c Cached) Name() string {
+	return c.Provider.Name()
+}
+
+func (c Cached) LookupISBN(ctx context.Context, isbn13 string) (Metadata, error) {
+	key := "metadata:" + c.Provider.Name() + ":" + isbn13
+	if data, ok := c.Cache.Get(key); ok {
+		var hit cachedLookup
+		if json.Unmarshal(data, &hit) == nil {
+			if !hit.Found {
+				return hit.Metadata, ErrNotFound
+			}
+			return hit.Metadata, nil
+		}
+	}
+
+	found, err := c.Provider.LookupISBN(ctx, isbn13)
+	// Provider failures are not cached so the next request tries again
+	if err != nil && err != ErrNotFound {
+		return found, err
+	}
+
+	if data, marshalErr := json.Marshal(cachedLookup{Found: err == nil, Metadata: found}); marshalErr == nil {
+		c.Cache.Set(key, data, c.TTL)
+	}
+	return found, err
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// NewProviderFromEnv builds the providers named in METADATA_PROVIDERS
+// (default "openlibrary,googlebooks"), cached for METADATA_CACHE_TTL (default 24h);
+// nil when METADATA_PROVIDERS is "none"
+func NewProviderFromEnv() (Provider, error) {
+	names := os.Getenv("METADATA_PROVIDERS")
+	if names == "" {
+		names = "openlibrary,googlebooks"
+	}
+	if names == "none" {
+		return nil, nil
+	}
+
+	client := &http.Client{Timeout: envDuration("METADATA_TIMEOUT", 5*time.Second)}
+
+	var chain Chain
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "openlibrary":
+			chain = append(chain, &OpenLibrary{BaseURL: os.Getenv("OPENLIBRARY_URL"), CoversURL: os.Getenv("OPENLIBRARY_COVERS_URL"), Client: client})
+		case "googlebooks":
+			chain = append(chain, &GoogleBooks{BaseURL: os.Getenv("GOOGLE_BOOKS_URL"), APIKey: os.Getenv("GOOGLE_BOOKS_API_KEY"), Client: client})
+		default:
+			return nil, fmt.Errorf("unknown metadata provider %q", name)
+		}
+	}
+
+	return Cached{
+		Provider: chain,
+		Cache:    cache.NewLRU(1000),
+		TTL:      envDuration("METADATA_CACHE_TTL", 24*time.Hour),
+	}, nil
+}
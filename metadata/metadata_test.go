@@ -0,0 +1,315 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example/books-api/cache"
+)
+
+const duneISBN = "9780441172719"
+
+// a stub provider answering from the recorded fixtures in testdata
+func fixtureServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serveFixture(t *testing.T, w http.ResponseWriter, name string) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// make the backoff fast and restore it after the test
+func fastBackoff(t *testing.T) {
+	retries, backoff, wait := maxRetries, retryBackoff, maxRetryWait
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { maxRetries, retryBackoff, maxRetryWait = retries, backoff, wait })
+}
+
+func TestOpenLibraryLookupISBN(t *testing.T) {
+	server := fixtureServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/isbn/"+duneISBN+".json" {
+			http.NotFound(w, r)
+			return
+		}
+		serveFixture(t, w, "openlibrary_"+duneISBN+".json")
+	})
+	provider := &OpenLibrary{BaseURL: server.URL, CoversURL: "https://covers.example", Client: server.Client()}
+
+	got, err := provider.LookupISBN(context.Background(), duneISBN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{
+		Source:        "openlibrary",
+		Title:         "Dune",
+		Subtitle:      "Deluxe Edition",
+		Publisher:     "Ace",
+		PublishedDate: "2019",
+		PageCount:     896,
+		Language:      "en",
+		CoverURL:      "https://covers.example/b/id/8231856-L.jpg",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LookupISBN = %+v, want %+v", got, want)
+	}
+
+	if _, err := provider.LookupISBN(context.Background(), "9780000000002"); err != ErrNotFound {
+		t.Errorf("unknown ISBN error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGoogleBooksLookupISBN(t *testing.T) {
+	var query string
+	server := fixtureServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		if r.URL.Path != "/books/v1/volumes" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("q") == "isbn:"+duneISBN {
+			serveFixture(t, w, "googlebooks_"+duneISBN+".json")
+			return
+		}
+		serveFixture(t, w, "googlebooks_empty.json")
+	})
+	provider := &GoogleBooks{BaseURL: server.URL, APIKey: "secret", Client: server.Client()}
+
+	got, err := provider.LookupISBN(context.Background(), duneISBN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{
+		Source:        "googlebooks",
+		Title:         "Dune",
+		Description:   "Set on the desert planet Arrakis.",
+		Authors:       []string{"Frank Herbert"},
+		Publisher:     "Penguin",
+		PublishedDate: "2005-08-02",
+		PageCount:     528,
+		Language:      "en",
+		CoverURL:      "https://books.google.com/books/content?id=B1hSG45JCX4C&zoom=1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LookupISBN = %+v, want %+v", got, want)
+	}
+	if want := "key=secret&q=isbn%3A" + duneISBN; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+
+	// An empty result set is a miss, not an error
+	if _, err := provider.LookupISBN(context.Background(), "9780000000002"); err != ErrNotFound {
+		t.Errorf("unknown ISBN error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetJSONRateLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		// statuses answered in turn, the last one repeats
+		statuses   []int
+		retryAfter string
+		wantErr    error
+		wantCalls  int32
+	}{
+		{name: "ok", statuses: []int{http.StatusOK}, wantCalls: 1},
+		{name: "retried after 429", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, retryAfter: "0", wantCalls: 2},
+		{name: "retried after 503 with backoff", statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK}, wantCalls: 3},
+		{name: "gives up after the retries", statuses: []int{http.StatusTooManyRequests}, retryAfter: "0", wantErr: ErrRateLimited, wantCalls: 3},
+		{name: "does not wait too long", statuses: []int{http.StatusTooManyRequests}, retryAfter: "120", wantErr: ErrRateLimited, wantCalls: 1},
+		{name: "not found is not retried", statuses: []int{http.StatusNotFound}, wantErr: ErrNotFound, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fastBackoff(t)
+			var calls int32
+			server := fixtureServer(t, func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&calls, 1)) - 1
+				if n >= len(tt.statuses) {
+					n = len(tt.statuses) - 1
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				if tt.statuses[n] != http.StatusOK {
+					w.WriteHeader(tt.statuses[n])
+					return
+				}
+				w.Write([]byte(`{"title": "Dune"}`))
+			})
+
+			var edition openLibraryEdition
+			err := getJSON(context.Background(), server.Client(), server.URL, &edition)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("getJSON error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("getJSON error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && edition.Title != "Dune" {
+				t.Errorf("decoded %+v", edition)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestGetJSONRateLimitStopsWithContext(t *testing.T) {
+	fastBackoff(t)
+	retryBackoff = time.Minute
+	maxRetryWait = time.Hour
+
+	server := fixtureServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var edition openLibraryEdition
+	if err := getJSON(ctx, server.Client(), server.URL, &edition); err != context.DeadlineExceeded {
+		t.Errorf("getJSON error = %v, want the context's", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "0", want: 0},
+		{value: "3", want: 3 * time.Second},
+		{value: "-1", want: 0},
+		{value: "soon", want: 0},
+		{value: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.value); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := retryAfter(future); got <= 59*time.Minute || got > time.Hour {
+		t.Errorf("retryAfter(%q) = %v, want about an hour", future, got)
+	}
+}
+
+// a provider answering from memory
+type stubProvider struct {
+	name  string
+	found Metadata
+	err   error
+	calls int
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) LookupISBN(ctx context.Context, isbn13 string) (Metadata, error) {
+	p.calls++
+	if p.err != nil {
+		return Metadata{}, p.err
+	}
+	found := p.found
+	found.Source = p.name
+	return found, nil
+}
+
+func TestChainLookupISBN(t *testing.T) {
+	failure := errors.New("provider down")
+	tests := []struct {
+		name      string
+		providers []*stubProvider
+		want      Metadata
+		wantErr   error
+	}{
+		{
+			name: "first provider wins, the second fills the gaps",
+			providers: []*stubProvider{
+				{name: "a", found: Metadata{Title: "Dune", PageCount: 896}},
+				{name: "b", found: Metadata{Title: "Dune (Penguin)", Publisher: "Penguin"}},
+			},
+			want: Metadata{Source: "a,b", Title: "Dune", PageCount: 896, Publisher: "Penguin"},
+		},
+		{
+			name: "misses and failures are skipped",
+			providers: []*stubProvider{
+				{name: "a", err: ErrNotFound},
+				{name: "b", err: failure},
+				{name: "c", found: Metadata{Title: "Dune"}},
+			},
+			want: Metadata{Source: "c", Title: "Dune"},
+		},
+		{
+			name:      "all miss",
+			providers: []*stubProvider{{name: "a", err: ErrNotFound}, {name: "b", err: ErrNotFound}},
+			wantErr:   ErrNotFound,
+		},
+		{
+			name:      "a failure beats a miss",
+			providers: []*stubProvider{{name: "a", err: ErrNotFound}, {name: "b", err: ErrRateLimited}},
+			wantErr:   ErrRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chain Chain
+			for _, provider := range tt.providers {
+				chain = append(chain, provider)
+			}
+			got, err := chain.LookupISBN(context.Background(), duneISBN)
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LookupISBN = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCachedLookupISBN(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  *stubProvider
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "hits are cached", provider: &stubProvider{name: "a", found: Metadata{Title: "Dune"}}, wantCalls: 1},
+		{name: "misses are cached", provider: &stubProvider{name: "a", err: ErrNotFound}, wantErr: ErrNotFound, wantCalls: 1},
+		{name: "failures are not cached", provider: &stubProvider{name: "a", err: ErrRateLimited}, wantErr: ErrRateLimited, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cached := Cached{Provider: tt.provider, Cache: cache.NewLRU(10), TTL: time.Hour}
+			for i := 0; i < 2; i++ {
+				if _, err := cached.LookupISBN(context.Background(), duneISBN); err != tt.wantErr {
+					t.Fatalf("lookup %d error = %v, want %v", i, err, tt.wantErr)
+				}
+			}
+			if tt.provider.calls != tt.wantCalls {
+				t.Errorf("provider calls = %d, want %d", tt.provider.calls, tt.wantCalls)
+			}
+		})
+	}
+}
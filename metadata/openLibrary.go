@@ -0,0 +1,79 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MARC language codes Open Library uses, mapped to BCP-47
+var marcLanguages = map[string]string{
+	"eng": "en", "fre": "fr", "ger": "de", "spa": "es", "ita": "it", "por": "pt",
+	"dut": "nl", "swe": "sv", "nor": "no", "dan": "da", "fin": "fi", "pol": "pl",
+	"rus": "ru", "jpn": "ja", "chi": "zh", "kor": "ko", "ara": "ar", "heb": "he",
+	"tur": "tr", "gre": "el", "cze": "cs", "hun": "hu", "scc": "sr", "srp": "sr",
+	"hrv": "hr", "bos": "bs", "slv": "sl", "ukr": "uk", "lat": "la",
+}
+
+// OpenLibrary reads edition records from /isbn/{isbn}.json
+type OpenLibrary struct {
+	BaseURL   string
+	CoversURL string
+	Client    *http.Client
+}
+
+type openLibraryEdition struct {
+	Title         string   `json:"title"`
+	Subtitle      string   `json:"subtitle"`
+	Publishers    []string `json:"publishers"`
+	PublishDate   string   `json:"publish_date"`
+	NumberOfPages int      `json:"number_of_pages"`
+	Covers        []int    `json:"covers"`
+	Languages     []struct {
+		Key string `json:"key"`
+	} `json:"languages"`
+}
+
+func (p *OpenLibrary) Name() string {
+	return "openlibrary"
+}
+
+func (p *OpenLibrary) LookupISBN(ctx context.Context, isbn13 string) (Metadata, error) {
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		baseURL = "https://openlibrary.org"
+	}
+	coversURL := p.CoversURL
+	if coversURL == "" {
+		coversURL = "https://covers.openlibrary.org"
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var edition openLibraryEdition
+	if err := getJSON(ctx, client, strings.TrimSuffix(baseURL, "/")+"/isbn/"+isbn13+".json", &edition); err != nil {
+		return Metadata{}, err
+	}
+
+	found := Metadata{
+		Source:        p.Name(),
+		Title:         edition.Title,
+		Subtitle:      edition.Subtitle,
+		PublishedDate: edition.PublishDate,
+		PageCount:     edition.NumberOfPages,
+	}
+	if len(edition.Publishers) > 0 {
+		found.Publisher = edition.Publishers[0]
+	}
+	if len(edition.Languages) > 0 {
+		code := strings.TrimPrefix(edition.Languages[0].Key, "/languages/")
+		found.Language = marcLanguages[code]
+	}
+	if len(edition.Covers) > 0 && edition.Covers[0] > 0 {
+		found.CoverURL = fmt.Sprintf("%s/b/id/%d-L.jpg", strings.TrimSuffix(coversURL, "/"), edition.Covers[0])
+	}
+	return found, nil
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"example/books-api/auth"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ClaimsKey = "claims"
+
+// Authenticate requires either a valid, unrevoked Bearer access token or an
+// "ApiKey <key>" Authorization header, and attaches the resulting claims to the context
+func Authenticate(isRevoked func(tokenID string) bool, verifyAPIKey func(key string) (*auth.Claims, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var claims *auth.Claims
+		var err error
+
+		if key, ok := APIKey(c); ok {
+			claims, err = verifyAPIKey(key)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+				return
+			}
+		} else if token, ok := BearerToken(c); ok {
+			claims, err = auth.ParseAccessToken(token)
+			if err != nil || isRevoked(claims.ID) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				return
+			}
+		} else {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token or API key"})
+			return
+		}
+
+		c.Set(ClaimsKey, claims)
+		c.Set(ActorKey, claims.Username)
+		c.Next()
+	}
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>" header
+func BearerToken(c *gin.Context) (string, bool) {
+	return authorizationValue(c, "Bearer ")
+}
+
+// APIKey extracts the key from an "Authorization: ApiKey <key>" header
+func APIKey(c *gin.Context) (string, bool) {
+	return authorizationValue(c, "ApiKey ")
+}
+
+func authorizationValue(c *gin.Context, scheme string) (string, bool) {
+	header := c.GetHeader("Authorization")
+	value, found := strings.CutPrefix(header, scheme)
+	if !found || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// ClaimsFrom returns the claims attached by Authenticate, nil for anonymous requests
+func ClaimsFrom(c *gin.Context) *auth.Claims {
+	if claims, ok := c.Get(ClaimsKey); ok {
+		return claims.(*auth.Claims)
+	}
+	return nil
+}
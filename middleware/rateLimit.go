@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"example/books-api/auth"
+	"example/books-api/ratelimit"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit applies separate token buckets to reads (GET, HEAD) and writes per
+// client: the API key or user once Authenticate has verified them, else the client IP.
+// Use it before Authenticate so rejected credentials are limited by IP, and again
+// after it to give each verified client its own budget.
+func RateLimit(store ratelimit.Store, read ratelimit.Limit, write ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		class, limit := "write", write
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			class, limit = "read", read
+		}
+
+		result, err := store.Take(c.Request.Context(), class+":"+rateLimitClient(c), limit)
+		if err != nil {
+			// Fail open, an unavailable store should not take the API down
+			log.Println("Rate limit store error: ", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.Reset))
+
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// credentials only count once Authenticate accepted them, otherwise anyone could
+// send a fresh key or token with every request to get a fresh bucket
+func rateLimitClient(c *gin.Context) string {
+	claims := ClaimsFrom(c)
+	if claims == nil {
+		return "ip:" + c.ClientIP()
+	}
+	if key, ok := APIKey(c); ok {
+		return "key:" + auth.HashToken(key)
+	}
+	return "user:" + claims.UserID
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDKey    = "requestId"
+	ActorKey        = "actor"
+	requestIDHeader = "X-Request-ID"
+)
+
+// RequestID reuses the caller's X-Request-ID or generates one, and echoes it back
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+			c.Request.Header.Set(requestIDHeader, requestID)
+		}
+		c.Set(RequestIDKey, requestID)
+		c.Header(requestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
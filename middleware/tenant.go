@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"example/books-api/auth"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	LibraryIDKey    = "libraryId"
+	libraryIDHeader = "X-Library-ID"
+)
+
+// Tenant resolves the library a request operates on. Users and API keys bound to a
+// library always use it; admins pick one with the X-Library-ID header or a
+// subdomain of TENANT_BASE_DOMAIN. resolve accepts a library ID or slug.
+func Tenant(resolve func(ref string) (string, bool)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := ClaimsFrom(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		requested := ""
+		if ref := requestedLibrary(c); ref != "" {
+			id, ok := resolve(ref)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+				return
+			}
+			requested = id
+		}
+
+		libraryID := claims.LibraryID
+		switch {
+		case libraryID != "" && requested != "" && requested != libraryID:
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not a member of this library"})
+			return
+		case libraryID == "" && claims.Can(auth.PermLibraryManage):
+			libraryID = requested
+		}
+
+		if libraryID == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "No library selected"})
+			return
+		}
+
+		c.Set(LibraryIDKey, libraryID)
+		c.Next()
+	}
+}
+
+func requestedLibrary(c *gin.Context) string {
+	if ref := c.GetHeader(libraryIDHeader); ref != "" {
+		return ref
+	}
+
+	base := os.Getenv("TENANT_BASE_DOMAIN")
+	if base == "" {
+		return ""
+	}
+
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	slug, found := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(base))
+	if !found || strings.Contains(slug, ".") {
+		return ""
+	}
+	return slug
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APIKey struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name       string             `json:"name,omitempty" bson:"name,omitempty"`
+	Prefix     string             `json:"prefix,omitempty" bson:"prefix,omitempty"`
+	KeyHash    string             `json:"-" bson:"keyHash,omitempty"`
+	Scopes     []string           `json:"scopes" bson:"scopes"`
+	LibraryID  primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	ExpiresAt  *time.Time         `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	LastUsedAt *time.Time         `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time         `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	Timestamps `bson:",inline"`
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	LibraryID string     `json:"libraryId"`
+}
+
+// APIKeyWithSecret is only returned when a key is created or rotated
+type APIKeyWithSecret struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditEntry struct {
+	ID        primitive.ObjectID     `json:"_id,omitempty" bson:"_id,omitempty"`
+	LibraryID primitive.ObjectID     `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	Entity    string                 `json:"entity,omitempty" bson:"entity,omitempty"`
+	EntityID  primitive.ObjectID     `json:"entityId,omitempty" bson:"entityId,omitempty"`
+	Action    string                 `json:"action,omitempty" bson:"action,omitempty"`
+	Actor     string                 `json:"actor,omitempty" bson:"actor,omitempty"`
+	RequestID string                 `json:"requestId,omitempty" bson:"requestId,omitempty"`
+	Timestamp time.Time              `json:"timestamp,omitempty" bson:"timestamp,omitempty"`
+	Before    bson.M                 `json:"before,omitempty" bson:"before,omitempty"`
+	After     bson.M                 `json:"after,omitempty" bson:"after,omitempty"`
+	Changes   map[string]AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+}
+
+type AuditChange struct {
+	From interface{} `json:"from" bson:"from"`
+	To   interface{} `json:"to" bson:"to"`
+}
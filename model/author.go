@@ -7,5 +7,15 @@ import (
 type Author struct {
     ID    primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
     Name  string             `json:"name,omitempty" bson:"name,omitempty"`
+    Aliases []string         `json:"aliases,omitempty" bson:"aliases,omitempty"`
+    Biography string         `json:"biography,omitempty" bson:"biography,omitempty"`
+    BirthDate string         `json:"birthDate,omitempty" bson:"birthDate,omitempty"`
+    DeathDate string         `json:"deathDate,omitempty" bson:"deathDate,omitempty"`
+    Nationality string       `json:"nationality,omitempty" bson:"nationality,omitempty"`
+    ISNI  string             `json:"isni,omitempty" bson:"isni,omitempty"`
+    VIAF  string             `json:"viaf,omitempty" bson:"viaf,omitempty"`
+    OpenLibraryID string     `json:"openLibraryId,omitempty" bson:"openLibraryId,omitempty"`
     Books []primitive.ObjectID `json:"books,omitempty" bson:"books,omitempty"`
+    LibraryID primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+    Timestamps `bson:",inline"`
 }
\ No newline at end of file
@@ -5,9 +5,19 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 type AuthorWithBooks struct {
     ID    primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
     Name  string             `json:"name,omitempty" bson:"name,omitempty"`
+    Aliases []string         `json:"aliases,omitempty" bson:"aliases,omitempty"`
+    Biography string         `json:"biography,omitempty" bson:"biography,omitempty"`
+    BirthDate string         `json:"birthDate,omitempty" bson:"birthDate,omitempty"`
+    DeathDate string         `json:"deathDate,omitempty" bson:"deathDate,omitempty"`
+    Nationality string       `json:"nationality,omitempty" bson:"nationality,omitempty"`
+    ISNI  string             `json:"isni,omitempty" bson:"isni,omitempty"`
+    VIAF  string             `json:"viaf,omitempty" bson:"viaf,omitempty"`
+    OpenLibraryID string     `json:"openLibraryId,omitempty" bson:"openLibraryId,omitempty"`
     Books []BookInfo         `json:"books,omitempty" bson:"books,omitempty"`
+    Timestamps `bson:",inline"`
 }
 
 type BookInfo struct {
+    ID    primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
     Title string             `json:"title,omitempty" bson:"title,omitempty"`
 }
\ No newline at end of file
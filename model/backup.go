@@ -0,0 +1,13 @@
+package model
+
+type RestoreCounts struct {
+	Restored    int64 `json:"restored"`
+	Overwritten int64 `json:"overwritten"`
+	Skipped     int64 `json:"skipped"`
+}
+
+type RestoreReport struct {
+	Strategy    string                   `json:"strategy"`
+	Version     int                      `json:"version"`
+	Collections map[string]RestoreCounts `json:"collections"`
+}
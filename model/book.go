@@ -1,13 +1,60 @@
 package model
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Reading states, imported from Goodreads shelves and StoryGraph read statuses
+const (
+	ReadingStatusToRead       = "to-read"
+	ReadingStatusReading      = "currently-reading"
+	ReadingStatusRead         = "read"
+	ReadingStatusDidNotFinish = "did-not-finish"
+)
+
+// Book formats
+const (
+	BookFormatHardcover = "hardcover"
+	BookFormatPaperback = "paperback"
+	BookFormatEbook     = "ebook"
+	BookFormatAudiobook = "audiobook"
+)
+
 type Book struct {
     ID     primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
     Title  string             `json:"title,omitempty" bson:"title,omitempty"`
+    Subtitle string           `json:"subtitle,omitempty" bson:"subtitle,omitempty"`
+    Description string        `json:"description,omitempty" bson:"description,omitempty"`
+    Publisher string          `json:"publisher,omitempty" bson:"publisher,omitempty"`
+    PublisherID primitive.ObjectID `json:"publisherId,omitempty" bson:"publisherId,omitempty"`
+    PublicationYear int       `json:"publicationYear,omitempty" bson:"publicationYear,omitempty"`
+    PublishedDate string      `json:"publishedDate,omitempty" bson:"publishedDate,omitempty"`
+    Edition string            `json:"edition,omitempty" bson:"edition,omitempty"`
+    PageCount int             `json:"pageCount,omitempty" bson:"pageCount,omitempty"`
+    Language string           `json:"language,omitempty" bson:"language,omitempty"`
+    Format string             `json:"format,omitempty" bson:"format,omitempty"`
+    CoverURL string           `json:"coverUrl,omitempty" bson:"coverUrl,omitempty"`
     Genre  string             `json:"genre,omitempty" bson:"genre,omitempty"`
+    Genres []primitive.ObjectID `json:"genres,omitempty" bson:"genres,omitempty"`
+    SeriesID primitive.ObjectID `json:"seriesId,omitempty" bson:"seriesId,omitempty"`
+    Volume float64            `json:"volume,omitempty" bson:"volume,omitempty"`
+    // the work this book is an edition of, its authors are those of the work
+    WorkID primitive.ObjectID `json:"workId,omitempty" bson:"workId,omitempty"`
     Authors []primitive.ObjectID `json:"authors,omitempty" bson:"authors,omitempty"`
-    Read   bool               `json:"read,omitempty"`
+    Contributors []Contributor `json:"contributors,omitempty" bson:"-"`
+    // the requesting reader's own state, stored in readingStates rather than on the shared book
+    Read   bool               `json:"read,omitempty" bson:"-"`
+    ReadingStatus string      `json:"readingStatus,omitempty" bson:"-"`
+    Rating float64            `json:"rating,omitempty" bson:"-"`
+    DateRead *time.Time       `json:"dateRead,omitempty" bson:"-"`
+    Shelves []string          `json:"shelves,omitempty" bson:"-"`
+    Tags []BookTag            `json:"tags,omitempty" bson:"tags,omitempty"`
+    ISBN10 string             `json:"isbn10,omitempty" bson:"isbn10,omitempty"`
+    ISBN13 string             `json:"isbn13,omitempty" bson:"isbn13,omitempty"`
+    // fields last filled by a metadata provider, the others were entered by a user
+    EnrichedFields []string   `json:"enrichedFields,omitempty" bson:"enrichedFields,omitempty"`
+    LibraryID primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+    Timestamps `bson:",inline"`
 }
\ No newline at end of file
@@ -4,8 +4,27 @@ import (
     "go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Roles an author can have on a book, links without a role are authors
+const (
+	AuthorRoleAuthor      = "author"
+	AuthorRoleEditor      = "editor"
+	AuthorRoleTranslator  = "translator"
+	AuthorRoleIllustrator = "illustrator"
+)
+
 type BookAuthor struct {
     ID     primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
     Author primitive.ObjectID `json:"author,omitempty" bson:"author,omitempty"`
     Book   primitive.ObjectID `json:"book,omitempty" bson:"book,omitempty"`
+    Role   string             `json:"role,omitempty" bson:"role,omitempty"`
+    Position int              `json:"position,omitempty" bson:"position,omitempty"`
+    LibraryID primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+    Timestamps `bson:",inline"`
+}
+
+// an author's part in a book as given when creating or updating it, position orders authors of the same role
+type Contributor struct {
+    Author   primitive.ObjectID `json:"author" bson:"author"`
+    Role     string             `json:"role,omitempty" bson:"role,omitempty"`
+    Position int                `json:"position,omitempty" bson:"position,omitempty"`
 }
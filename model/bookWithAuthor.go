@@ -1,15 +1,52 @@
 package model
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type BookWithAuthor struct {
     ID     primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
     Title  string             `json:"title,omitempty" bson:"title,omitempty"`
+    Subtitle string           `json:"subtitle,omitempty" bson:"subtitle,omitempty"`
+    Description string        `json:"description,omitempty" bson:"description,omitempty"`
+    Publisher string          `json:"publisher,omitempty" bson:"publisher,omitempty"`
+    PublisherID primitive.ObjectID `json:"publisherId,omitempty" bson:"publisherId,omitempty"`
+    PublicationYear int       `json:"publicationYear,omitempty" bson:"publicationYear,omitempty"`
+    PublishedDate string      `json:"publishedDate,omitempty" bson:"publishedDate,omitempty"`
+    Edition string            `json:"edition,omitempty" bson:"edition,omitempty"`
+    PageCount int             `json:"pageCount,omitempty" bson:"pageCount,omitempty"`
+    Language string           `json:"language,omitempty" bson:"language,omitempty"`
+    Format string             `json:"format,omitempty" bson:"format,omitempty"`
+    CoverURL string           `json:"coverUrl,omitempty" bson:"coverUrl,omitempty"`
     Genre  string             `json:"genre,omitempty" bson:"genre,omitempty"`
+    Genres []GenreInfo        `json:"genres,omitempty" bson:"genres,omitempty"`
+    SeriesID primitive.ObjectID `json:"seriesId,omitempty" bson:"seriesId,omitempty"`
+    Volume float64            `json:"volume,omitempty" bson:"volume,omitempty"`
+    // the work this book is an edition of, its authors are those of the work
+    WorkID primitive.ObjectID `json:"workId,omitempty" bson:"workId,omitempty"`
     Authors []AuthorInfo      `json:"authors,omitempty" bson:"authors,omitempty"`
+    Editors []AuthorInfo      `json:"editors,omitempty" bson:"editors,omitempty"`
+    Translators []AuthorInfo  `json:"translators,omitempty" bson:"translators,omitempty"`
+    Illustrators []AuthorInfo `json:"illustrators,omitempty" bson:"illustrators,omitempty"`
+    Contributors []AuthorInfo `json:"-" bson:"contributors,omitempty"`
+    // the requesting reader's own state, looked up in readingStates
     Read   bool               `json:"read,omitempty" bson:"read,omitempty"`
+    ReadingStatus string      `json:"readingStatus,omitempty" bson:"readingStatus,omitempty"`
+    Rating float64            `json:"rating,omitempty" bson:"rating,omitempty"`
+    DateRead *time.Time       `json:"dateRead,omitempty" bson:"dateRead,omitempty"`
+    Shelves []string          `json:"shelves,omitempty" bson:"shelves,omitempty"`
+    Tags []BookTag            `json:"tags,omitempty" bson:"tags,omitempty"`
+    ISBN10 string             `json:"isbn10,omitempty" bson:"isbn10,omitempty"`
+    ISBN13 string             `json:"isbn13,omitempty" bson:"isbn13,omitempty"`
+    EnrichedFields []string   `json:"enrichedFields,omitempty" bson:"enrichedFields,omitempty"`
+    Timestamps `bson:",inline"`
 }
 
 type AuthorInfo struct {
-	Name string             `json:"name,omitempty" bson:"name,omitempty"`
+	ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name     string             `json:"name,omitempty" bson:"name,omitempty"`
+	Role     string             `json:"-" bson:"role,omitempty"`
+	Position int                `json:"-" bson:"position,omitempty"`
 }
@@ -0,0 +1,44 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// a node of a library's genre tree, e.g. Fiction > Speculative > Science Fiction
+type Genre struct {
+	ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name     string             `json:"name,omitempty" bson:"name,omitempty"`
+	ParentID primitive.ObjectID `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	// from the root down to the parent, so a genre's descendants are found with one query
+	Ancestors []primitive.ObjectID `json:"ancestors,omitempty" bson:"ancestors,omitempty"`
+	// other spellings free-text genres are mapped from, e.g. "Sci-Fi" for Science Fiction
+	Aliases []string `json:"aliases,omitempty" bson:"aliases,omitempty"`
+	// normalized name and aliases, unique within a library
+	Keys       []string           `json:"-" bson:"keys,omitempty"`
+	LibraryID  primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	Timestamps `bson:",inline"`
+}
+
+// a genre with its subgenres, as returned by the tree endpoint
+type GenreNode struct {
+	Genre
+	Children []GenreNode `json:"children"`
+}
+
+type GenreInfo struct {
+	ID   primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name string             `json:"name,omitempty" bson:"name,omitempty"`
+}
+
+// outcome of mapping free-text genres onto the taxonomy
+type GenreMigrationReport struct {
+	DryRun   bool            `json:"dryRun"`
+	Books    int             `json:"books"`
+	Mapped   int             `json:"mapped"`
+	Partial  int             `json:"partial"`
+	Unmapped []UnmappedGenre `json:"unmapped"`
+}
+
+// a free-text genre no genre name or alias matches, with the number of books using it
+type UnmappedGenre struct {
+	Value string `json:"value"`
+	Books int    `json:"books"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+type ImportReport struct {
+	Source         string             `json:"source"`
+	DryRun         bool               `json:"dryRun"`
+	Created        int                `json:"created"`
+	Updated        int                `json:"updated"`
+	Skipped        int                `json:"skipped"`
+	Errored        int                `json:"errored"`
+	AuthorsCreated int                `json:"authorsCreated"`
+	Errors         []ImportRowError   `json:"errors,omitempty"`
+	Preview        []ImportPreviewRow `json:"preview,omitempty"`
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// what an import would do with one row, returned by dry runs
+type ImportPreviewRow struct {
+	Row           int        `json:"row"`
+	Action        string     `json:"action"`
+	Title         string     `json:"title,omitempty"`
+	Authors       []string   `json:"authors,omitempty"`
+	ReadingStatus string     `json:"readingStatus,omitempty"`
+	Rating        float64    `json:"rating,omitempty"`
+	DateRead      *time.Time `json:"dateRead,omitempty"`
+	Shelves       []string   `json:"shelves,omitempty"`
+	ISBN10        string     `json:"isbn10,omitempty"`
+	ISBN13        string     `json:"isbn13,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type Library struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name       string             `json:"name,omitempty" bson:"name,omitempty"`
+	Slug       string             `json:"slug,omitempty" bson:"slug,omitempty"`
+	Quota      LibraryQuota       `json:"quota" bson:"quota"`
+	Timestamps `bson:",inline"`
+}
+
+// LibraryQuota limits the size of a library's catalog, 0 means unlimited
+type LibraryQuota struct {
+	MaxAuthors    int64 `json:"maxAuthors" bson:"maxAuthors"`
+	MaxBooks      int64 `json:"maxBooks" bson:"maxBooks"`
+	MaxSeries     int64 `json:"maxSeries" bson:"maxSeries"`
+	MaxGenres     int64 `json:"maxGenres" bson:"maxGenres"`
+	MaxPublishers int64 `json:"maxPublishers" bson:"maxPublishers"`
+	MaxWorks      int64 `json:"maxWorks" bson:"maxWorks"`
+}
+
+type LibraryUsage struct {
+	Authors int64 `json:"authors"`
+	Books   int64 `json:"books"`
+	Links   int64 `json:"links"`
+	Series  int64 `json:"series"`
+	Genres  int64 `json:"genres"`
+	// publishers and imprints
+	Publishers int64 `json:"publishers"`
+	Works      int64 `json:"works"`
+}
+
+type LibraryWithUsage struct {
+	Library `bson:",inline"`
+	Usage   LibraryUsage `json:"usage"`
+}
+
+type LibraryAssignment struct {
+	LibraryID string `json:"libraryId"`
+}
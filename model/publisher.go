@@ -0,0 +1,22 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// a publisher, or an imprint of one when ParentID is set
+type Publisher struct {
+	ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name     string             `json:"name,omitempty" bson:"name,omitempty"`
+	ParentID primitive.ObjectID `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	// other names free-text publishers are linked by, merged publishers leave theirs here
+	Aliases    []string           `json:"aliases,omitempty" bson:"aliases,omitempty"`
+	Website    string             `json:"website,omitempty" bson:"website,omitempty"`
+	LibraryID  primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	Timestamps `bson:",inline"`
+}
+
+// a publisher with its imprints and the number of books published under either
+type PublisherWithImprints struct {
+	Publisher `bson:",inline"`
+	Imprints  []Publisher `json:"imprints"`
+	Books     int64       `json:"books"`
+}
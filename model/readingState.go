@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// a reader's own state of one book or edition, there is at most one per user and book
+type ReadingState struct {
+	ID            primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Actor         string             `json:"actor" bson:"actor"`
+	Book          primitive.ObjectID `json:"book" bson:"book"`
+	Read          bool               `json:"read" bson:"read"`
+	ReadingStatus string             `json:"readingStatus,omitempty" bson:"readingStatus,omitempty"`
+	DateRead      *time.Time         `json:"dateRead,omitempty" bson:"dateRead,omitempty"`
+	Rating        float64            `json:"rating,omitempty" bson:"rating,omitempty"`
+	Shelves       []string           `json:"shelves,omitempty" bson:"shelves,omitempty"`
+	LibraryID     primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	Timestamps    `bson:",inline"`
+}
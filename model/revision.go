@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Revision struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	LibraryID primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	Entity    string             `json:"entity,omitempty" bson:"entity,omitempty"`
+	EntityID  primitive.ObjectID `json:"entityId,omitempty" bson:"entityId,omitempty"`
+	Revision  int                `json:"revision" bson:"revision"`
+	Document  bson.M             `json:"document,omitempty" bson:"document,omitempty"`
+	Links     []bson.M           `json:"links" bson:"links"`
+	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+	CreatedBy string             `json:"createdBy,omitempty" bson:"createdBy,omitempty"`
+	RequestID string             `json:"requestId,omitempty" bson:"requestId,omitempty"`
+}
+
+type RevisionDiff struct {
+	From    int                    `json:"from"`
+	To      int                    `json:"to"`
+	Changes map[string]AuditChange `json:"changes"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Series struct {
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name         string             `json:"name,omitempty" bson:"name,omitempty"`
+	Description  string             `json:"description,omitempty" bson:"description,omitempty"`
+	TotalVolumes int                `json:"totalVolumes,omitempty" bson:"totalVolumes,omitempty"`
+	LibraryID    primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	Timestamps   `bson:",inline"`
+}
+
+// a series with its books in reading order
+type SeriesWithBooks struct {
+	Series    `bson:",inline"`
+	Books     []SeriesBook `json:"books"`
+	ReadCount int          `json:"readCount"`
+}
+
+// a book of a series and its reading state
+type SeriesBook struct {
+	ID              primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Title           string             `json:"title,omitempty" bson:"title,omitempty"`
+	Volume          float64            `json:"volume,omitempty" bson:"volume,omitempty"`
+	PublicationYear int                `json:"publicationYear,omitempty" bson:"publicationYear,omitempty"`
+	Read            bool               `json:"read" bson:"read"`
+	ReadingStatus   string             `json:"readingStatus,omitempty" bson:"readingStatus,omitempty"`
+	DateRead        *time.Time         `json:"dateRead,omitempty" bson:"dateRead,omitempty"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// a free-form label on a book; tags without an owner are shared with the whole
+// library and stored on the book, the others are only seen by the user who added them
+type BookTag struct {
+	Name  string `json:"name" bson:"name"`
+	Owner string `json:"owner,omitempty" bson:"owner,omitempty"`
+}
+
+// a tag only its owner sees, kept apart from the shared book so that tagging
+// never changes the catalog; there is at most one per owner, book and name
+type PersonalTag struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Owner      string             `json:"owner" bson:"owner"`
+	Book       primitive.ObjectID `json:"book" bson:"book"`
+	Name       string             `json:"name" bson:"name"`
+	LibraryID  primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	Timestamps `bson:",inline"`
+}
+
+// a tag with the number of books carrying it, as listed by the tag endpoints
+type TagCount struct {
+	Name   string `json:"name"`
+	Shared bool   `json:"shared"`
+	Books  int    `json:"books"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+type Timestamps struct {
+	CreatedAt time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty" bson:"createdBy,omitempty"`
+	UpdatedBy string    `json:"updatedBy,omitempty" bson:"updatedBy,omitempty"`
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type User struct {
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Username     string             `json:"username,omitempty" bson:"username,omitempty"`
+	PasswordHash string             `json:"-" bson:"passwordHash,omitempty"`
+	Role         string             `json:"role,omitempty" bson:"role,omitempty"`
+	LibraryID    primitive.ObjectID `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	Provider     string             `json:"provider,omitempty" bson:"provider,omitempty"`
+	Subject      string             `json:"subject,omitempty" bson:"subject,omitempty"`
+	Timestamps   `bson:",inline"`
+}
+
+type RefreshToken struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"userId,omitempty" bson:"userId,omitempty"`
+	TokenHash string             `json:"-" bson:"tokenHash,omitempty"`
+	ExpiresAt time.Time          `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	RevokedAt *time.Time         `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+}
+
+type RevokedToken struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	TokenID   string             `json:"tokenId,omitempty" bson:"tokenId,omitempty"`
+	ExpiresAt time.Time          `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
+
+// LoginState carries PKCE and nonce values between the OIDC login redirect and its callback
+type LoginState struct {
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	State        string             `json:"state,omitempty" bson:"state,omitempty"`
+	CodeVerifier string             `json:"-" bson:"codeVerifier,omitempty"`
+	Nonce        string             `json:"-" bson:"nonce,omitempty"`
+	ExpiresAt    time.Time          `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
+
+type Credentials struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type RoleChange struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type TokenPair struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken"`
+	TokenType    string    `json:"tokenType"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
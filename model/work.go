@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// a work groups the editions of one title, e.g. the hardcover, the paperback and
+// the audiobook of a novel. Its contributors are those of every edition
+type Work struct {
+	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Title       string             `json:"title,omitempty" bson:"title,omitempty"`
+	Subtitle    string             `json:"subtitle,omitempty" bson:"subtitle,omitempty"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	// year of the first edition
+	FirstPublished int                  `json:"firstPublished,omitempty" bson:"firstPublished,omitempty"`
+	Authors        []primitive.ObjectID `json:"authors,omitempty" bson:"-"`
+	Contributors   []Contributor        `json:"contributors,omitempty" bson:"contributors,omitempty"`
+	// books to attach as editions when the work is created
+	Editions   []primitive.ObjectID `json:"editions,omitempty" bson:"-"`
+	LibraryID  primitive.ObjectID   `json:"libraryId,omitempty" bson:"libraryId,omitempty"`
+	Timestamps `bson:",inline"`
+}
+
+// a work with its contributors by role and all of its editions
+type WorkWithEditions struct {
+	Work         `bson:",inline"`
+	Authors      []AuthorInfo `json:"authors"`
+	Editors      []AuthorInfo `json:"editors,omitempty"`
+	Translators  []AuthorInfo `json:"translators,omitempty"`
+	Illustrators []AuthorInfo `json:"illustrators,omitempty"`
+	Editions     []Edition    `json:"editions"`
+	// whether the reader has read any edition
+	Read bool `json:"read"`
+}
+
+// one edition of a work: its own identifiers, format, publisher and the reader's reading state
+type Edition struct {
+	ID              primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Title           string             `json:"title,omitempty" bson:"title,omitempty"`
+	Subtitle        string             `json:"subtitle,omitempty" bson:"subtitle,omitempty"`
+	Edition         string             `json:"edition,omitempty" bson:"edition,omitempty"`
+	Format          string             `json:"format,omitempty" bson:"format,omitempty"`
+	Publisher       string             `json:"publisher,omitempty" bson:"publisher,omitempty"`
+	PublisherID     primitive.ObjectID `json:"publisherId,omitempty" bson:"publisherId,omitempty"`
+	PublicationYear int                `json:"publicationYear,omitempty" bson:"publicationYear,omitempty"`
+	PublishedDate   string             `json:"publishedDate,omitempty" bson:"publishedDate,omitempty"`
+	PageCount       int                `json:"pageCount,omitempty" bson:"pageCount,omitempty"`
+	Language        string             `json:"language,omitempty" bson:"language,omitempty"`
+	ISBN10          string             `json:"isbn10,omitempty" bson:"isbn10,omitempty"`
+	ISBN13          string             `json:"isbn13,omitempty" bson:"isbn13,omitempty"`
+	Read            bool               `json:"read" bson:"read"`
+	ReadingStatus   string             `json:"readingStatus,omitempty" bson:"readingStatus,omitempty"`
+	Rating          float64            `json:"rating,omitempty" bson:"rating,omitempty"`
+	DateRead        *time.Time         `json:"dateRead,omitempty" bson:"dateRead,omitempty"`
+}
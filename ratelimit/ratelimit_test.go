@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "120/1m", want: Limit{Requests: 120, Period: time.Minute}},
+		{value: "10/1s", want: Limit{Requests: 10, Period: time.Second}},
+		{value: "120", wantErr: true},
+		{value: "0/1m", wantErr: true},
+		{value: "-5/1m", wantErr: true},
+		{value: "ten/1m", wantErr: true},
+		{value: "10/0s", wantErr: true},
+		{value: "10/minute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseLimit(%q) = %+v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLimit(%q) error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// a store whose clock only moves when the test advances it
+func newTestStore() (*MemoryStore, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{Requests: 2, Period: 10 * time.Second}
+
+	tests := []struct {
+		name          string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{name: "first request", wantAllowed: true, wantRemaining: 1},
+		{name: "burst", wantAllowed: true, wantRemaining: 0},
+		{name: "empty bucket", wantAllowed: false, wantRemaining: 0, wantRetry: 5 * time.Second},
+		{name: "refilled one token", advance: 5 * time.Second, wantAllowed: true, wantRemaining: 0},
+		{name: "refill caps at capacity", advance: time.Hour, wantAllowed: true, wantRemaining: 1},
+	}
+
+	store, now := newTestStore()
+	for _, tt := range tests {
+		*now = now.Add(tt.advance)
+		result, err := store.Take(context.Background(), "client", limit)
+		if err != nil {
+			t.Fatalf("%s: Take error: %v", tt.name, err)
+		}
+		if result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining || result.RetryAfter != tt.wantRetry {
+			t.Errorf("%s: got allowed=%v remaining=%d retry=%v, want allowed=%v remaining=%d retry=%v", tt.name,
+				result.Allowed, result.Remaining, result.RetryAfter, tt.wantAllowed, tt.wantRemaining, tt.wantRetry)
+		}
+	}
+}
+
+func TestMemoryStoreSweepUsesBucketPeriod(t *testing.T) {
+	store, now := newTestStore()
+	hourly := Limit{Requests: 1, Period: time.Hour}
+	perSecond := Limit{Requests: 1, Period: time.Second}
+
+	if _, err := store.Take(context.Background(), "hourly", hourly); err != nil {
+		t.Fatal(err)
+	}
+
+	// A short limit checked later must not sweep a bucket that is still refilling
+	*now = now.Add(2 * time.Hour / 3)
+	if _, err := store.Take(context.Background(), "per-second", perSecond); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(2 * time.Hour / 3)
+	if _, err := store.Take(context.Background(), "per-second", perSecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.buckets["hourly"]; ok {
+		t.Errorf("hourly bucket idle past its period was not swept")
+	}
+
+	*now = now.Add(time.Second)
+	result, err := store.Take(context.Background(), "hourly", hourly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Errorf("hourly bucket should be full again")
+	}
+
+	store, now = newTestStore()
+	if _, err := store.Take(context.Background(), "hourly", hourly); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(time.Minute)
+	if _, err := store.Take(context.Background(), "per-second", perSecond); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(time.Minute)
+	if _, err := store.Take(context.Background(), "per-second", perSecond); err != nil {
+		t.Fatal(err)
+	}
+	result, err = store.Take(context.Background(), "hourly", hourly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Errorf("hourly bucket was swept before its period and refilled early")
+	}
+}
@@ -0,0 +1,26 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+
+	adminGroup := router.Group("/admin")
+	adminGroup.Use(middleware.Require(auth.PermMaintenance))
+	{
+		adminGroup.GET("/backup", controller.BackupDatabase)
+		adminGroup.POST("/restore", controller.RestoreDatabase)
+	}
+
+	return router
+}
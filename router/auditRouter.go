@@ -0,0 +1,24 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AuditRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+
+	auditGroup := router.Group("/audit")
+	{
+		auditGroup.GET("", middleware.Require(auth.PermAuditRead), controller.GetAuditEntries)
+	}
+
+	return router
+}
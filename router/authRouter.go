@@ -0,0 +1,37 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AuthRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+
+	authGroup := router.Group("/auth")
+	{
+		authGroup.POST("/register", controller.Register)
+		authGroup.POST("/login", controller.Login)
+		authGroup.POST("/refresh", controller.Refresh)
+		authGroup.POST("/logout", controller.Logout)
+		authGroup.GET("/oidc/login", controller.OIDCLogin)
+		authGroup.GET("/oidc/callback", controller.OIDCCallback)
+		authGroup.PUT("/users/:userId/role", authenticate(), rateLimit(), middleware.Require(auth.PermUserManage), controller.SetUserRole)
+		authGroup.PUT("/users/:userId/library", authenticate(), rateLimit(), middleware.Require(auth.PermLibraryManage), controller.SetUserLibrary)
+	}
+
+	apiKeyGroup := router.Group("/auth/api-keys", authenticate(), rateLimit(), middleware.Require(auth.PermAPIKeyManage))
+	{
+		apiKeyGroup.POST("", controller.CreateAPIKey)
+		apiKeyGroup.GET("", controller.GetAPIKeys)
+		apiKeyGroup.POST("/:keyId/rotate", controller.RotateAPIKey)
+		apiKeyGroup.DELETE("/:keyId", controller.RevokeAPIKey)
+	}
+
+	return router
+}
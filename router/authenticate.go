@@ -0,0 +1,18 @@
+package router
+
+import (
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authentication shared by every protected route group
+func authenticate() gin.HandlerFunc {
+	return middleware.Authenticate(controller.IsTokenRevoked, controller.VerifyAPIKey)
+}
+
+// library scoping for catalog routes, must run after authenticate
+func tenant() gin.HandlerFunc {
+	return middleware.Tenant(controller.ResolveLibrary)
+}
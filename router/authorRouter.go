@@ -1,21 +1,35 @@
 package router
 
 import (
+	"example/books-api/auth"
 	"example/books-api/controller"
+	"example/books-api/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AuthorRoutes() *gin.Engine {
-	router := gin.Default()
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+	router.Use(tenant())
+
+	read := middleware.Require(auth.PermAuthorRead)
+	write := middleware.Require(auth.PermAuthorWrite)
 
 	authorGroup := router.Group("/author")
 	{
-		authorGroup.POST("/add", controller.CreateAuthor)
-		authorGroup.GET("/all", controller.GetAllAuthors)
-		authorGroup.GET("/:authorId", controller.GetAuthor)
-		authorGroup.PUT("/:authorId", controller.UpdateAuthor)
-		authorGroup.DELETE("/:authorId", controller.DeleteAuthor)
+		authorGroup.POST("/add", write, controller.CreateAuthor)
+		authorGroup.POST("/bulk", write, controller.BulkAuthors)
+		authorGroup.GET("/all", read, controller.GetAllAuthors)
+		authorGroup.GET("/:authorId", read, controller.GetAuthor)
+		authorGroup.PUT("/:authorId", write, controller.UpdateAuthor)
+		authorGroup.GET("/:authorId/revisions", read, controller.GetAuthorRevisions)
+		authorGroup.GET("/:authorId/revisions/diff", read, controller.GetAuthorRevisionDiff)
+		authorGroup.POST("/:authorId/revisions/:rev/restore", write, controller.RestoreAuthorRevision)
+		authorGroup.DELETE("/:authorId", middleware.Require(auth.PermAuthorDelete), controller.DeleteAuthor)
 	}
 
 	return router
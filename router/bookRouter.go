@@ -1,22 +1,47 @@
 package router
 
 import (
+	"example/books-api/auth"
 	"example/books-api/controller"
+	"example/books-api/middleware"
 	"github.com/gin-gonic/gin"
 )
 
 func BookRoutes() *gin.Engine {
-	router := gin.Default()
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+	router.Use(tenant())
+
+	read := middleware.Require(auth.PermBookRead)
+	write := middleware.Require(auth.PermBookWrite)
 
 	bookGroup := router.Group("/book")
 	{
-		bookGroup.POST("/add", controller.CreateBook)
-		bookGroup.GET("/all", controller.GetAllBooksWithAuthors)
-		bookGroup.GET("/:bookId", controller.GetBookWithAuthor)
-		bookGroup.GET("/author-books/:authorId", controller.GetBooksForAuthor)
-		bookGroup.PUT("/read-book/:bookId", controller.ReadBook)
-		bookGroup.PUT("/:bookId", controller.UpdateBook)
-		bookGroup.DELETE("/:bookId", controller.DeleteBook)
+		bookGroup.POST("/add", write, controller.CreateBook)
+		bookGroup.POST("/bulk", write, controller.BulkBooks)
+		bookGroup.GET("/all", read, controller.GetAllBooksWithAuthors)
+		bookGroup.GET("/cite", read, controller.CiteBooks)
+		bookGroup.GET("/isbn/:isbn", read, controller.GetBookByISBN)
+		bookGroup.GET("/metadata/:isbn", read, controller.GetISBNMetadata)
+		bookGroup.GET("/:bookId", read, controller.GetBookWithAuthor)
+		bookGroup.GET("/:bookId/cite", read, controller.CiteBook)
+		bookGroup.GET("/author-books/:authorId", read, controller.GetBooksForAuthor)
+		bookGroup.PUT("/read-book/:bookId", write, controller.ReadBook)
+		// every reader keeps their own state, so read access is enough
+		bookGroup.GET("/:bookId/reading-state", read, controller.GetReadingState)
+		bookGroup.PUT("/:bookId/reading-state", read, controller.SetReadingState)
+		bookGroup.PUT("/:bookId", write, controller.UpdateBook)
+		bookGroup.POST("/:bookId/enrich", write, controller.EnrichBook)
+		// shared tags also need book:write, checked by the handlers
+		bookGroup.POST("/:bookId/tags", read, controller.AddBookTags)
+		bookGroup.DELETE("/:bookId/tags/:tag", read, controller.RemoveBookTag)
+		bookGroup.GET("/:bookId/revisions", read, controller.GetBookRevisions)
+		bookGroup.GET("/:bookId/revisions/diff", read, controller.GetBookRevisionDiff)
+		bookGroup.POST("/:bookId/revisions/:rev/restore", write, controller.RestoreBookRevision)
+		bookGroup.DELETE("/:bookId", middleware.Require(auth.PermBookDelete), controller.DeleteBook)
 	}
 
 	return router
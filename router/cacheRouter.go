@@ -0,0 +1,24 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CacheRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+
+	cacheGroup := router.Group("/cache")
+	{
+		cacheGroup.GET("/stats", middleware.Require(auth.PermMaintenance), controller.GetCacheStats)
+	}
+
+	return router
+}
@@ -0,0 +1,25 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CatalogRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+	router.Use(tenant())
+
+	// Imports create authors as well as books
+	router.GET("/export.csv", middleware.Require(auth.PermBookRead), controller.ExportCSV)
+	router.POST("/import", middleware.Require(auth.PermBookWrite), middleware.Require(auth.PermAuthorWrite), controller.ImportCSV)
+	router.POST("/import/:source", middleware.Require(auth.PermBookWrite), middleware.Require(auth.PermAuthorWrite), controller.ImportLibrary)
+
+	return router
+}
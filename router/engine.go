@@ -0,0 +1,31 @@
+package router
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewEngine is gin.Default with the proxies from TRUSTED_PROXIES, a comma separated
+// list of addresses or CIDRs. Without it no proxy is trusted and the client IP is
+// always the peer address, so X-Forwarded-For cannot be used to pick a rate limit bucket.
+func NewEngine() *gin.Engine {
+	engine := gin.Default()
+	if err := engine.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Println(err)
+		engine.SetTrustedProxies(nil)
+	}
+	return engine
+}
+
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
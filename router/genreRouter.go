@@ -0,0 +1,35 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// genres are part of the book catalog and share its permissions
+func GenreRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+	router.Use(tenant())
+
+	read := middleware.Require(auth.PermBookRead)
+	write := middleware.Require(auth.PermBookWrite)
+
+	genreGroup := router.Group("/genre")
+	{
+		genreGroup.POST("/add", write, controller.CreateGenre)
+		genreGroup.GET("/all", read, controller.GetAllGenres)
+		genreGroup.GET("/tree", read, controller.GetGenreTree)
+		genreGroup.POST("/migrate", write, controller.MigrateGenres)
+		genreGroup.GET("/:genreId", read, controller.GetGenre)
+		genreGroup.PUT("/:genreId", write, controller.UpdateGenre)
+		genreGroup.DELETE("/:genreId", middleware.Require(auth.PermBookDelete), controller.DeleteGenre)
+	}
+
+	return router
+}
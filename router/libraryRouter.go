@@ -0,0 +1,30 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func LibraryRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+	router.Use(middleware.Require(auth.PermLibraryManage))
+
+	libraryGroup := router.Group("/library")
+	{
+		libraryGroup.POST("/add", controller.CreateLibrary)
+		libraryGroup.GET("/all", controller.GetAllLibraries)
+		libraryGroup.GET("/:libraryId", controller.GetLibrary)
+		libraryGroup.PUT("/:libraryId", controller.UpdateLibrary)
+		libraryGroup.DELETE("/:libraryId", controller.DeleteLibrary)
+		libraryGroup.POST("/:libraryId/adopt", middleware.Require(auth.PermMaintenance), controller.AdoptUnscoped)
+	}
+
+	return router
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// publishers are part of the book catalog and share its permissions
+func PublisherRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+	router.Use(tenant())
+
+	read := middleware.Require(auth.PermBookRead)
+	write := middleware.Require(auth.PermBookWrite)
+
+	publisherGroup := router.Group("/publisher")
+	{
+		publisherGroup.POST("/add", write, controller.CreatePublisher)
+		publisherGroup.GET("/all", read, controller.GetAllPublishers)
+		publisherGroup.GET("/:publisherId", read, controller.GetPublisher)
+		publisherGroup.GET("/:publisherId/books", read, controller.GetPublisherBooks)
+		publisherGroup.PUT("/:publisherId", write, controller.UpdatePublisher)
+		publisherGroup.POST("/:publisherId/merge", middleware.Require(auth.PermBookDelete), controller.MergePublishers)
+		publisherGroup.DELETE("/:publisherId", middleware.Require(auth.PermBookDelete), controller.DeletePublisher)
+	}
+
+	return router
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"example/books-api/middleware"
+	"example/books-api/ratelimit"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var defaultReadLimit = ratelimit.Limit{Requests: 300, Period: time.Minute}
+var defaultWriteLimit = ratelimit.Limit{Requests: 60, Period: time.Minute}
+
+// shared by every engine so a client has one budget across author and book routes
+var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+
+// SetRateLimitStore swaps the in-memory store for a shared one, call before building routes
+func SetRateLimitStore(store ratelimit.Store) {
+	rateLimitStore = store
+}
+
+// use it before authenticate, where every client is limited by IP, and again after it,
+// where verified clients get their own bucket
+func rateLimit() gin.HandlerFunc {
+	return middleware.RateLimit(rateLimitStore,
+		limitFromEnv("RATE_LIMIT_READ", defaultReadLimit),
+		limitFromEnv("RATE_LIMIT_WRITE", defaultWriteLimit))
+}
+
+// limits like "300/1m", falling back to the default when unset or invalid
+func limitFromEnv(key string, fallback ratelimit.Limit) ratelimit.Limit {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		log.Println(err)
+		return fallback
+	}
+	return limit
+}
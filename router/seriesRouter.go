@@ -0,0 +1,34 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// series are part of the book catalog and share its permissions
+func SeriesRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+	router.Use(tenant())
+
+	read := middleware.Require(auth.PermBookRead)
+	write := middleware.Require(auth.PermBookWrite)
+
+	seriesGroup := router.Group("/series")
+	{
+		seriesGroup.POST("/add", write, controller.CreateSeries)
+		seriesGroup.GET("/all", read, controller.GetAllSeries)
+		seriesGroup.GET("/:seriesId", read, controller.GetSeries)
+		seriesGroup.GET("/:seriesId/next", read, controller.GetNextInSeries)
+		seriesGroup.PUT("/:seriesId", write, controller.UpdateSeries)
+		seriesGroup.DELETE("/:seriesId", middleware.Require(auth.PermBookDelete), controller.DeleteSeries)
+	}
+
+	return router
+}
@@ -0,0 +1,31 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// anyone who can read books can keep personal tags, shared tags are
+// checked against book:write by the handlers
+func TagRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+	router.Use(tenant())
+
+	read := middleware.Require(auth.PermBookRead)
+
+	tagGroup := router.Group("/tag")
+	{
+		tagGroup.GET("/all", read, controller.GetAllTags)
+		tagGroup.POST("/merge", read, controller.MergeTags)
+		tagGroup.PUT("/:tag", read, controller.RenameTag)
+	}
+
+	return router
+}
@@ -0,0 +1,37 @@
+package router
+
+import (
+	"example/books-api/auth"
+	"example/books-api/controller"
+	"example/books-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// works group the editions of the book catalog and share its permissions
+func WorkRoutes() *gin.Engine {
+	router := NewEngine()
+	router.Use(middleware.RequestID())
+	router.Use(rateLimit())
+	router.Use(authenticate())
+	router.Use(rateLimit())
+	router.Use(tenant())
+
+	read := middleware.Require(auth.PermBookRead)
+	write := middleware.Require(auth.PermBookWrite)
+
+	workGroup := router.Group("/work")
+	{
+		workGroup.POST("/add", write, controller.CreateWork)
+		workGroup.GET("/all", read, controller.GetAllWorks)
+		workGroup.GET("/:workId", read, controller.GetWork)
+		workGroup.PUT("/:workId", write, controller.UpdateWork)
+		workGroup.POST("/:workId/editions", write, controller.AddWorkEditions)
+		workGroup.DELETE("/:workId/editions/:bookId", write, controller.RemoveWorkEdition)
+		// every reader keeps their own state, so read access is enough
+		workGroup.PUT("/:workId/editions/:bookId/reading-state", read, controller.SetEditionReadingState)
+		workGroup.DELETE("/:workId", middleware.Require(auth.PermBookDelete), controller.DeleteWork)
+	}
+
+	return router
+}